@@ -17,6 +17,9 @@ type Ast struct {
 	curr lex.Token
 	prev lex.Token
 	next lex.Token
+	// noIn disables the "in" operator while parsing the header of a for loop
+	// so that "for (x in xs)" isn't parsed as a membership test
+	noIn bool
 }
 
 func New(tok Tokenizer) *Ast {
@@ -67,12 +70,18 @@ func (a *Ast) program() (Node, error) {
 
 // declaration -> funDecl
 //             | varDecl
+//             | structDecl
+//             | classDecl
 //             | statement ;
 func (a *Ast) declaration() (Node, error) {
 	if a.consume(lex.TT_FUNCTION) {
 		return a.funDeclaration()
 	} else if a.consume(lex.TT_VAR) {
 		return a.varDeclaration()
+	} else if a.consume(lex.TT_STRUCT) {
+		return a.structDeclaration()
+	} else if a.consume(lex.TT_CLASS) {
+		return a.classDeclaration()
 	} else {
 		return a.statement()
 	}
@@ -92,6 +101,7 @@ func (a *Ast) funDeclaration() (Node, error) {
 				Type:    lex.TT_IDENTIFIER,
 				Literal: fmt.Sprintf("anon-%s", RandStringBytes(8)),
 			},
+			Binding: &Binding{},
 		}
 	} else {
 		identifier, err = a.atom()
@@ -193,10 +203,16 @@ func (a *Ast) parameter() (IdentifierNode, error) {
 	return param.(IdentifierNode), nil
 }
 
-// varDecl -> "var" IDENTIFIER ( "=" expression )? ;
+// varDecl -> "var" IDENTIFIER ( "=" expressionList )?
+//          | "var" IDENTIFIER ( "," IDENTIFIER )+ "=" expressionList
+//          | "var" ( listPattern | mapPattern ) "=" expressionList ;
 func (a *Ast) varDeclaration() (Node, error) {
 	begin := a.curr.BeginPosition
 
+	if a.checkAny([]lex.TokenType{lex.TT_LBRACKET, lex.TT_LBRACE}) {
+		return a.destructuringDeclaration(begin)
+	}
+
 	atom, err := a.atom()
 	if err != nil {
 		return nil, err
@@ -207,6 +223,10 @@ func (a *Ast) varDeclaration() (Node, error) {
 		return nil, NewSyntaxError("Expected identifier after var", a.curr)
 	}
 
+	if a.check(lex.TT_COMMA) {
+		return a.multiVarDeclaration(begin, identifier)
+	}
+
 	if !a.consume(lex.TT_ASSIGN) {
 		end := a.curr.BeginPosition
 		return VarStmtNode{
@@ -217,7 +237,7 @@ func (a *Ast) varDeclaration() (Node, error) {
 		}, nil
 	}
 
-	value, err := a.expression()
+	value, err := a.expressionList()
 	if err != nil {
 		return nil, err
 	}
@@ -232,20 +252,310 @@ func (a *Ast) varDeclaration() (Node, error) {
 	}, nil
 }
 
+// multiVarDeclaration parses the variables after the first one of a declaration which unpacks its value
+func (a *Ast) multiVarDeclaration(begin lex.Position, first IdentifierNode) (Node, error) {
+	identifiers := []IdentifierNode{first}
+	seen := map[string]bool{first.Token.Literal: true}
+	for a.consume(lex.TT_COMMA) {
+		if !a.consume(lex.TT_IDENTIFIER) {
+			return nil, NewSyntaxError("expected identifier after ','", a.curr)
+		}
+
+		identifier := a.identifierNode()
+		if seen[identifier.Token.Literal] {
+			return nil, NewSyntaxError("duplicate variable in declaration", a.curr)
+		}
+		seen[identifier.Token.Literal] = true
+		identifiers = append(identifiers, identifier)
+	}
+
+	if !a.consume(lex.TT_ASSIGN) {
+		return nil, NewSyntaxError("expected '=' after variables", a.curr)
+	}
+
+	value, err := a.expressionList()
+	if err != nil {
+		return nil, err
+	}
+
+	end := a.curr.BeginPosition
+
+	return MultiVarStmtNode{
+		Identifiers: identifiers,
+		Value:       value,
+		BeginPos:    begin,
+		EndPos:      end,
+	}, nil
+}
+
+func (a *Ast) destructuringDeclaration(begin lex.Position) (Node, error) {
+	var pattern PatternNode
+	var err error
+	if a.consume(lex.TT_LBRACKET) {
+		pattern, err = a.listPattern()
+	} else if a.consume(lex.TT_LBRACE) {
+		pattern, err = a.mapPattern()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.consume(lex.TT_ASSIGN) {
+		return nil, NewSyntaxError("expected '=' after destructuring pattern", a.curr)
+	}
+
+	value, err := a.expressionList()
+	if err != nil {
+		return nil, err
+	}
+
+	end := a.curr.BeginPosition
+
+	return DestructureVarStmtNode{
+		Pattern:  pattern,
+		Value:    value,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+// listPattern -> "[" ( ( IDENTIFIER "," )* ( IDENTIFIER | "..." IDENTIFIER ) )? "]" ;
+func (a *Ast) listPattern() (PatternNode, error) {
+	begin := a.curr.BeginPosition
+
+	elements := make([]IdentifierNode, 0)
+	var rest Node
+	seen := make(map[string]bool)
+	for !a.check(lex.TT_RBRACKET) {
+		spread := a.consume(lex.TT_ELLIPSIS)
+		if !a.consume(lex.TT_IDENTIFIER) {
+			return nil, NewSyntaxError("expected identifier in list pattern", a.curr)
+		}
+
+		identifier := a.identifierNode()
+		if seen[identifier.Token.Literal] {
+			return nil, NewSyntaxError("duplicate variable in declaration", a.curr)
+		}
+		seen[identifier.Token.Literal] = true
+
+		if spread { // The rest of the elements must come last
+			rest = identifier
+			break
+		}
+		elements = append(elements, identifier)
+
+		if !a.consume(lex.TT_COMMA) {
+			break
+		}
+	}
+
+	if !a.consume(lex.TT_RBRACKET) {
+		return nil, NewSyntaxError("expected closing ']' for list pattern", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return ListPatternNode{
+		Elements: elements,
+		Rest:     rest,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+// mapPattern -> "{" ( IDENTIFIER ( "," IDENTIFIER )* ","? )? "}" ;
+func (a *Ast) mapPattern() (PatternNode, error) {
+	begin := a.curr.BeginPosition
+
+	keys := make([]IdentifierNode, 0)
+	seen := make(map[string]bool)
+	for a.consume(lex.TT_IDENTIFIER) {
+		key := a.identifierNode()
+		if seen[key.Token.Literal] {
+			return nil, NewSyntaxError("duplicate variable in declaration", a.curr)
+		}
+		seen[key.Token.Literal] = true
+		keys = append(keys, key)
+
+		if !a.consume(lex.TT_COMMA) {
+			break
+		}
+	}
+
+	if !a.consume(lex.TT_RBRACE) {
+		return nil, NewSyntaxError("expected closing '}' for map pattern", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return MapPatternNode{
+		Keys:     keys,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+// structDecl -> "struct" IDENTIFIER "{" ( IDENTIFIER ( "," IDENTIFIER )* ","? )? "}" ;
+func (a *Ast) structDeclaration() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	if !a.consume(lex.TT_IDENTIFIER) {
+		return nil, NewSyntaxError("expected struct name", a.curr)
+	}
+	identifier := a.identifierNode()
+
+	if !a.consume(lex.TT_LBRACE) {
+		return nil, NewSyntaxError("expected opening '{' for struct fields", a.curr)
+	}
+
+	fields := make([]IdentifierNode, 0)
+	seen := make(map[string]bool)
+	for a.consume(lex.TT_IDENTIFIER) {
+		field := a.identifierNode()
+		if seen[field.Token.Literal] {
+			return nil, NewSyntaxError("duplicate struct field", a.curr)
+		}
+		seen[field.Token.Literal] = true
+		fields = append(fields, field)
+
+		if !a.consume(lex.TT_COMMA) {
+			break
+		}
+	}
+
+	if !a.consume(lex.TT_RBRACE) {
+		return nil, NewSyntaxError("expected closing '}' for struct fields", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return StructStmtNode{
+		Identifier: identifier,
+		Fields:     fields,
+		BeginPos:   begin,
+		EndPos:     end,
+	}, nil
+}
+
+// classDecl -> "class" IDENTIFIER ( "<" IDENTIFIER )? "{" method* "}" ;
+func (a *Ast) classDeclaration() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	if !a.consume(lex.TT_IDENTIFIER) {
+		return nil, NewSyntaxError("expected class name", a.curr)
+	}
+	identifier := a.identifierNode()
+
+	var superclass Node
+	if a.consume(lex.TT_LT) {
+		if !a.consume(lex.TT_IDENTIFIER) {
+			return nil, NewSyntaxError("expected superclass name after '<'", a.curr)
+		}
+		superclass = a.identifierNode()
+	}
+
+	if !a.consume(lex.TT_LBRACE) {
+		return nil, NewSyntaxError("expected opening '{' for class body", a.curr)
+	}
+
+	methods := make([]FunctionNode, 0)
+	seen := make(map[string]bool)
+	for {
+		if a.consume(lex.TT_COMMENT) {
+			continue
+		} else if !a.consume(lex.TT_IDENTIFIER) {
+			break
+		}
+
+		method, err := a.method()
+		if err != nil {
+			return nil, err
+		}
+		if seen[method.Identifier.Token.Literal] {
+			return nil, NewSyntaxError("duplicate method", a.curr)
+		}
+		seen[method.Identifier.Token.Literal] = true
+		methods = append(methods, method)
+	}
+
+	if !a.consume(lex.TT_RBRACE) {
+		return nil, NewSyntaxError("expected closing '}' for class body", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return ClassStmtNode{
+		Identifier: identifier,
+		Superclass: superclass,
+		Methods:    methods,
+		BeginPos:   begin,
+		EndPos:     end,
+	}, nil
+}
+
+// method -> IDENTIFIER "(" parameters? ")" block ;
+// The method name has already been consumed
+func (a *Ast) method() (FunctionNode, error) {
+	begin := a.curr.BeginPosition
+	identifier := a.identifierNode()
+
+	parameters, err := a.parameters()
+	if err != nil {
+		return FunctionNode{}, err
+	}
+
+	if !a.consume(lex.TT_LBRACE) {
+		return FunctionNode{}, NewSyntaxError("expected opening '{' for method body", a.curr)
+	}
+
+	body, err := a.block()
+	if err != nil {
+		return FunctionNode{}, err
+	}
+
+	end := a.curr.BeginPosition
+
+	return FunctionNode{
+		Identifier: identifier,
+		Parameters: parameters,
+		Body:       body.(BlockNode),
+		BeginPos:   begin,
+		EndPos:     end,
+	}, nil
+}
+
+// identifierNode returns an identifier node for the current token
+func (a *Ast) identifierNode() IdentifierNode {
+	return IdentifierNode{
+		Token:    a.curr,
+		Binding:  &Binding{},
+		BeginPos: a.curr.BeginPosition,
+		EndPos:   a.curr.EndPosition,
+	}
+}
+
 // statement -> exprStatementNode
 //           | ifStatement
 //           | whileStatement
+//           | doWhileStatement
+//           | forStatement
 //           | breakStatement
 //           | continueStatement
 //           | returnStatement
 //           | deferStatement
 //           | assertStatement
+//           | throwStatement
+//           | tryStatement
 //           | block ;
 func (a *Ast) statement() (Node, error) {
 	if a.consume(lex.TT_IF) {
 		return a.ifStatement()
 	} else if a.consume(lex.TT_WHILE) {
 		return a.whileStatement()
+	} else if a.consume(lex.TT_DO) {
+		return a.doWhileStatement()
+	} else if a.consume(lex.TT_FOR) {
+		return a.forStatement()
 	} else if a.consume(lex.TT_BREAK) {
 		return a.breakStatement()
 	} else if a.consume(lex.TT_CONTINUE) {
@@ -256,6 +566,10 @@ func (a *Ast) statement() (Node, error) {
 		return a.deferStatement()
 	} else if a.consume(lex.TT_ASSERT) {
 		return a.assertStatement()
+	} else if a.consume(lex.TT_THROW) {
+		return a.throwStatement()
+	} else if a.consume(lex.TT_TRY) {
+		return a.tryStatement()
 	} else if a.consume(lex.TT_IMPORT) {
 		return a.importStatement()
 	} else if a.consume(lex.TT_LBRACE) {
@@ -357,6 +671,170 @@ func (a *Ast) whileStatement() (Node, error) {
 	}, nil
 }
 
+// doWhileStatement -> "do" statement "while" "(" expression ")" ;
+func (a *Ast) doWhileStatement() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	body, err := a.statement()
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.consume(lex.TT_WHILE) {
+		return nil, NewSyntaxError("expected 'while' after 'do' loop body", a.curr)
+	}
+
+	if !a.consume(lex.TT_LPAREN) {
+		return nil, NewSyntaxError("expected opening '(' for 'while' condition", a.curr)
+	}
+
+	condition, err := a.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.consume(lex.TT_RPAREN) {
+		return nil, NewSyntaxError("expected closing ')' for 'while' condition", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return DoWhileStmtNode{
+		Body:      body,
+		Condition: condition,
+		BeginPos:  begin,
+		EndPos:    end,
+	}, nil
+}
+
+// forStatement -> "for" "(" ( forInClause | forClause ) ")" statement ;
+//
+// Both forms may start with an identifier, so the initializer is parsed as an
+// expression first and treated as a loop variable if it's followed by "in" or ","
+func (a *Ast) forStatement() (Node, error) {
+	if !a.consume(lex.TT_LPAREN) {
+		return nil, NewSyntaxError("expected opening '(' for 'for' loop", a.curr)
+	}
+
+	begin := a.curr.BeginPosition
+
+	var init Node
+	var err error
+	if a.consume(lex.TT_VAR) {
+		init, err = a.varDeclaration()
+		if err != nil {
+			return nil, err
+		}
+	} else if !a.check(lex.TT_SEMICOLON) {
+		a.noIn = true
+		init, err = a.expStatement()
+		a.noIn = false
+		if err != nil {
+			return nil, err
+		}
+
+		variable, ok := init.(ExpStmtNode).Exp.(IdentifierNode)
+		if ok && a.checkAny([]lex.TokenType{lex.TT_IN, lex.TT_COMMA}) {
+			return a.forInClause(begin, variable)
+		}
+	}
+
+	return a.forClause(begin, init)
+}
+
+// forInClause -> IDENTIFIER ( "," IDENTIFIER )? "in" expression ;
+func (a *Ast) forInClause(begin lex.Position, variable IdentifierNode) (Node, error) {
+	variables := []IdentifierNode{variable}
+	if a.consume(lex.TT_COMMA) {
+		if !a.consume(lex.TT_IDENTIFIER) {
+			return nil, NewSyntaxError("expected loop variable", a.curr)
+		}
+
+		variables = append(variables, IdentifierNode{
+			Token:    a.curr,
+			Binding:  &Binding{},
+			BeginPos: a.curr.BeginPosition,
+			EndPos:   a.curr.EndPosition,
+		})
+	}
+
+	if !a.consume(lex.TT_IN) {
+		return nil, NewSyntaxError("expected 'in' after loop variables", a.curr)
+	}
+
+	iterable, err := a.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.consume(lex.TT_RPAREN) {
+		return nil, NewSyntaxError("expected closing ')' for 'for' loop", a.curr)
+	}
+
+	body, err := a.statement()
+	if err != nil {
+		return nil, err
+	}
+
+	end := a.curr.BeginPosition
+
+	return ForInStmtNode{
+		Variables: variables,
+		Iterable:  iterable,
+		Body:      body,
+		BeginPos:  begin,
+		EndPos:    end,
+	}, nil
+}
+
+// forClause -> ( varDecl | exprStatementNode )? ";" expression? ";" expression? ;
+func (a *Ast) forClause(begin lex.Position, init Node) (Node, error) {
+	if !a.consume(lex.TT_SEMICOLON) {
+		return nil, NewSyntaxError("expected ';' after 'for' loop initializer", a.curr)
+	}
+
+	var condition Node
+	var err error
+	if !a.check(lex.TT_SEMICOLON) {
+		condition, err = a.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !a.consume(lex.TT_SEMICOLON) {
+		return nil, NewSyntaxError("expected ';' after 'for' loop condition", a.curr)
+	}
+
+	var update Node
+	if !a.check(lex.TT_RPAREN) {
+		update, err = a.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !a.consume(lex.TT_RPAREN) {
+		return nil, NewSyntaxError("expected closing ')' for 'for' loop", a.curr)
+	}
+
+	body, err := a.statement()
+	if err != nil {
+		return nil, err
+	}
+
+	end := a.curr.BeginPosition
+
+	return ForStmtNode{
+		Init:      init,
+		Condition: condition,
+		Update:    update,
+		Body:      body,
+		BeginPos:  begin,
+		EndPos:    end,
+	}, nil
+}
+
 // breakStatement -> "break" ;
 func (a *Ast) breakStatement() (Node, error) {
 	return BreakStmtNode{
@@ -375,11 +853,11 @@ func (a *Ast) continueStatement() (Node, error) {
 	}, nil
 }
 
-// returnStatement -> "return" expression ;
+// returnStatement -> "return" expressionList ;
 func (a *Ast) returnStatement() (Node, error) {
 	begin := a.curr.BeginPosition
 
-	exp, err := a.expression()
+	exp, err := a.expressionList()
 	if err != nil {
 		return nil, err
 	}
@@ -393,16 +871,42 @@ func (a *Ast) returnStatement() (Node, error) {
 	}, nil
 }
 
-// deferStatement -> "defer" funcCall ;
-func (a *Ast) deferStatement() (Node, error) {
-	begin := a.curr.BeginPosition
+// expressionList -> expression ( "," expression )* ;
+// Several expressions make a tuple, this is how functions return multiple values
+func (a *Ast) expressionList() (Node, error) {
+	begin := a.next.BeginPosition
 
-	node, err := a.atom()
+	exp, err := a.expression()
 	if err != nil {
 		return nil, err
 	}
 
-	call, err := a.funcCall(node)
+	if !a.check(lex.TT_COMMA) {
+		return exp, nil
+	}
+
+	elements := []Node{exp}
+	for a.consume(lex.TT_COMMA) {
+		elem, err := a.expression()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, elem)
+	}
+
+	end := a.curr.BeginPosition
+	return TupleNode{
+		Elements: elements,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+// deferStatement -> "defer" call ;
+func (a *Ast) deferStatement() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	call, err := a.call()
 	if err != nil {
 		return nil, err
 	}
@@ -441,6 +945,76 @@ func (a *Ast) assertStatement() (Node, error) {
 	}, nil
 }
 
+// throwStatement -> "throw" expression ;
+func (a *Ast) throwStatement() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	exp, err := a.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	end := a.curr.BeginPosition
+
+	return ThrowStmtNode{
+		Exp:      exp,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+// tryStatement -> "try" block ( "catch" "(" IDENTIFIER ")" block )? ( "finally" block )? ;
+// At least one of the catch and finally clauses is required
+func (a *Ast) tryStatement() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	if !a.consume(lex.TT_LBRACE) {
+		return nil, NewSyntaxError("expected opening '{' for try block", a.curr)
+	}
+	body, err := a.block()
+	if err != nil {
+		return nil, err
+	}
+
+	node := TryStmtNode{Body: body.(BlockNode), BeginPos: begin}
+
+	if a.consume(lex.TT_CATCH) {
+		if !a.consume(lex.TT_LPAREN) {
+			return nil, NewSyntaxError("expected opening '(' after catch", a.curr)
+		}
+		if !a.consume(lex.TT_IDENTIFIER) {
+			return nil, NewSyntaxError("expected a variable for the caught value", a.curr)
+		}
+		node.Variable = a.identifierNode()
+		if !a.consume(lex.TT_RPAREN) {
+			return nil, NewSyntaxError("expected closing ')' after catch variable", a.curr)
+		}
+
+		if !a.consume(lex.TT_LBRACE) {
+			return nil, NewSyntaxError("expected opening '{' for catch block", a.curr)
+		}
+		if node.Catch, err = a.block(); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.consume(lex.TT_FINALLY) {
+		if !a.consume(lex.TT_LBRACE) {
+			return nil, NewSyntaxError("expected opening '{' for finally block", a.curr)
+		}
+		if node.Finally, err = a.block(); err != nil {
+			return nil, err
+		}
+	}
+
+	if node.Catch == nil && node.Finally == nil {
+		return nil, NewSyntaxError("expected catch or finally after try block", a.curr)
+	}
+
+	node.EndPos = a.curr.BeginPosition
+	return node, nil
+}
+
 // importStatement -> "import" STRING ;
 func (a *Ast) importStatement() (Node, error) {
 	begin := a.curr.BeginPosition
@@ -530,7 +1104,8 @@ func (a *Ast) expression() (Node, error) {
 	return exp, nil
 }
 
-// assignment -> IDENTIFIER "=" assignment
+// assignment -> ( IDENTIFIER | call "[" expression "]" | call "." IDENTIFIER ) ( "=" | "+=" | "-=" | "*=" | "/=" | "%=" ) assignment
+//            | "[" ( IDENTIFIER ( "," IDENTIFIER )* )? "]" "=" assignment
 //            | logicalOr ;
 func (a *Ast) assignment() (Node, error) {
 	begin := a.curr.BeginPosition
@@ -540,9 +1115,26 @@ func (a *Ast) assignment() (Node, error) {
 		return nil, err
 	}
 
-	if a.consume(lex.TT_ASSIGN) {
-		if _, ok := expr.(IdentifierNode); !ok {
-			return nil, NewSyntaxError("expected an identifier for assignment", a.curr)
+	assignOps := []lex.TokenType{
+		lex.TT_ASSIGN,
+		lex.TT_PLUS_ASSIGN,
+		lex.TT_MINUS_ASSIGN,
+		lex.TT_MULTIPLY_ASSIGN,
+		lex.TT_DIVIDE_ASSIGN,
+		lex.TT_MODULO_ASSIGN,
+	}
+
+	if a.consumeAny(assignOps) {
+		op := a.curr
+
+		if list, ok := expr.(ListNode); ok {
+			return a.destructuringAssignment(begin, list, op)
+		}
+
+		switch expr.(type) {
+		case IdentifierNode, IndexOfNode, GetAttrNode:
+		default:
+			return nil, NewSyntaxError("expected an identifier, an index or an attribute for assignment", a.curr)
 		}
 
 		assign, err := a.assignment()
@@ -552,8 +1144,27 @@ func (a *Ast) assignment() (Node, error) {
 
 		end := a.curr.BeginPosition
 
+		if target, ok := expr.(IndexOfNode); ok {
+			return IndexAssignmentNode{
+				Target:   target,
+				Op:       op,
+				Value:    assign,
+				BeginPos: begin,
+				EndPos:   end,
+			}, nil
+		} else if target, ok := expr.(GetAttrNode); ok {
+			return AttrAssignmentNode{
+				Target:   target,
+				Op:       op,
+				Value:    assign,
+				BeginPos: begin,
+				EndPos:   end,
+			}, nil
+		}
+
 		return AssignmentNode{
 			Identifier: expr.(IdentifierNode),
+			Op:         op,
 			Value:      assign,
 			BeginPos:   begin,
 			EndPos:     end,
@@ -562,6 +1173,40 @@ func (a *Ast) assignment() (Node, error) {
 	return expr, nil
 }
 
+// destructuringAssignment assigns the elements of a sequence to the identifiers of a list, e.g. [a, b] = [b, a]
+func (a *Ast) destructuringAssignment(begin lex.Position, list ListNode, op lex.Token) (Node, error) {
+	if op.Type != lex.TT_ASSIGN {
+		return nil, NewSyntaxError("expected '=' for destructuring assignment", a.curr)
+	}
+
+	elements := make([]IdentifierNode, 0, len(list.Elements))
+	for _, elem := range list.Elements {
+		identifier, ok := elem.(IdentifierNode)
+		if !ok {
+			return nil, NewSyntaxError("expected identifiers for destructuring assignment", a.curr)
+		}
+		elements = append(elements, identifier)
+	}
+
+	value, err := a.assignment()
+	if err != nil {
+		return nil, err
+	}
+
+	end := a.curr.BeginPosition
+
+	return DestructureAssignmentNode{
+		Pattern: ListPatternNode{
+			Elements: elements,
+			BeginPos: list.BeginPos,
+			EndPos:   list.EndPos,
+		},
+		Value:    value,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
 // logicalOr -> logicalAnd ( "||" logicalAnd )*
 func (a *Ast) logicalOr() (Node, error) {
 	begin := a.curr.BeginPosition
@@ -621,9 +1266,62 @@ func (a *Ast) equality() (Node, error) {
 	return a.binaryOp([]lex.TokenType{lex.TT_EQ, lex.TT_NEQ}, a.comparison)
 }
 
-// comparison -> term ( ( "<" | "<=" | ">" | ">=" ) term )* ;
+// comparison -> term ( ( "<" | "<=" | ">" | ">=" | "in" | "not" "in" ) term )* ;
 func (a *Ast) comparison() (Node, error) {
-	return a.binaryOp([]lex.TokenType{lex.TT_LT, lex.TT_LTE, lex.TT_GT, lex.TT_GTE}, a.term)
+	begin := a.curr.BeginPosition
+
+	left, err := a.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, ok, err := a.comparisonOp()
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+
+		right, err := a.term()
+		if err != nil {
+			return nil, err
+		}
+
+		end := a.curr.BeginPosition
+
+		left = BinaryOpNode{
+			LeftExp:  left,
+			Op:       tok,
+			RightExp: right,
+			BeginPos: begin,
+			EndPos:   end,
+		}
+	}
+	return left, nil
+}
+
+// comparisonOp consumes a comparison operator, "not" "in" is combined into a single "not in" token
+func (a *Ast) comparisonOp() (lex.Token, bool, error) {
+	if a.consumeAny([]lex.TokenType{lex.TT_LT, lex.TT_LTE, lex.TT_GT, lex.TT_GTE}) {
+		return a.curr, true, nil
+	} else if a.noIn {
+		return a.curr, false, nil
+	} else if a.consume(lex.TT_IN) {
+		return a.curr, true, nil
+	} else if a.consume(lex.TT_NOT_KEYWORD) {
+		begin := a.curr.BeginPosition
+		if !a.consume(lex.TT_IN) {
+			return a.curr, false, NewSyntaxError("expected 'in' after 'not'", a.curr)
+		}
+		return lex.Token{
+			Type:          lex.TT_NOT_IN,
+			Literal:       "not in",
+			BeginPosition: begin,
+			EndPosition:   a.curr.EndPosition,
+		}, true, nil
+	}
+	return a.curr, false, nil
 }
 
 // term -> factor ( ( "+" | "-" ) factor )* ;
@@ -641,8 +1339,7 @@ func (a *Ast) factor() (Node, error) {
 func (a *Ast) unary() (Node, error) {
 	begin := a.curr.BeginPosition
 
-	var node Node
-	for a.consumeAny([]lex.TokenType{lex.TT_NOT, lex.TT_MINUS}) {
+	if a.consumeAny([]lex.TokenType{lex.TT_NOT, lex.TT_MINUS}) {
 		tok := a.curr
 
 		n, err := a.unary()
@@ -652,40 +1349,39 @@ func (a *Ast) unary() (Node, error) {
 
 		end := a.curr.BeginPosition
 
-		node = UnaryOpNode{
+		return UnaryOpNode{
 			Op:       tok,
 			Operand:  n,
 			BeginPos: begin,
 			EndPos:   end,
-		}
-	}
-
-	if node != nil {
-		return node, nil
+		}, nil
 	}
 	return a.call()
 }
 
-// call -> funcCall
-//      | indexCall ;
+// call -> atom ( "(" arguments? ")" | "[" ( expression | slice ) "]" | "." IDENTIFIER )* ;
 func (a *Ast) call() (Node, error) {
 	expr, err := a.atom()
 	if err != nil {
 		return nil, err
 	}
 
-	if a.check(lex.TT_LPAREN) {
-		expr, err = a.funcCall(expr)
-		if err != nil {
-			return nil, err
+	begin := a.curr.BeginPosition
+	for {
+		if a.consume(lex.TT_LPAREN) {
+			expr, err = a.finishCall(expr)
+		} else if a.onSameLine() && a.consume(lex.TT_LBRACKET) { // A '[' on a new line starts a destructuring assignment
+			expr, err = a.finishIndex(expr, begin)
+		} else if a.consume(lex.TT_DOT) {
+			expr, err = a.finishGetAttr(expr, begin)
+		} else {
+			return expr, nil
 		}
-	} else if a.check(lex.TT_LBRACKET) {
-		expr, err = a.indexCall(expr)
+
 		if err != nil {
 			return nil, err
 		}
 	}
-	return expr, nil
 }
 
 // funcCall -> atom ( "(" arguments? ")" )* ;
@@ -728,30 +1424,73 @@ func (a *Ast) finishCall(callee Node) (Node, error) {
 	}, nil
 }
 
-// indexCall -> atom ( "[" expression "]" )* ;
-func (a *Ast) indexCall(atom Node) (Node, error) {
-	begin := a.curr.BeginPosition
-	expr := atom
-	for a.consume(lex.TT_LBRACKET) {
-		indexExpr, err := a.expression()
+func (a *Ast) finishIndex(sequence Node, begin lex.Position) (Node, error) {
+	indexExpr, err := a.optionalExpression(lex.TT_COLON)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.consume(lex.TT_COLON) {
+		return a.slice(sequence, indexExpr, begin)
+	}
+
+	if !a.consume(lex.TT_RBRACKET) {
+		return nil, NewSyntaxError("expected closing ']' for index operation", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return IndexOfNode{
+		Sequence: sequence,
+		Index:    indexExpr,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+func (a *Ast) finishGetAttr(object Node, begin lex.Position) (Node, error) {
+	if !a.consume(lex.TT_IDENTIFIER) {
+		return nil, NewSyntaxError("expected an attribute name after '.'", a.curr)
+	}
+
+	return GetAttrNode{
+		Object:   object,
+		Name:     a.identifierNode(),
+		BeginPos: begin,
+		EndPos:   a.curr.EndPosition,
+	}, nil
+}
+
+// slice -> expression? ":" expression? ( ":" expression? )? ;
+// The start of the slice and the first ":" have already been consumed
+func (a *Ast) slice(sequence Node, start Node, begin lex.Position) (Node, error) {
+	stop, err := a.optionalExpression(lex.TT_COLON, lex.TT_RBRACKET)
+	if err != nil {
+		return nil, err
+	}
+
+	var step Node
+	if a.consume(lex.TT_COLON) {
+		step, err = a.optionalExpression(lex.TT_RBRACKET)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		if !a.consume(lex.TT_RBRACKET) {
-			return nil, NewSyntaxError("expected closing ']' for index operation", a.curr)
-		}
+	if !a.consume(lex.TT_RBRACKET) {
+		return nil, NewSyntaxError("expected closing ']' for slice operation", a.curr)
+	}
 
-		end := a.curr.BeginPosition
+	end := a.curr.BeginPosition
 
-		expr = IndexOfNode{
-			Sequence: expr,
-			Index:    indexExpr,
-			BeginPos: begin,
-			EndPos:   end,
-		}
-	}
-	return expr, nil
+	return SliceNode{
+		Sequence: sequence,
+		Start:    start,
+		Stop:     stop,
+		Step:     step,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
 }
 
 // arguments -> expression ( "," expression )* ;
@@ -777,9 +1516,14 @@ func (a *Ast) arguments() ([]Node, error) {
 }
 
 // atom -> NUMBER | STRING | "true" | "false" | "nil"
+//      | interpolatedString
 //      | "(" expression ")"
+//      | tuple
 //      | list
 //      | map
+//      | set
+//      | "this"
+//      | "super" "." IDENTIFIER
 //      | IDENTIFIER ;
 func (a *Ast) atom() (Node, error) {
 	if a.consume(lex.TT_NUMBER) {
@@ -794,6 +1538,8 @@ func (a *Ast) atom() (Node, error) {
 			BeginPos: a.curr.BeginPosition,
 			EndPos:   a.curr.EndPosition,
 		}, nil
+	} else if a.check(lex.TT_STRING_PART) {
+		return a.interpolatedStringNode()
 	} else if a.consumeAny([]lex.TokenType{lex.TT_TRUE, lex.TT_FALSE}) {
 		return BooleanNode{
 			Token:    a.curr,
@@ -803,9 +1549,14 @@ func (a *Ast) atom() (Node, error) {
 	} else if a.consume(lex.TT_IDENTIFIER) {
 		return IdentifierNode{
 			Token:    a.curr,
+			Binding:  &Binding{},
 			BeginPos: a.curr.BeginPosition,
 			EndPos:   a.curr.EndPosition,
 		}, nil
+	} else if a.consume(lex.TT_THIS) {
+		return a.identifierNode(), nil
+	} else if a.consume(lex.TT_SUPER) {
+		return a.superNode()
 	} else if a.consume(lex.TT_NIL) {
 		return NilNode{
 			Token:    a.curr,
@@ -813,26 +1564,302 @@ func (a *Ast) atom() (Node, error) {
 			EndPos:   a.curr.EndPosition,
 		}, nil
 	} else if a.consume(lex.TT_LPAREN) {
-		return a.nestedExpressionNode()
+		return a.nestedExpressionOrTupleNode()
 	} else if a.consume(lex.TT_LBRACE) {
 		return a.mapNode()
+	} else if a.consume(lex.TT_HASH_LBRACE) {
+		return a.setNode()
 	} else if a.consume(lex.TT_LBRACKET) {
 		return a.listNode()
 	} else if a.consume(lex.TT_FUNCTION) {
 		return a.funDeclaration()
+	} else if a.consume(lex.TT_MATCH) {
+		return a.matchNode()
 	} else if a.consume(lex.TT_COMMENT) {
 		return CommentNode{
 			Token:    a.curr,
 			BeginPos: a.curr.BeginPosition,
 			EndPos:   a.curr.EndPosition,
 		}, nil
+	} else if a.consume(lex.TT_ILLEGAL) {
+		return nil, NewSyntaxError(a.curr.Err, a.curr)
 	}
 
 	return nil, NewSyntaxError("expected a literal or an expression", a.curr)
 }
 
-func (a *Ast) nestedExpressionNode() (Node, error) {
+// match -> "match" "(" expression ")" "{" matchArm ( "," matchArm )* ","? "}" ;
+func (a *Ast) matchNode() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	if !a.consume(lex.TT_LPAREN) {
+		return nil, NewSyntaxError("expected opening '(' after match", a.curr)
+	}
+
+	value, err := a.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.consume(lex.TT_RPAREN) {
+		return nil, NewSyntaxError("expected closing ')' after match value", a.curr)
+	}
+	if !a.consume(lex.TT_LBRACE) {
+		return nil, NewSyntaxError("expected opening '{' for match arms", a.curr)
+	}
+
+	arms := make([]MatchArmNode, 0)
+	for !a.check(lex.TT_RBRACE) {
+		arm, err := a.matchArm()
+		if err != nil {
+			return nil, err
+		}
+		arms = append(arms, arm)
+
+		if !a.consume(lex.TT_COMMA) {
+			break
+		}
+	}
+
+	if len(arms) == 0 {
+		return nil, NewSyntaxError("expected at least one match arm", a.curr)
+	}
+	if !a.consume(lex.TT_RBRACE) {
+		return nil, NewSyntaxError("expected closing '}' for match arms", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return MatchNode{
+		Value:    value,
+		Arms:     arms,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+// matchArm -> pattern ( "if" expression )? "=>" expression ;
+func (a *Ast) matchArm() (MatchArmNode, error) {
+	begin := a.next.BeginPosition
+
+	pattern, err := a.pattern()
+	if err != nil {
+		return MatchArmNode{}, err
+	}
+
+	seen := make(map[string]bool)
+	for _, capture := range Captures(pattern) {
+		if seen[capture.Token.Literal] {
+			return MatchArmNode{}, NewSyntaxError("duplicate capture in pattern", capture.Token)
+		}
+		seen[capture.Token.Literal] = true
+	}
+
+	var guard Node
+	if a.consume(lex.TT_IF) {
+		if guard, err = a.expression(); err != nil {
+			return MatchArmNode{}, err
+		}
+	}
+
+	if !a.consume(lex.TT_ARROW) {
+		return MatchArmNode{}, NewSyntaxError("expected '=>' after pattern", a.curr)
+	}
+
+	body, err := a.expression()
+	if err != nil {
+		return MatchArmNode{}, err
+	}
+
+	end := a.curr.BeginPosition
+
+	return MatchArmNode{
+		Pattern:  pattern,
+		Guard:    guard,
+		Body:     body,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+// pattern -> IDENTIFIER ( ":" IDENTIFIER )?
+//          | "[" ( pattern ( "," pattern )* ( "," "..." IDENTIFIER )? | "..." IDENTIFIER )? "]"
+//          | "{" ( literal ":" pattern ( "," literal ":" pattern )* ","? )? "}"
+//          | literal ;
+func (a *Ast) pattern() (Node, error) {
+	begin := a.next.BeginPosition
+
+	if a.consume(lex.TT_IDENTIFIER) {
+		identifier := a.identifierNode()
+
+		var typ Node
+		if a.consume(lex.TT_COLON) {
+			if !a.consume(lex.TT_IDENTIFIER) {
+				return nil, NewSyntaxError("expected type name after ':'", a.curr)
+			}
+			typ = a.identifierNode()
+		}
+
+		return CapturePatternNode{
+			Identifier: identifier,
+			Type:       typ,
+			BeginPos:   begin,
+			EndPos:     a.curr.BeginPosition,
+		}, nil
+	} else if a.consume(lex.TT_LBRACKET) {
+		return a.listMatchPattern()
+	} else if a.consume(lex.TT_LBRACE) {
+		return a.mapMatchPattern()
+	}
+	return a.literalPattern()
+}
+
+func (a *Ast) listMatchPattern() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	elements := make([]Node, 0)
+	var rest Node
+	for !a.check(lex.TT_RBRACKET) {
+		if a.consume(lex.TT_ELLIPSIS) { // The rest of the elements must come last
+			if !a.consume(lex.TT_IDENTIFIER) {
+				return nil, NewSyntaxError("expected identifier after '...'", a.curr)
+			}
+			rest = a.identifierNode()
+			break
+		}
+
+		elem, err := a.pattern()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, elem)
+
+		if !a.consume(lex.TT_COMMA) {
+			break
+		}
+	}
+
+	if !a.consume(lex.TT_RBRACKET) {
+		return nil, NewSyntaxError("expected closing ']' for list pattern", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return ListMatchPatternNode{
+		Elements: elements,
+		Rest:     rest,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+func (a *Ast) mapMatchPattern() (Node, error) {
+	begin := a.curr.BeginPosition
+
+	keys := make([]Node, 0)
+	values := make([]Node, 0)
+	for !a.check(lex.TT_RBRACE) {
+		key, err := a.literalPattern()
+		if err != nil {
+			return nil, err
+		}
+
+		if !a.consume(lex.TT_COLON) {
+			return nil, NewSyntaxError("expected ':' after key in map pattern", a.curr)
+		}
+
+		value, err := a.pattern()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+
+		if !a.consume(lex.TT_COMMA) {
+			break
+		}
+	}
+
+	if !a.consume(lex.TT_RBRACE) {
+		return nil, NewSyntaxError("expected closing '}' for map pattern", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+
+	return MapMatchPatternNode{
+		Keys:     keys,
+		Values:   values,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
+// literal -> NUMBER | "-" NUMBER | STRING | "true" | "false" | "nil" ;
+func (a *Ast) literalPattern() (Node, error) {
+	var literal Node
+	var err error
+	if a.check(lex.TT_MINUS) {
+		literal, err = a.unary()
+		if unary, ok := literal.(UnaryOpNode); ok {
+			if _, ok := unary.Operand.(NumberNode); !ok {
+				return nil, NewSyntaxError("expected a pattern", a.curr)
+			}
+		}
+	} else {
+		literal, err = a.atom()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch literal.(type) {
+	case NumberNode, StringNode, BooleanNode, NilNode, UnaryOpNode:
+		return literal, nil
+	}
+	return nil, NewSyntaxError("expected a pattern", a.curr)
+}
+
+// superNode binds "super" and "this" as identifiers so that the resolver can find the enclosing class
+func (a *Ast) superNode() (Node, error) {
 	begin := a.curr.BeginPosition
+	super := a.identifierNode()
+
+	if !a.consume(lex.TT_DOT) {
+		return nil, NewSyntaxError("expected '.' after 'super'", a.curr)
+	}
+	if !a.consume(lex.TT_IDENTIFIER) {
+		return nil, NewSyntaxError("expected superclass method name", a.curr)
+	}
+	method := a.identifierNode()
+
+	this := IdentifierNode{
+		Token:    lex.Token{Type: lex.TT_THIS, Literal: "this", BeginPosition: begin, EndPosition: super.EndPos},
+		Binding:  &Binding{},
+		BeginPos: begin,
+		EndPos:   super.EndPos,
+	}
+
+	return SuperNode{
+		Super:    super,
+		This:     this,
+		Method:   method,
+		BeginPos: begin,
+		EndPos:   a.curr.EndPosition,
+	}, nil
+}
+
+// tuple -> "(" ( expression "," ( expression ( "," expression )* ","? )? )? ")" ;
+func (a *Ast) nestedExpressionOrTupleNode() (Node, error) {
+	begin := a.curr.BeginPosition
+	if a.consume(lex.TT_RPAREN) { // Tuple is empty ()
+		end := a.curr.BeginPosition
+		return TupleNode{
+			Elements: make([]Node, 0),
+			BeginPos: begin,
+			EndPos:   end,
+		}, nil
+	}
+
 	exp, err := a.expression()
 	if err != nil {
 		return nil, err
@@ -845,8 +1872,33 @@ func (a *Ast) nestedExpressionNode() (Node, error) {
 			BeginPos: begin,
 			EndPos:   end,
 		}, nil
+	} else if !a.check(lex.TT_COMMA) {
+		return nil, NewSyntaxError("expected closing ')' after expression", a.curr)
+	}
+
+	// A trailing comma is allowed and is required to make a single element tuple (1,)
+	elements := []Node{exp}
+	for a.consume(lex.TT_COMMA) {
+		if a.check(lex.TT_RPAREN) {
+			break
+		}
+		elem, err := a.expression()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, elem)
+	}
+
+	if !a.consume(lex.TT_RPAREN) {
+		return nil, NewSyntaxError("expected closing ')' for tuple", a.curr)
 	}
-	return nil, NewSyntaxError("expected closing ')' after expression", a.curr)
+
+	end := a.curr.BeginPosition
+	return TupleNode{
+		Elements: elements,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
 }
 
 // map -> "{" keyValuePairs? "}" ;
@@ -932,6 +1984,76 @@ func (a *Ast) keyValuePair() (KeyValueNode, error) {
 	}, nil
 }
 
+// interpolatedString -> STRING_PART expression ( STRING_PART expression )* STRING_END ;
+func (a *Ast) interpolatedStringNode() (Node, error) {
+	begin := a.next.BeginPosition
+	segments := make([]Node, 0, 4)
+	for a.consume(lex.TT_STRING_PART) {
+		if a.curr.Literal != "" {
+			segments = append(segments, StringNode{
+				Token:    a.curr,
+				BeginPos: a.curr.BeginPosition,
+				EndPos:   a.curr.EndPosition,
+			})
+		}
+
+		exp, err := a.expression()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, exp)
+	}
+
+	if a.consume(lex.TT_ILLEGAL) {
+		return nil, NewSyntaxError(a.curr.Err, a.curr)
+	} else if !a.consume(lex.TT_STRING_END) {
+		return nil, NewSyntaxError("expected closing '}' for embedded expression", a.curr)
+	}
+
+	if a.curr.Literal != "" {
+		segments = append(segments, StringNode{
+			Token:    a.curr,
+			BeginPos: a.curr.BeginPosition,
+			EndPos:   a.curr.EndPosition,
+		})
+	}
+
+	return InterpolatedStringNode{
+		Segments: segments,
+		BeginPos: begin,
+		EndPos:   a.curr.EndPosition,
+	}, nil
+}
+
+// set -> "#{" arguments? "}" ;
+func (a *Ast) setNode() (Node, error) {
+	begin := a.curr.BeginPosition
+	if a.consume(lex.TT_RBRACE) { // Set is empty #{}
+		end := a.curr.BeginPosition
+		return SetNode{
+			Elements: make([]Node, 0),
+			BeginPos: begin,
+			EndPos:   end,
+		}, nil
+	}
+
+	arguments, err := a.arguments()
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.consume(lex.TT_RBRACE) {
+		return nil, NewSyntaxError("expected closing '}' for set", a.curr)
+	}
+
+	end := a.curr.BeginPosition
+	return SetNode{
+		Elements: arguments,
+		BeginPos: begin,
+		EndPos:   end,
+	}, nil
+}
+
 // list -> "[" arguments? "]" ;
 func (a *Ast) listNode() (Node, error) {
 	begin := a.curr.BeginPosition
@@ -995,6 +2117,19 @@ func (a *Ast) binaryOp(tokenTypes []lex.TokenType, fun ProductionRuleHandler) (N
 	return left, nil
 }
 
+// optionalExpression parses an expression unless the next token is one of the given terminators, in which case it returns nil
+func (a *Ast) optionalExpression(terminators ...lex.TokenType) (Node, error) {
+	if a.checkAny(terminators) {
+		return nil, nil
+	}
+	return a.expression()
+}
+
+// onSameLine checks if the next token starts on the line the current token ends on
+func (a *Ast) onSameLine() bool {
+	return a.next.BeginPosition.Line == a.curr.EndPosition.Line
+}
+
 // check checks the next token if it matches the given type and returns true, otherwise it returns false
 func (a *Ast) check(tokType lex.TokenType) bool {
 	return a.checkAny([]lex.TokenType{tokType})
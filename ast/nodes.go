@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/shreerangdixit/yeti/lex"
 )
@@ -50,17 +51,28 @@ func (n ProgramNode) String() string {
 type IdentifierNode struct {
 	Node
 	Token    lex.Token
+	Binding  *Binding
 	BeginPos lex.Position
 	EndPos   lex.Position
 }
 
+// Binding is the location of the variable an identifier refers to, filled in by the resolver
+// Globals are looked up by name, locals by the number of scopes to walk up (Depth) and their index in that scope (Slot)
+type Binding struct {
+	Local bool
+	Depth int
+	Slot  int
+}
+
 func (n IdentifierNode) Begin() lex.Position { return n.BeginPos }
 func (n IdentifierNode) End() lex.Position   { return n.EndPos }
 func (n IdentifierNode) String() string      { return n.Token.Literal }
 
+// AssignmentNode assigns to a variable, Op is either "=" or a compound assignment operator such as "+="
 type AssignmentNode struct {
 	Node
 	Identifier IdentifierNode
+	Op         lex.Token
 	Value      Node
 	BeginPos   lex.Position
 	EndPos     lex.Position
@@ -68,7 +80,46 @@ type AssignmentNode struct {
 
 func (n AssignmentNode) Begin() lex.Position { return n.BeginPos }
 func (n AssignmentNode) End() lex.Position   { return n.EndPos }
-func (n AssignmentNode) String() string      { return fmt.Sprintf("%s=%s", n.Identifier, n.Value) }
+func (n AssignmentNode) String() string      { return fmt.Sprintf("%s%s%s", n.Identifier, n.Op, n.Value) }
+
+// IndexAssignmentNode assigns to an index of a collection, Op is the same as for AssignmentNode
+type IndexAssignmentNode struct {
+	Node
+	Target   IndexOfNode
+	Op       lex.Token
+	Value    Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n IndexAssignmentNode) Begin() lex.Position { return n.BeginPos }
+func (n IndexAssignmentNode) End() lex.Position   { return n.EndPos }
+func (n IndexAssignmentNode) String() string      { return fmt.Sprintf("%s%s%s", n.Target, n.Op, n.Value) }
+
+type AttrAssignmentNode struct {
+	Node
+	Target   GetAttrNode
+	Op       lex.Token
+	Value    Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n AttrAssignmentNode) Begin() lex.Position { return n.BeginPos }
+func (n AttrAssignmentNode) End() lex.Position   { return n.EndPos }
+func (n AttrAssignmentNode) String() string      { return fmt.Sprintf("%s%s%s", n.Target, n.Op, n.Value) }
+
+type DestructureAssignmentNode struct {
+	Node
+	Pattern  ListPatternNode
+	Value    Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n DestructureAssignmentNode) Begin() lex.Position { return n.BeginPos }
+func (n DestructureAssignmentNode) End() lex.Position   { return n.EndPos }
+func (n DestructureAssignmentNode) String() string      { return fmt.Sprintf("%s=%s", n.Pattern, n.Value) }
 
 type VarStmtNode struct {
 	Node
@@ -82,6 +133,139 @@ func (n VarStmtNode) Begin() lex.Position { return n.BeginPos }
 func (n VarStmtNode) End() lex.Position   { return n.EndPos }
 func (n VarStmtNode) String() string      { return fmt.Sprintf("var %s=%s", n.Identifier, n.Value) }
 
+type MultiVarStmtNode struct {
+	Node
+	Identifiers []IdentifierNode
+	Value       Node
+	BeginPos    lex.Position
+	EndPos      lex.Position
+}
+
+func (n MultiVarStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n MultiVarStmtNode) End() lex.Position   { return n.EndPos }
+
+func (n MultiVarStmtNode) String() string {
+	identifiers := make([]string, 0, len(n.Identifiers))
+	for _, identifier := range n.Identifiers {
+		identifiers = append(identifiers, identifier.String())
+	}
+	return fmt.Sprintf("var %s=%s", strings.Join(identifiers, ", "), n.Value)
+}
+
+// PatternNode is a destructuring pattern which binds the identifiers it contains
+type PatternNode interface {
+	Node
+	Identifiers() []IdentifierNode
+}
+
+type DestructureVarStmtNode struct {
+	Node
+	Pattern  PatternNode
+	Value    Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n DestructureVarStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n DestructureVarStmtNode) End() lex.Position   { return n.EndPos }
+func (n DestructureVarStmtNode) String() string      { return fmt.Sprintf("var %s=%s", n.Pattern, n.Value) }
+
+// ListPatternNode binds the elements of a sequence in order, the remaining elements are
+// bound as a list to Rest which is nil if the sequence must have exactly as many elements
+type ListPatternNode struct {
+	Node
+	Elements []IdentifierNode
+	Rest     Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n ListPatternNode) Begin() lex.Position { return n.BeginPos }
+func (n ListPatternNode) End() lex.Position   { return n.EndPos }
+
+func (n ListPatternNode) Identifiers() []IdentifierNode {
+	identifiers := make([]IdentifierNode, 0, len(n.Elements)+1)
+	identifiers = append(identifiers, n.Elements...)
+	if n.Rest != nil {
+		identifiers = append(identifiers, n.Rest.(IdentifierNode))
+	}
+	return identifiers
+}
+
+func (n ListPatternNode) String() string {
+	elems := make([]string, 0, len(n.Elements)+1)
+	for _, elem := range n.Elements {
+		elems = append(elems, elem.String())
+	}
+	if n.Rest != nil {
+		elems = append(elems, "..."+n.Rest.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elems, ", "))
+}
+
+// MapPatternNode binds the values of the keys named by its identifiers
+type MapPatternNode struct {
+	Node
+	Keys     []IdentifierNode
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n MapPatternNode) Begin() lex.Position           { return n.BeginPos }
+func (n MapPatternNode) End() lex.Position             { return n.EndPos }
+func (n MapPatternNode) Identifiers() []IdentifierNode { return n.Keys }
+
+func (n MapPatternNode) String() string {
+	keys := make([]string, 0, len(n.Keys))
+	for _, key := range n.Keys {
+		keys = append(keys, key.String())
+	}
+	return fmt.Sprintf("{%s}", strings.Join(keys, ", "))
+}
+
+type StructStmtNode struct {
+	Node
+	Identifier IdentifierNode
+	Fields     []IdentifierNode
+	BeginPos   lex.Position
+	EndPos     lex.Position
+}
+
+func (n StructStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n StructStmtNode) End() lex.Position   { return n.EndPos }
+
+func (n StructStmtNode) String() string {
+	fields := make([]string, 0, len(n.Fields))
+	for _, field := range n.Fields {
+		fields = append(fields, field.String())
+	}
+	return fmt.Sprintf("struct %s { %s }", n.Identifier, strings.Join(fields, ", "))
+}
+
+// ClassStmtNode declares a class, Superclass is nil when the class doesn't inherit from another class
+type ClassStmtNode struct {
+	Node
+	Identifier IdentifierNode
+	Superclass Node
+	Methods    []FunctionNode
+	BeginPos   lex.Position
+	EndPos     lex.Position
+}
+
+func (n ClassStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n ClassStmtNode) End() lex.Position   { return n.EndPos }
+
+func (n ClassStmtNode) String() string {
+	methods := make([]string, 0, len(n.Methods))
+	for _, method := range n.Methods {
+		methods = append(methods, method.Identifier.String())
+	}
+	if n.Superclass != nil {
+		return fmt.Sprintf("class %s < %s { %s }", n.Identifier, n.Superclass, strings.Join(methods, ", "))
+	}
+	return fmt.Sprintf("class %s { %s }", n.Identifier, strings.Join(methods, ", "))
+}
+
 type ExpStmtNode struct {
 	Node
 	Exp      Node
@@ -125,6 +309,66 @@ func (n WhileStmtNode) Begin() lex.Position { return n.BeginPos }
 func (n WhileStmtNode) End() lex.Position   { return n.EndPos }
 func (n WhileStmtNode) String() string      { return fmt.Sprintf("while(%s)\n\t%s", n.Condition, n.Body) }
 
+type DoWhileStmtNode struct {
+	Node
+	Body      Node
+	Condition Node
+	BeginPos  lex.Position
+	EndPos    lex.Position
+}
+
+func (n DoWhileStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n DoWhileStmtNode) End() lex.Position   { return n.EndPos }
+func (n DoWhileStmtNode) String() string {
+	return fmt.Sprintf("do\n\t%s\nwhile(%s)", n.Body, n.Condition)
+}
+
+// ForStmtNode is a C-style for loop, any of the Init, Condition and Update clauses may be nil
+type ForStmtNode struct {
+	Node
+	Init      Node
+	Condition Node
+	Update    Node
+	Body      Node
+	BeginPos  lex.Position
+	EndPos    lex.Position
+}
+
+func (n ForStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n ForStmtNode) End() lex.Position   { return n.EndPos }
+
+func (n ForStmtNode) String() string {
+	clauses := make([]string, 0, 3)
+	for _, clause := range []Node{n.Init, n.Condition, n.Update} {
+		if clause != nil {
+			clauses = append(clauses, clause.String())
+		} else {
+			clauses = append(clauses, "")
+		}
+	}
+	return fmt.Sprintf("for(%s)\n\t%s", strings.Join(clauses, "; "), n.Body)
+}
+
+type ForInStmtNode struct {
+	Node
+	Variables []IdentifierNode
+	Iterable  Node
+	Body      Node
+	BeginPos  lex.Position
+	EndPos    lex.Position
+}
+
+func (n ForInStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n ForInStmtNode) End() lex.Position   { return n.EndPos }
+
+func (n ForInStmtNode) String() string {
+	variables := make([]string, 0, len(n.Variables))
+	for _, v := range n.Variables {
+		variables = append(variables, v.String())
+	}
+	return fmt.Sprintf("for(%s in %s)\n\t%s", strings.Join(variables, ", "), n.Iterable, n.Body)
+}
+
 type BreakStmtNode struct {
 	Node
 	Token    lex.Token
@@ -186,6 +430,43 @@ func (n AssertStmtNode) Begin() lex.Position { return n.BeginPos }
 func (n AssertStmtNode) End() lex.Position   { return n.EndPos }
 func (n AssertStmtNode) String() string      { return fmt.Sprintf("assert %s", n.Exp) }
 
+type ThrowStmtNode struct {
+	Node
+	Exp      Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n ThrowStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n ThrowStmtNode) End() lex.Position   { return n.EndPos }
+func (n ThrowStmtNode) String() string      { return fmt.Sprintf("throw %s", n.Exp) }
+
+// TryStmtNode has at least one of a catch or a finally clause
+// Catch and Finally are nil when the clause is missing, Variable is bound to the caught value
+type TryStmtNode struct {
+	Node
+	Body     BlockNode
+	Variable IdentifierNode
+	Catch    Node
+	Finally  Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n TryStmtNode) Begin() lex.Position { return n.BeginPos }
+func (n TryStmtNode) End() lex.Position   { return n.EndPos }
+
+func (n TryStmtNode) String() string {
+	str := fmt.Sprintf("try %s", n.Body)
+	if n.Catch != nil {
+		str += fmt.Sprintf(" catch (%s) %s", n.Variable, n.Catch)
+	}
+	if n.Finally != nil {
+		str += fmt.Sprintf(" finally %s", n.Finally)
+	}
+	return str
+}
+
 type ImportStmtNode struct {
 	Node
 	Name     StringNode
@@ -317,6 +598,30 @@ func (n StringNode) Begin() lex.Position { return n.BeginPos }
 func (n StringNode) End() lex.Position   { return n.EndPos }
 func (n StringNode) String() string      { return n.Token.Literal }
 
+// InterpolatedStringNode is a string with embedded expressions, e.g. "hello ${name}"
+// Segments are StringNodes for literal text and arbitrary expressions for the embedded expressions
+type InterpolatedStringNode struct {
+	Node
+	Segments []Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n InterpolatedStringNode) Begin() lex.Position { return n.BeginPos }
+func (n InterpolatedStringNode) End() lex.Position   { return n.EndPos }
+
+func (n InterpolatedStringNode) String() string {
+	str := "\""
+	for _, segment := range n.Segments {
+		if literal, ok := segment.(StringNode); ok {
+			str += literal.String()
+		} else {
+			str += fmt.Sprintf("${%s}", segment)
+		}
+	}
+	return str + "\""
+}
+
 type ListNode struct {
 	Node
 	Elements []Node
@@ -336,6 +641,42 @@ func (n ListNode) String() string {
 	return str
 }
 
+type SetNode struct {
+	Node
+	Elements []Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n SetNode) Begin() lex.Position { return n.BeginPos }
+func (n SetNode) End() lex.Position   { return n.EndPos }
+
+func (n SetNode) String() string {
+	elems := make([]string, 0, len(n.Elements))
+	for _, elem := range n.Elements {
+		elems = append(elems, elem.String())
+	}
+	return fmt.Sprintf("#{%s}", strings.Join(elems, ", "))
+}
+
+type TupleNode struct {
+	Node
+	Elements []Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n TupleNode) Begin() lex.Position { return n.BeginPos }
+func (n TupleNode) End() lex.Position   { return n.EndPos }
+
+func (n TupleNode) String() string {
+	elems := make([]string, 0, len(n.Elements))
+	for _, elem := range n.Elements {
+		elems = append(elems, elem.String())
+	}
+	return fmt.Sprintf("(%s)", strings.Join(elems, ", "))
+}
+
 type CallNode struct {
 	Node
 	Callee    Node
@@ -363,6 +704,59 @@ func (n IndexOfNode) Begin() lex.Position { return n.BeginPos }
 func (n IndexOfNode) End() lex.Position   { return n.EndPos }
 func (n IndexOfNode) String() string      { return fmt.Sprintf("%s[%s]", n.Sequence, n.Index) }
 
+// SliceNode is a slice operation such as xs[1:3:2], omitted bounds are nil
+type GetAttrNode struct {
+	Node
+	Object   Node
+	Name     IdentifierNode
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n GetAttrNode) Begin() lex.Position { return n.BeginPos }
+func (n GetAttrNode) End() lex.Position   { return n.EndPos }
+func (n GetAttrNode) String() string      { return fmt.Sprintf("%s.%s", n.Object, n.Name) }
+
+// SuperNode looks up a method of the superclass of the class it appears in
+// Super and This refer to the variables the superclass and the instance are bound to
+type SuperNode struct {
+	Node
+	Super    IdentifierNode
+	This     IdentifierNode
+	Method   IdentifierNode
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n SuperNode) Begin() lex.Position { return n.BeginPos }
+func (n SuperNode) End() lex.Position   { return n.EndPos }
+func (n SuperNode) String() string      { return fmt.Sprintf("super.%s", n.Method) }
+
+type SliceNode struct {
+	Node
+	Sequence Node
+	Start    Node
+	Stop     Node
+	Step     Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n SliceNode) Begin() lex.Position { return n.BeginPos }
+func (n SliceNode) End() lex.Position   { return n.EndPos }
+
+func (n SliceNode) String() string {
+	bounds := make([]string, 0, 3)
+	for _, bound := range []Node{n.Start, n.Stop, n.Step} {
+		if bound != nil {
+			bounds = append(bounds, bound.String())
+		} else {
+			bounds = append(bounds, "")
+		}
+	}
+	return fmt.Sprintf("%s[%s]", n.Sequence, strings.Join(bounds, ":"))
+}
+
 type FunctionNode struct {
 	Node
 	Identifier IdentifierNode
@@ -412,3 +806,129 @@ type CommentNode struct {
 func (n CommentNode) Begin() lex.Position { return n.BeginPos }
 func (n CommentNode) End() lex.Position   { return n.EndPos }
 func (n CommentNode) String() string      { return n.Token.String() }
+
+type MatchNode struct {
+	Node
+	Value    Node
+	Arms     []MatchArmNode
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n MatchNode) Begin() lex.Position { return n.BeginPos }
+func (n MatchNode) End() lex.Position   { return n.EndPos }
+
+func (n MatchNode) String() string {
+	arms := make([]string, 0, len(n.Arms))
+	for _, arm := range n.Arms {
+		arms = append(arms, arm.String())
+	}
+	return fmt.Sprintf("match (%s) { %s }", n.Value, strings.Join(arms, ", "))
+}
+
+// MatchArmNode evaluates Body if Pattern matches and Guard, which is nil for arms without one, holds
+type MatchArmNode struct {
+	Node
+	Pattern  Node
+	Guard    Node
+	Body     Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n MatchArmNode) Begin() lex.Position { return n.BeginPos }
+func (n MatchArmNode) End() lex.Position   { return n.EndPos }
+
+func (n MatchArmNode) String() string {
+	if n.Guard != nil {
+		return fmt.Sprintf("%s if %s => %s", n.Pattern, n.Guard, n.Body)
+	}
+	return fmt.Sprintf("%s => %s", n.Pattern, n.Body)
+}
+
+// CapturePatternNode matches any value, or only values of the type named by Type if it isn't nil,
+// and binds it to Identifier unless it's the wildcard _
+type CapturePatternNode struct {
+	Node
+	Identifier IdentifierNode
+	Type       Node
+	BeginPos   lex.Position
+	EndPos     lex.Position
+}
+
+func (n CapturePatternNode) Begin() lex.Position { return n.BeginPos }
+func (n CapturePatternNode) End() lex.Position   { return n.EndPos }
+
+func (n CapturePatternNode) String() string {
+	if n.Type != nil {
+		return fmt.Sprintf("%s: %s", n.Identifier, n.Type)
+	}
+	return n.Identifier.String()
+}
+
+// ListMatchPatternNode matches lists and tuples element by element, the remaining elements are
+// bound as a list to Rest which is nil if the sequence must have exactly as many elements
+type ListMatchPatternNode struct {
+	Node
+	Elements []Node
+	Rest     Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n ListMatchPatternNode) Begin() lex.Position { return n.BeginPos }
+func (n ListMatchPatternNode) End() lex.Position   { return n.EndPos }
+
+func (n ListMatchPatternNode) String() string {
+	elems := make([]string, 0, len(n.Elements)+1)
+	for _, elem := range n.Elements {
+		elems = append(elems, elem.String())
+	}
+	if n.Rest != nil {
+		elems = append(elems, "..."+n.Rest.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elems, ", "))
+}
+
+// MapMatchPatternNode matches maps which have all of its literal keys with values matching their patterns
+type MapMatchPatternNode struct {
+	Node
+	Keys     []Node
+	Values   []Node
+	BeginPos lex.Position
+	EndPos   lex.Position
+}
+
+func (n MapMatchPatternNode) Begin() lex.Position { return n.BeginPos }
+func (n MapMatchPatternNode) End() lex.Position   { return n.EndPos }
+
+func (n MapMatchPatternNode) String() string {
+	pairs := make([]string, 0, len(n.Keys))
+	for i := range n.Keys {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", n.Keys[i], n.Values[i]))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+}
+
+// Captures returns the identifiers bound by a match pattern in the order they appear in it
+func Captures(pattern Node) []IdentifierNode {
+	captures := make([]IdentifierNode, 0)
+	switch pattern := pattern.(type) {
+	case CapturePatternNode:
+		if pattern.Identifier.Token.Literal != "_" {
+			captures = append(captures, pattern.Identifier)
+		}
+	case ListMatchPatternNode:
+		for _, elem := range pattern.Elements {
+			captures = append(captures, Captures(elem)...)
+		}
+		if pattern.Rest != nil && pattern.Rest.(IdentifierNode).Token.Literal != "_" {
+			captures = append(captures, pattern.Rest.(IdentifierNode))
+		}
+	case MapMatchPatternNode:
+		for _, value := range pattern.Values {
+			captures = append(captures, Captures(value)...)
+		}
+	}
+	return captures
+}
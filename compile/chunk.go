@@ -0,0 +1,125 @@
+package compile
+
+import (
+	"encoding/binary"
+	"math/big"
+	"sort"
+
+	"github.com/shreerangdixit/yeti/ast"
+)
+
+// Function is a compiled function body along with the metadata needed to call it
+type Function struct {
+	Name      string
+	Arity     int
+	ScopeSize int
+	Chunk     *Chunk
+}
+
+// DecimalConstant is a decimal literal in a constant pool
+type DecimalConstant struct {
+	Value *big.Rat
+	Scale int
+}
+
+func (d DecimalConstant) String() string { return d.Value.FloatString(d.Scale) }
+
+// StructConstant is a struct declaration in a constant pool
+type StructConstant struct {
+	Name   string
+	Fields []string
+}
+
+func (s StructConstant) String() string { return "<struct " + s.Name + ">" }
+
+// Chunk is a sequence of bytecode instructions with a constant pool
+// Constants are one of int64, float64, *big.Int, DecimalConstant, StructConstant, string or *Function
+type Chunk struct {
+	Code      []byte
+	Constants []interface{}
+	spans     []span
+	ints      map[int64]int
+	numbers   map[float64]int
+	strings   map[string]int
+}
+
+// span maps an instruction offset to the AST node it was generated from
+type span struct {
+	offset int
+	node   ast.Node
+}
+
+func NewChunk() *Chunk {
+	return &Chunk{
+		Code:      make([]byte, 0, 256),
+		Constants: make([]interface{}, 0, 16),
+		spans:     make([]span, 0, 64),
+		ints:      make(map[int64]int),
+		numbers:   make(map[float64]int),
+		strings:   make(map[string]int),
+	}
+}
+
+// Node returns the AST node that generated the instruction at the given offset
+func (c *Chunk) Node(offset int) ast.Node {
+	i := sort.Search(len(c.spans), func(i int) bool { return c.spans[i].offset > offset })
+	if i == 0 {
+		return nil
+	}
+	return c.spans[i-1].node
+}
+
+// ReadUint16 reads a 2 byte operand at the given offset
+func (c *Chunk) ReadUint16(offset int) int {
+	return int(binary.BigEndian.Uint16(c.Code[offset:]))
+}
+
+func (c *Chunk) write(node ast.Node, op Opcode, operands ...int) int {
+	offset := len(c.Code)
+	c.spans = append(c.spans, span{offset: offset, node: node})
+
+	c.Code = append(c.Code, byte(op))
+	for i, w := range operandWidths[op] {
+		switch w {
+		case 1:
+			c.Code = append(c.Code, byte(operands[i]))
+		case 2:
+			c.Code = append(c.Code, 0, 0)
+			binary.BigEndian.PutUint16(c.Code[len(c.Code)-2:], uint16(operands[i]))
+		}
+	}
+	return offset
+}
+
+func (c *Chunk) patchUint16(offset int, value int) {
+	binary.BigEndian.PutUint16(c.Code[offset:], uint16(value))
+}
+
+func (c *Chunk) addInt(n int64) int {
+	if idx, ok := c.ints[n]; ok {
+		return idx
+	}
+	c.ints[n] = c.addConstant(n)
+	return c.ints[n]
+}
+
+func (c *Chunk) addNumber(n float64) int {
+	if idx, ok := c.numbers[n]; ok {
+		return idx
+	}
+	c.numbers[n] = c.addConstant(n)
+	return c.numbers[n]
+}
+
+func (c *Chunk) addString(s string) int {
+	if idx, ok := c.strings[s]; ok {
+		return idx
+	}
+	c.strings[s] = c.addConstant(s)
+	return c.strings[s]
+}
+
+func (c *Chunk) addConstant(value interface{}) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
@@ -0,0 +1,1268 @@
+package compile
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/lex"
+)
+
+// Compiler lowers an AST into bytecode
+//
+// Programs must be resolved by the Resolver before they are compiled: variables
+// bound to a (depth, slot) pair are accessed as locals, all others as globals by name.
+// The compiler creates the same scopes the resolver does and only tracks how many
+// slots each of them needs.
+type Compiler struct {
+	fn         *Function
+	scope      *scope
+	loop       *loop
+	try        *tryBlock
+	inFunction bool
+}
+
+type scope struct {
+	size   int
+	parent *scope
+}
+
+type loop struct {
+	scope  *scope
+	start  int
+	breaks []int
+	parent *loop
+}
+
+// tryBlock is a part of a try statement which break, continue and return have to clean up after
+// when they jump out of it: handler is set while an OP_TRY handler is installed, finally is the
+// finally block to run and pending is set while an error to rethrow is on the stack
+type tryBlock struct {
+	scope   *scope
+	loop    *loop
+	handler bool
+	finally ast.Node
+	pending bool
+	parent  *tryBlock
+}
+
+func New() *Compiler {
+	return &Compiler{}
+}
+
+// Compile compiles a program or a single expression into a function that takes no arguments
+// Programs return nil, expressions return their value
+func (c *Compiler) Compile(root ast.Node) (*Function, error) {
+	c.fn = &Function{
+		Name:  "script",
+		Chunk: NewChunk(),
+	}
+
+	if program, ok := root.(ast.ProgramNode); ok {
+		for _, decl := range program.Declarations {
+			if err := c.declaration(decl); err != nil {
+				return nil, err
+			}
+		}
+		c.emit(program, OP_NIL)
+	} else if isStatement(root) {
+		if err := c.declaration(root); err != nil {
+			return nil, err
+		}
+		c.emit(root, OP_NIL)
+	} else {
+		if err := c.expression(root); err != nil {
+			return nil, err
+		}
+	}
+	c.emit(root, OP_RETURN)
+	return c.fn, nil
+}
+
+// ------------------------------------
+// Declarations + Statements
+// ------------------------------------
+
+func (c *Compiler) declaration(node ast.Node) error {
+	switch node := node.(type) {
+	case ast.VarStmtNode:
+		return c.varStmt(node)
+	case ast.MultiVarStmtNode:
+		return c.multiVarStmt(node)
+	case ast.DestructureVarStmtNode:
+		return c.destructureVarStmt(node)
+	case ast.StructStmtNode:
+		return c.structStmt(node)
+	case ast.ClassStmtNode:
+		return c.classStmt(node)
+	case ast.ExpStmtNode:
+		if err := c.expression(node.Exp); err != nil {
+			return err
+		}
+		c.emit(node, OP_POP)
+		return nil
+	case ast.BlockNode:
+		return c.block(node)
+	case ast.IfStmtNode:
+		return c.ifStmt(node)
+	case ast.WhileStmtNode:
+		return c.whileStmt(node)
+	case ast.DoWhileStmtNode:
+		return c.doWhileStmt(node)
+	case ast.ForStmtNode:
+		return c.forStmt(node)
+	case ast.ForInStmtNode:
+		return c.forInStmt(node)
+	case ast.BreakStmtNode:
+		return c.breakStmt(node)
+	case ast.ContinueStmtNode:
+		return c.continueStmt(node)
+	case ast.ReturnStmtNode:
+		return c.returnStmt(node)
+	case ast.DeferStmtNode:
+		return c.deferStmt(node)
+	case ast.AssertStmtNode:
+		if err := c.expression(node.Exp); err != nil {
+			return err
+		}
+		c.emit(node, OP_ASSERT)
+		return nil
+	case ast.ThrowStmtNode:
+		if err := c.expression(node.Exp); err != nil {
+			return err
+		}
+		c.emit(node, OP_THROW)
+		return nil
+	case ast.TryStmtNode:
+		return c.tryStmt(node)
+	case ast.ImportStmtNode:
+		c.emit(node, OP_IMPORT, c.fn.Chunk.addString(node.Name.Token.Literal))
+		return nil
+	default:
+		if err := c.expression(node); err != nil {
+			return err
+		}
+		c.emit(node, OP_POP)
+		return nil
+	}
+}
+
+func (c *Compiler) varStmt(node ast.VarStmtNode) error {
+	if err := c.expression(node.Value); err != nil {
+		return err
+	}
+	return c.define(node.Identifier)
+}
+
+// multiVarStmt unpacks the value onto the stack with the first element on top
+func (c *Compiler) multiVarStmt(node ast.MultiVarStmtNode) error {
+	if err := c.expression(node.Value); err != nil {
+		return err
+	}
+
+	c.emit(node, OP_UNPACK, len(node.Identifiers))
+	for _, identifier := range node.Identifiers {
+		if err := c.define(identifier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// destructureVarStmt unpacks list patterns like multiVarStmt, the rest of the elements are
+// unpacked below the others. Map patterns look up each key in a copy of the map.
+func (c *Compiler) destructureVarStmt(node ast.DestructureVarStmtNode) error {
+	if err := c.expression(node.Value); err != nil {
+		return err
+	}
+
+	switch pattern := node.Pattern.(type) {
+	case ast.ListPatternNode:
+		op := OP_UNPACK
+		if pattern.Rest != nil {
+			op = OP_UNPACK_REST
+		}
+		if err := c.emitCount(node, op, len(pattern.Elements), math.MaxUint16); err != nil {
+			return err
+		}
+	case ast.MapPatternNode:
+		for _, key := range pattern.Keys {
+			c.emit(node, OP_DUP)
+			c.emit(node, OP_GET_KEY, c.fn.Chunk.addString(key.Token.Literal))
+			if err := c.define(key); err != nil {
+				return err
+			}
+		}
+		c.emit(node, OP_POP)
+		return nil
+	}
+
+	for _, identifier := range node.Pattern.Identifiers() {
+		if err := c.define(identifier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) structStmt(node ast.StructStmtNode) error {
+	fields := make([]string, 0, len(node.Fields))
+	for _, field := range node.Fields {
+		fields = append(fields, field.Token.Literal)
+	}
+
+	name := node.Identifier.Token.Literal
+	c.emit(node, OP_STRUCT, c.fn.Chunk.addConstant(StructConstant{Name: name, Fields: fields}))
+	return c.define(node.Identifier)
+}
+
+// classStmt compiles the methods of a class as closures and collects them with OP_CLASS
+//
+// When the class has a superclass, the methods are closed over a scope that binds it to "super".
+// The scope holding "this" only exists at compile time, the VM creates it whenever a method is bound
+// to an instance.
+func (c *Compiler) classStmt(node ast.ClassStmtNode) error {
+	local := node.Identifier.Binding.Local
+	if local {
+		if err := c.declare(node.Identifier); err != nil {
+			return err
+		}
+	}
+
+	if node.Superclass != nil {
+		if err := c.expression(node.Superclass); err != nil {
+			return err
+		}
+		c.emit(node, OP_DUP)
+		c.emit(node, OP_PUSH_SCOPE, 1)
+		c.beginScope()
+		c.scope.size = 1
+		c.emit(node, OP_DEFINE_LOCAL, 0)
+	} else {
+		c.emit(node, OP_NIL)
+	}
+
+	c.beginScope()
+	c.scope.size = 1
+	for _, method := range node.Methods {
+		if err := c.closure(method); err != nil {
+			return err
+		}
+	}
+	c.endScope()
+
+	name := c.fn.Chunk.addString(node.Identifier.Token.Literal)
+	if node.Superclass != nil {
+		c.emit(node.Superclass, OP_CLASS, name, len(node.Methods))
+		c.emit(node, OP_POP_SCOPE)
+		c.endScope()
+	} else {
+		c.emit(node, OP_CLASS, name, len(node.Methods))
+	}
+
+	if local {
+		c.emit(node, OP_DEFINE_LOCAL, node.Identifier.Binding.Slot)
+	} else {
+		c.emit(node.Identifier, OP_DEFINE_GLOBAL, name)
+	}
+	return nil
+}
+
+func (c *Compiler) block(node ast.BlockNode) error {
+	push := c.emit(node, OP_PUSH_SCOPE, 0)
+	c.beginScope()
+
+	for _, decl := range node.Declarations {
+		if err := c.declaration(decl); err != nil {
+			return err
+		}
+	}
+
+	c.fn.Chunk.patchUint16(push+1, c.scope.size)
+	c.endScope()
+	c.emit(node, OP_POP_SCOPE)
+	return nil
+}
+
+func (c *Compiler) ifStmt(node ast.IfStmtNode) error {
+	if err := c.expression(node.Exp); err != nil {
+		return err
+	}
+
+	elseJump := c.emit(node, OP_JUMP_IF_FALSE, 0)
+	if err := c.declaration(node.TrueStmt); err != nil {
+		return err
+	}
+
+	if node.FalseStmt == nil {
+		return c.patchJump(node, elseJump)
+	}
+
+	endJump := c.emit(node, OP_JUMP, 0)
+	if err := c.patchJump(node, elseJump); err != nil {
+		return err
+	}
+
+	if err := c.declaration(node.FalseStmt); err != nil {
+		return err
+	}
+	return c.patchJump(node, endJump)
+}
+
+func (c *Compiler) whileStmt(node ast.WhileStmtNode) error {
+	c.loop = &loop{
+		scope:  c.scope,
+		start:  len(c.fn.Chunk.Code),
+		parent: c.loop,
+	}
+	defer func() {
+		c.loop = c.loop.parent
+	}()
+
+	if err := c.expression(node.Condition); err != nil {
+		return err
+	}
+
+	exitJump := c.emit(node, OP_JUMP_IF_FALSE, 0)
+	if err := c.declaration(node.Body); err != nil {
+		return err
+	}
+
+	if err := c.emitLoop(node, c.loop.start); err != nil {
+		return err
+	}
+
+	return c.patchLoopExits(node, exitJump)
+}
+
+// doWhileStmt jumps over the condition on entry so that the condition is the target of 'continue'
+func (c *Compiler) doWhileStmt(node ast.DoWhileStmtNode) error {
+	bodyJump := c.emit(node, OP_JUMP, 0)
+
+	c.loop = &loop{
+		scope:  c.scope,
+		start:  len(c.fn.Chunk.Code),
+		parent: c.loop,
+	}
+	defer func() {
+		c.loop = c.loop.parent
+	}()
+
+	if err := c.expression(node.Condition); err != nil {
+		return err
+	}
+
+	exitJump := c.emit(node, OP_JUMP_IF_FALSE, 0)
+	if err := c.patchJump(node, bodyJump); err != nil {
+		return err
+	}
+
+	if err := c.declaration(node.Body); err != nil {
+		return err
+	}
+
+	if err := c.emitLoop(node, c.loop.start); err != nil {
+		return err
+	}
+	return c.patchLoopExits(node, exitJump)
+}
+
+// forStmt compiles the update clause ahead of the condition, skipping it on entry,
+// so that it's the target of 'continue'
+// Variables declared by the initializer live in a scope which spans the whole loop
+func (c *Compiler) forStmt(node ast.ForStmtNode) error {
+	push := c.emit(node, OP_PUSH_SCOPE, 0)
+	c.beginScope()
+
+	if node.Init != nil {
+		if err := c.declaration(node.Init); err != nil {
+			return err
+		}
+	}
+	conditionJump := c.emit(node, OP_JUMP, 0)
+
+	c.loop = &loop{
+		scope:  c.scope,
+		start:  len(c.fn.Chunk.Code),
+		parent: c.loop,
+	}
+	defer func() {
+		c.loop = c.loop.parent
+	}()
+
+	if node.Update != nil {
+		if err := c.expression(node.Update); err != nil {
+			return err
+		}
+		c.emit(node, OP_POP)
+	}
+
+	if err := c.patchJump(node, conditionJump); err != nil {
+		return err
+	}
+
+	if node.Condition != nil {
+		if err := c.expression(node.Condition); err != nil {
+			return err
+		}
+	} else {
+		c.emit(node, OP_TRUE)
+	}
+
+	exitJump := c.emit(node, OP_JUMP_IF_FALSE, 0)
+	if err := c.declaration(node.Body); err != nil {
+		return err
+	}
+
+	if err := c.emitLoop(node, c.loop.start); err != nil {
+		return err
+	}
+
+	if err := c.patchLoopExits(node, exitJump); err != nil {
+		return err
+	}
+
+	c.fn.Chunk.patchUint16(push+1, c.scope.size)
+	c.endScope()
+	c.emit(node, OP_POP_SCOPE)
+	return nil
+}
+
+// forInStmt keeps the iterator on the stack for the duration of the loop
+// Loop variables are bound in a scope which is pushed on every iteration
+func (c *Compiler) forInStmt(node ast.ForInStmtNode) error {
+	if err := c.expression(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(node.Iterable, OP_ITER)
+
+	c.loop = &loop{
+		scope:  c.scope,
+		start:  len(c.fn.Chunk.Code),
+		parent: c.loop,
+	}
+	defer func() {
+		c.loop = c.loop.parent
+	}()
+
+	exitJump := c.emit(node, OP_ITER_NEXT, 0, len(node.Variables))
+	c.emit(node, OP_PUSH_SCOPE, len(node.Variables))
+	c.beginScope()
+
+	for _, variable := range node.Variables {
+		if err := c.declare(variable); err != nil {
+			return err
+		}
+	}
+	for i := len(node.Variables) - 1; i >= 0; i-- {
+		c.emit(node.Variables[i], OP_DEFINE_LOCAL, node.Variables[i].Binding.Slot)
+	}
+
+	if err := c.declaration(node.Body); err != nil {
+		return err
+	}
+
+	c.endScope()
+	c.emit(node, OP_POP_SCOPE)
+	if err := c.emitLoop(node, c.loop.start); err != nil {
+		return err
+	}
+
+	if err := c.patchLoopExits(node, exitJump); err != nil {
+		return err
+	}
+	c.emit(node, OP_POP)
+	return nil
+}
+
+func (c *Compiler) breakStmt(node ast.BreakStmtNode) error {
+	if c.loop == nil {
+		return NewCompileError("'break' outside of a loop", node)
+	}
+
+	scope := c.scope
+	defer func() { c.scope = scope }()
+	if err := c.exitTries(node, true); err != nil {
+		return err
+	}
+
+	c.popScopesTo(node, c.loop.scope)
+	c.loop.breaks = append(c.loop.breaks, c.emit(node, OP_JUMP, 0))
+	return nil
+}
+
+func (c *Compiler) continueStmt(node ast.ContinueStmtNode) error {
+	if c.loop == nil {
+		return NewCompileError("'continue' outside of a loop", node)
+	}
+
+	scope := c.scope
+	defer func() { c.scope = scope }()
+	if err := c.exitTries(node, true); err != nil {
+		return err
+	}
+
+	c.popScopesTo(node, c.loop.scope)
+	return c.emitLoop(node, c.loop.start)
+}
+
+func (c *Compiler) returnStmt(node ast.ReturnStmtNode) error {
+	if !c.inFunction {
+		return NewCompileError("'return' outside of a function", node)
+	}
+
+	if node.Exp == nil {
+		c.emit(node, OP_NIL)
+	} else if err := c.expression(node.Exp); err != nil {
+		return err
+	}
+
+	scope := c.scope
+	defer func() { c.scope = scope }()
+	if err := c.exitTries(node, false); err != nil {
+		return err
+	}
+
+	c.emit(node, OP_RETURN)
+	return nil
+}
+
+// tryStmt compiles the finally block on every path out of the try statement: after the body,
+// after the catch block, in a handler which rethrows errors escaping either of them, and before
+// every break, continue and return jumping out of them (see exitTries)
+func (c *Compiler) tryStmt(node ast.TryStmtNode) error {
+	handler := c.emit(node, OP_TRY, 0)
+	if node.Catch == nil {
+		c.fn.Chunk.Code[handler] = byte(OP_TRY_FINALLY)
+	}
+
+	if err := c.tryBlock(node, true, false, func() error { return c.block(node.Body) }); err != nil {
+		return err
+	}
+	c.emit(node, OP_END_TRY)
+	if err := c.finally(node); err != nil {
+		return err
+	}
+	exits := []int{c.emit(node, OP_JUMP, 0)}
+
+	if node.Catch != nil {
+		if err := c.patchJump(node, handler); err != nil {
+			return err
+		}
+
+		if node.Finally != nil {
+			handler = c.emit(node, OP_TRY_FINALLY, 0)
+		}
+		if err := c.tryBlock(node, node.Finally != nil, false, func() error { return c.catchClause(node) }); err != nil {
+			return err
+		}
+		if node.Finally != nil {
+			c.emit(node, OP_END_TRY)
+			if err := c.finally(node); err != nil {
+				return err
+			}
+			exits = append(exits, c.emit(node, OP_JUMP, 0))
+		}
+	}
+
+	if node.Finally != nil {
+		// The handler runs the finally block with the error to rethrow on the stack
+		if err := c.patchJump(node, handler); err != nil {
+			return err
+		}
+		if err := c.tryBlock(node, false, true, func() error { return c.finally(node) }); err != nil {
+			return err
+		}
+		c.emit(node, OP_RETHROW)
+	}
+
+	for _, exit := range exits {
+		if err := c.patchJump(node, exit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// catchClause binds the caught value on top of the stack in a scope enclosing the catch block
+func (c *Compiler) catchClause(node ast.TryStmtNode) error {
+	c.emit(node.Variable, OP_PUSH_SCOPE, 1)
+	c.beginScope()
+	defer c.endScope()
+
+	if err := c.define(node.Variable); err != nil {
+		return err
+	}
+
+	if err := c.block(node.Catch.(ast.BlockNode)); err != nil {
+		return err
+	}
+	c.emit(node, OP_POP_SCOPE)
+	return nil
+}
+
+func (c *Compiler) finally(node ast.TryStmtNode) error {
+	if node.Finally == nil {
+		return nil
+	}
+	return c.block(node.Finally.(ast.BlockNode))
+}
+
+// tryBlock compiles a part of a try statement with the state break, continue and return need to exit it
+func (c *Compiler) tryBlock(node ast.TryStmtNode, handler bool, pending bool, compile func() error) error {
+	finally := node.Finally
+	if pending {
+		finally = nil
+	}
+
+	c.try = &tryBlock{
+		scope:   c.scope,
+		loop:    c.loop,
+		handler: handler,
+		finally: finally,
+		pending: pending,
+		parent:  c.try,
+	}
+	defer func() { c.try = c.try.parent }()
+	return compile()
+}
+
+// exitTries cleans up after the try statements that a jump leaves, innermost first: handlers
+// are removed and finally blocks are run in the scope of their try statement. Breaks and continues
+// only leave the try statements inside their loop and also pop errors waiting to be rethrown,
+// returns leave every try statement of the function and discard the stack anyway.
+// The scope is left at the scope of the outermost try statement left.
+func (c *Compiler) exitTries(node ast.Node, loop bool) error {
+	try := c.try
+	defer func() { c.try = try }()
+
+	for t := try; t != nil && (!loop || t.loop == c.loop); t = t.parent {
+		c.popScopesTo(node, t.scope)
+		c.scope = t.scope
+		c.try = t.parent
+
+		if t.handler {
+			c.emit(node, OP_END_TRY)
+		}
+		if t.pending && loop {
+			c.emit(node, OP_POP)
+		}
+		if t.finally != nil {
+			if err := c.block(t.finally.(ast.BlockNode)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deferStmt compiles the deferred call into a closure which OP_RETURN runs when the enclosing function
+// returns, errors unwinding the function run it too
+func (c *Compiler) deferStmt(node ast.DeferStmtNode) error {
+	fc := c.enclose("defer", 0)
+	if err := fc.expression(node.Call); err != nil {
+		return err
+	}
+	fc.emit(node, OP_POP)
+	fc.emit(node, OP_NIL)
+	fc.emit(node, OP_RETURN)
+	fc.fn.ScopeSize = fc.scope.size
+
+	c.emit(node, OP_CLOSURE, c.fn.Chunk.addConstant(fc.fn))
+	c.emit(node, OP_DEFER)
+	return nil
+}
+
+// ------------------------------------
+// Expressions
+// ------------------------------------
+
+func (c *Compiler) expression(node ast.Node) error {
+	switch node := node.(type) {
+	case ast.NumberNode:
+		return c.number(node)
+	case ast.StringNode:
+		c.emit(node, OP_CONSTANT, c.fn.Chunk.addString(node.Token.Literal))
+	case ast.BooleanNode:
+		if node.Token.Type == lex.TT_TRUE {
+			c.emit(node, OP_TRUE)
+		} else {
+			c.emit(node, OP_FALSE)
+		}
+	case ast.NilNode, ast.CommentNode:
+		c.emit(node, OP_NIL)
+	case ast.ExpNode:
+		return c.expression(node.Exp)
+	case ast.IdentifierNode:
+		if node.Binding.Local {
+			c.emit(node, OP_GET_LOCAL, node.Binding.Depth, node.Binding.Slot)
+		} else {
+			c.emit(node, OP_GET_GLOBAL, c.fn.Chunk.addString(node.Token.Literal))
+		}
+	case ast.AssignmentNode:
+		return c.assignment(node)
+	case ast.DestructureAssignmentNode:
+		return c.destructureAssignment(node)
+	case ast.TernaryOpNode:
+		return c.ternaryOp(node)
+	case ast.MatchNode:
+		return c.match(node)
+	case ast.BinaryOpNode:
+		return c.binaryOp(node)
+	case ast.UnaryOpNode:
+		return c.unaryOp(node)
+	case ast.LogicalAndNode:
+		if err := c.expression(node.LHS); err != nil {
+			return err
+		}
+		if err := c.expression(node.RHS); err != nil {
+			return err
+		}
+		c.emit(node, OP_AND)
+	case ast.LogicalOrNode:
+		return c.logicalOr(node)
+	case ast.InterpolatedStringNode:
+		if err := c.expressions(node.Segments); err != nil {
+			return err
+		}
+		return c.emitCount(node, OP_INTERPOLATE, len(node.Segments), math.MaxUint16)
+	case ast.ListNode:
+		if err := c.expressions(node.Elements); err != nil {
+			return err
+		}
+		return c.emitCount(node, OP_LIST, len(node.Elements), math.MaxUint16)
+	case ast.TupleNode:
+		if err := c.expressions(node.Elements); err != nil {
+			return err
+		}
+		return c.emitCount(node, OP_TUPLE, len(node.Elements), math.MaxUint16)
+	case ast.MapNode:
+		for _, kvp := range node.Elements {
+			if err := c.expression(kvp.Key); err != nil {
+				return err
+			}
+			if err := c.expression(kvp.Value); err != nil {
+				return err
+			}
+		}
+		return c.emitCount(node, OP_MAP, len(node.Elements), math.MaxUint16)
+	case ast.SetNode:
+		if err := c.expressions(node.Elements); err != nil {
+			return err
+		}
+		return c.emitCount(node, OP_SET, len(node.Elements), math.MaxUint16)
+	case ast.CallNode:
+		if err := c.expression(node.Callee); err != nil {
+			return err
+		}
+		if err := c.expressions(node.Arguments); err != nil {
+			return err
+		}
+		return c.emitCount(node, OP_CALL, len(node.Arguments), math.MaxUint8)
+	case ast.IndexOfNode:
+		if err := c.expression(node.Sequence); err != nil {
+			return err
+		}
+		if err := c.expression(node.Index); err != nil {
+			return err
+		}
+		c.emit(node.Index, OP_INDEX)
+	case ast.IndexAssignmentNode:
+		return c.indexAssignment(node)
+	case ast.AttrAssignmentNode:
+		return c.attrAssignment(node)
+	case ast.SuperNode:
+		if err := c.expressions([]ast.Node{node.Super, node.This}); err != nil {
+			return err
+		}
+		c.emit(node.Method, OP_GET_SUPER, c.fn.Chunk.addString(node.Method.Token.Literal))
+	case ast.GetAttrNode:
+		if err := c.expression(node.Object); err != nil {
+			return err
+		}
+		c.emit(node.Name, OP_GET_ATTR, c.fn.Chunk.addString(node.Name.Token.Literal))
+	case ast.SliceNode:
+		if err := c.expression(node.Sequence); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Node{node.Start, node.Stop, node.Step} {
+			if bound == nil {
+				c.emit(node, OP_NIL)
+			} else if err := c.expression(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(node, OP_SLICE)
+	case ast.FunctionNode:
+		return c.function(node)
+	default:
+		return NewCompileError(fmt.Sprintf("invalid node: %T", node), node)
+	}
+	return nil
+}
+
+func (c *Compiler) number(node ast.NumberNode) error {
+	literal := node.Token.Literal
+	switch lex.NumberKindOf(literal) {
+	case lex.NUM_INT:
+		val, err := lex.ParseInt(literal)
+		if err != nil {
+			return NewCompileError(err.Error(), node)
+		}
+		c.emit(node, OP_CONSTANT, c.fn.Chunk.addInt(val))
+		return nil
+	case lex.NUM_BIGINT:
+		val, err := lex.ParseBigInt(literal)
+		if err != nil {
+			return NewCompileError(err.Error(), node)
+		}
+		c.emit(node, OP_CONSTANT, c.fn.Chunk.addConstant(val))
+		return nil
+	case lex.NUM_DECIMAL:
+		val, scale, err := lex.ParseDecimal(literal)
+		if err != nil {
+			return NewCompileError(err.Error(), node)
+		}
+		c.emit(node, OP_CONSTANT, c.fn.Chunk.addConstant(DecimalConstant{Value: val, Scale: scale}))
+		return nil
+	}
+
+	val, err := lex.ParseFloat(literal)
+	if err != nil {
+		return NewCompileError(err.Error(), node)
+	}
+	c.emit(node, OP_CONSTANT, c.fn.Chunk.addNumber(val))
+	return nil
+}
+
+func (c *Compiler) expressions(nodes []ast.Node) error {
+	for _, node := range nodes {
+		if err := c.expression(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignment evaluates to nil, matching the tree-walking evaluator
+func (c *Compiler) assignment(node ast.AssignmentNode) error {
+	if _, ok := lex.CompoundAssignmentOp(node.Op.Type); ok {
+		if err := c.expression(node.Identifier); err != nil {
+			return err
+		}
+	}
+
+	if err := c.assignedValue(node, node.Op, node.Value); err != nil {
+		return err
+	}
+
+	c.set(node, node.Identifier)
+	c.emit(node, OP_NIL)
+	return nil
+}
+
+func (c *Compiler) destructureAssignment(node ast.DestructureAssignmentNode) error {
+	if err := c.expression(node.Value); err != nil {
+		return err
+	}
+
+	if err := c.emitCount(node, OP_UNPACK, len(node.Pattern.Elements), math.MaxUint16); err != nil {
+		return err
+	}
+	for _, identifier := range node.Pattern.Elements {
+		c.set(node, identifier)
+	}
+	c.emit(node, OP_NIL)
+	return nil
+}
+
+// set assigns the value on top of the stack to a variable
+func (c *Compiler) set(node ast.Node, identifier ast.IdentifierNode) {
+	if identifier.Binding.Local {
+		c.emit(node, OP_SET_LOCAL, identifier.Binding.Depth, identifier.Binding.Slot)
+	} else {
+		c.emit(node, OP_SET_GLOBAL, c.fn.Chunk.addString(identifier.Token.Literal))
+	}
+}
+
+// attrAssignment duplicates the object for compound assignments so that it's evaluated only once
+func (c *Compiler) attrAssignment(node ast.AttrAssignmentNode) error {
+	if err := c.expression(node.Target.Object); err != nil {
+		return err
+	}
+
+	name := c.fn.Chunk.addString(node.Target.Name.Token.Literal)
+	if _, ok := lex.CompoundAssignmentOp(node.Op.Type); ok {
+		c.emit(node, OP_DUP)
+		c.emit(node.Target.Name, OP_GET_ATTR, name)
+	}
+
+	if err := c.assignedValue(node, node.Op, node.Value); err != nil {
+		return err
+	}
+	c.emit(node.Target.Name, OP_SET_ATTR, name)
+	c.emit(node, OP_NIL)
+	return nil
+}
+
+// indexAssignment duplicates the collection and the index for compound assignments so
+// that they're evaluated only once
+func (c *Compiler) indexAssignment(node ast.IndexAssignmentNode) error {
+	if err := c.expressions([]ast.Node{node.Target.Sequence, node.Target.Index}); err != nil {
+		return err
+	}
+
+	if _, ok := lex.CompoundAssignmentOp(node.Op.Type); ok {
+		c.emit(node, OP_DUP2)
+		c.emit(node.Target.Index, OP_INDEX)
+	}
+
+	if err := c.assignedValue(node, node.Op, node.Value); err != nil {
+		return err
+	}
+	c.emit(node.Target.Index, OP_SET_INDEX)
+	c.emit(node, OP_NIL)
+	return nil
+}
+
+// assignedValue compiles the value of an assignment, compound assignments combine it with the
+// current value of the target which must already be on the stack
+func (c *Compiler) assignedValue(node ast.Node, op lex.Token, value ast.Node) error {
+	if err := c.expression(value); err != nil {
+		return err
+	}
+
+	if binOp, ok := lex.CompoundAssignmentOp(op.Type); ok {
+		return c.emitBinaryOp(node, binOp)
+	}
+	return nil
+}
+
+func (c *Compiler) ternaryOp(node ast.TernaryOpNode) error {
+	if err := c.expression(node.Exp); err != nil {
+		return err
+	}
+
+	elseJump := c.emit(node, OP_JUMP_IF_FALSE, 0)
+	if err := c.expression(node.TrueExp); err != nil {
+		return err
+	}
+
+	endJump := c.emit(node, OP_JUMP, 0)
+	if err := c.patchJump(node, elseJump); err != nil {
+		return err
+	}
+
+	if err := c.expression(node.FalseExp); err != nil {
+		return err
+	}
+	return c.patchJump(node, endJump)
+}
+
+// match keeps the value on the stack while the arms are tried in order
+func (c *Compiler) match(node ast.MatchNode) error {
+	if err := c.expression(node.Value); err != nil {
+		return err
+	}
+
+	exits := make([]int, 0, len(node.Arms))
+	for _, arm := range node.Arms {
+		exit, err := c.matchArm(arm)
+		if err != nil {
+			return err
+		}
+		exits = append(exits, exit)
+	}
+	c.emit(node, OP_NO_MATCH)
+
+	for _, exit := range exits {
+		if err := c.patchJump(node, exit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchArm compiles an arm in a scope holding the captures of its pattern, which OP_MATCH binds
+// when the pattern matches. It returns the jump to the end of the match taken after the body.
+func (c *Compiler) matchArm(node ast.MatchArmNode) (int, error) {
+	push := c.emit(node, OP_PUSH_SCOPE, 0)
+	c.beginScope()
+	defer c.endScope()
+
+	for _, capture := range ast.Captures(node.Pattern) {
+		if err := c.declare(capture); err != nil {
+			return 0, err
+		}
+	}
+
+	c.emit(node, OP_MATCH)
+	fails := []int{c.emit(node, OP_JUMP_IF_FALSE, 0)}
+	if node.Guard != nil {
+		if err := c.expression(node.Guard); err != nil {
+			return 0, err
+		}
+		fails = append(fails, c.emit(node, OP_JUMP_IF_FALSE, 0))
+	}
+
+	c.emit(node, OP_POP)
+	if err := c.expression(node.Body); err != nil {
+		return 0, err
+	}
+	c.fn.Chunk.patchUint16(push+1, c.scope.size)
+	c.emit(node, OP_POP_SCOPE)
+	exit := c.emit(node, OP_JUMP, 0)
+
+	for _, fail := range fails {
+		if err := c.patchJump(node, fail); err != nil {
+			return 0, err
+		}
+	}
+	c.emit(node, OP_POP_SCOPE)
+	return exit, nil
+}
+
+func (c *Compiler) binaryOp(node ast.BinaryOpNode) error {
+	if err := c.expression(node.LeftExp); err != nil {
+		return err
+	}
+
+	if err := c.expression(node.RightExp); err != nil {
+		return err
+	}
+	return c.emitBinaryOp(node, node.Op.Type)
+}
+
+func (c *Compiler) emitBinaryOp(node ast.Node, op lex.TokenType) error {
+	switch op {
+	case lex.TT_PLUS:
+		c.emit(node, OP_ADD)
+	case lex.TT_MINUS:
+		c.emit(node, OP_SUBTRACT)
+	case lex.TT_DIVIDE:
+		c.emit(node, OP_DIVIDE)
+	case lex.TT_MULTIPLY:
+		c.emit(node, OP_MULTIPLY)
+	case lex.TT_MODULO:
+		c.emit(node, OP_MODULO)
+	case lex.TT_EQ:
+		c.emit(node, OP_EQ)
+	case lex.TT_NEQ:
+		c.emit(node, OP_NEQ)
+	case lex.TT_LT:
+		c.emit(node, OP_LT)
+	case lex.TT_LTE:
+		c.emit(node, OP_LTE)
+	case lex.TT_GT:
+		c.emit(node, OP_GT)
+	case lex.TT_GTE:
+		c.emit(node, OP_GTE)
+	case lex.TT_IN:
+		c.emit(node, OP_IN)
+	case lex.TT_NOT_IN:
+		c.emit(node, OP_IN)
+		c.emit(node, OP_NOT)
+	default:
+		return NewCompileError(fmt.Sprintf("invalid binary op: %s", op), node)
+	}
+	return nil
+}
+
+func (c *Compiler) unaryOp(node ast.UnaryOpNode) error {
+	if err := c.expression(node.Operand); err != nil {
+		return err
+	}
+
+	switch node.Op.Type {
+	case lex.TT_MINUS:
+		c.emit(node, OP_NEGATE)
+	case lex.TT_NOT:
+		c.emit(node, OP_NOT)
+	default:
+		return NewCompileError(fmt.Sprintf("invalid unary op: %s", node.Op.Type), node)
+	}
+	return nil
+}
+
+func (c *Compiler) logicalOr(node ast.LogicalOrNode) error {
+	if err := c.expression(node.LHS); err != nil {
+		return err
+	}
+
+	rhsJump := c.emit(node, OP_JUMP_IF_FALSE, 0)
+	c.emit(node, OP_TRUE)
+	endJump := c.emit(node, OP_JUMP, 0)
+	if err := c.patchJump(node, rhsJump); err != nil {
+		return err
+	}
+
+	if err := c.expression(node.RHS); err != nil {
+		return err
+	}
+	c.emit(node, OP_TRUTHY)
+	return c.patchJump(node, endJump)
+}
+
+// function compiles a function declaration and binds it to its name in the current scope
+// The name is declared before the body is compiled so that functions can recurse
+func (c *Compiler) function(node ast.FunctionNode) error {
+	local := node.Identifier.Binding.Local
+	if local {
+		if err := c.declare(node.Identifier); err != nil {
+			return err
+		}
+	}
+
+	if err := c.closure(node); err != nil {
+		return err
+	}
+	c.emit(node, OP_DUP)
+	if local {
+		c.emit(node, OP_DEFINE_LOCAL, node.Identifier.Binding.Slot)
+	} else {
+		c.emit(node.Identifier, OP_DEFINE_GLOBAL, c.fn.Chunk.addString(node.Identifier.Token.Literal))
+	}
+	return nil
+}
+
+// closure compiles a function body and emits OP_CLOSURE to push it
+func (c *Compiler) closure(node ast.FunctionNode) error {
+	fc := c.enclose(node.Identifier.Token.Literal, len(node.Parameters))
+	for _, param := range node.Parameters {
+		if err := fc.declare(param); err != nil {
+			return err
+		}
+	}
+
+	for _, decl := range node.Body.Declarations {
+		if err := fc.declaration(decl); err != nil {
+			return err
+		}
+	}
+	fc.emit(node.Body, OP_NIL)
+	fc.emit(node.Body, OP_RETURN)
+	fc.fn.ScopeSize = fc.scope.size
+
+	c.emit(node, OP_CLOSURE, c.fn.Chunk.addConstant(fc.fn))
+	return nil
+}
+
+// ------------------------------------
+// Helpers
+// ------------------------------------
+
+// enclose creates a compiler for a function nested in the current scope
+func (c *Compiler) enclose(name string, arity int) *Compiler {
+	return &Compiler{
+		fn: &Function{
+			Name:  name,
+			Arity: arity,
+			Chunk: NewChunk(),
+		},
+		scope: &scope{
+			parent: c.scope,
+		},
+		inFunction: true,
+	}
+}
+
+func (c *Compiler) beginScope() {
+	c.scope = &scope{
+		parent: c.scope,
+	}
+}
+
+func (c *Compiler) endScope() {
+	c.scope = c.scope.parent
+}
+
+// define binds the value on top of the stack to a newly declared variable
+func (c *Compiler) define(identifier ast.IdentifierNode) error {
+	if !identifier.Binding.Local {
+		c.emit(identifier, OP_DEFINE_GLOBAL, c.fn.Chunk.addString(identifier.Token.Literal))
+		return nil
+	}
+
+	if err := c.declare(identifier); err != nil {
+		return err
+	}
+
+	c.emit(identifier, OP_DEFINE_LOCAL, identifier.Binding.Slot)
+	return nil
+}
+
+// declare reserves the slot the resolver bound a local variable to in the current scope
+func (c *Compiler) declare(identifier ast.IdentifierNode) error {
+	slot := identifier.Binding.Slot
+	if slot >= math.MaxUint16 {
+		return NewCompileError("too many variables in scope", identifier)
+	}
+
+	if slot >= c.scope.size {
+		c.scope.size = slot + 1
+	}
+	return nil
+}
+
+// popScopesTo emits instructions to exit all scopes up to (but excluding) the target scope
+func (c *Compiler) popScopesTo(node ast.Node, target *scope) {
+	for s := c.scope; s != target; s = s.parent {
+		c.emit(node, OP_POP_SCOPE)
+	}
+}
+
+func (c *Compiler) emit(node ast.Node, op Opcode, operands ...int) int {
+	return c.fn.Chunk.write(node, op, operands...)
+}
+
+func (c *Compiler) emitCount(node ast.Node, op Opcode, count int, max int) error {
+	if count > max {
+		return NewCompileError(fmt.Sprintf("too many elements: %d (max %d)", count, max), node)
+	}
+	c.emit(node, op, count)
+	return nil
+}
+
+func (c *Compiler) emitLoop(node ast.Node, start int) error {
+	offset := len(c.fn.Chunk.Code) + OP_LOOP.Size() - start
+	if offset > math.MaxUint16 {
+		return NewCompileError("loop body too large", node)
+	}
+	c.emit(node, OP_LOOP, offset)
+	return nil
+}
+
+// patchLoopExits points the exit jump and all breaks of the current loop to the current end of code
+func (c *Compiler) patchLoopExits(node ast.Node, exitJump int) error {
+	if err := c.patchJump(node, exitJump); err != nil {
+		return err
+	}
+
+	for _, jump := range c.loop.breaks {
+		if err := c.patchJump(node, jump); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchJump sets the target of the jump instruction at the given offset to the current end of code
+func (c *Compiler) patchJump(node ast.Node, jump int) error {
+	offset := len(c.fn.Chunk.Code) - jump - Opcode(c.fn.Chunk.Code[jump]).Size()
+	if offset > math.MaxUint16 {
+		return NewCompileError("too much code to jump over", node)
+	}
+	c.fn.Chunk.patchUint16(jump+1, offset)
+	return nil
+}
+
+func isStatement(node ast.Node) bool {
+	switch node.(type) {
+	case ast.VarStmtNode, ast.MultiVarStmtNode, ast.DestructureVarStmtNode, ast.ExpStmtNode, ast.BlockNode, ast.IfStmtNode, ast.WhileStmtNode,
+		ast.DoWhileStmtNode, ast.ForStmtNode, ast.ForInStmtNode, ast.BreakStmtNode, ast.ContinueStmtNode,
+		ast.ReturnStmtNode, ast.DeferStmtNode, ast.AssertStmtNode, ast.ImportStmtNode, ast.StructStmtNode,
+		ast.ClassStmtNode, ast.ThrowStmtNode, ast.TryStmtNode:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,416 @@
+package compile
+
+import (
+	"testing"
+
+	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/lex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompiler_Compile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "globals",
+			input: "var x = 1 + 2",
+			want: `== script ==
+0000 CONSTANT 0 (1)
+0003 CONSTANT 1 (2)
+0006 ADD
+0007 DEFINE_GLOBAL 2 (x)
+0010 NIL
+0011 RETURN
+`,
+		},
+		{
+			name:  "locals",
+			input: "{ var x = 1 { x = x } }",
+			want: `== script ==
+0000 PUSH_SCOPE 1
+0003 CONSTANT 0 (1)
+0006 DEFINE_LOCAL 0
+0009 PUSH_SCOPE 0
+0012 GET_LOCAL 1 0
+0017 SET_LOCAL 1 0
+0022 NIL
+0023 POP
+0024 POP_SCOPE
+0025 POP_SCOPE
+0026 NIL
+0027 RETURN
+`,
+		},
+		{
+			name:  "while_break",
+			input: "while (true) { break }",
+			want: `== script ==
+0000 TRUE
+0001 JUMP_IF_FALSE 11
+0004 PUSH_SCOPE 0
+0007 POP_SCOPE
+0008 JUMP 4
+0011 POP_SCOPE
+0012 LOOP 15
+0015 NIL
+0016 RETURN
+`,
+		},
+		{
+			name:  "index_assignment",
+			input: "l[0] = 2",
+			want: `== script ==
+0000 GET_GLOBAL 0 (l)
+0003 CONSTANT 1 (0)
+0006 CONSTANT 2 (2)
+0009 SET_INDEX
+0010 NIL
+0011 POP
+0012 NIL
+0013 RETURN
+`,
+		},
+		{
+			name:  "tuple",
+			input: "(1, 2)",
+			want: `== script ==
+0000 CONSTANT 0 (1)
+0003 CONSTANT 1 (2)
+0006 TUPLE 2
+0009 POP
+0010 NIL
+0011 RETURN
+`,
+		},
+		{
+			name:  "set_membership",
+			input: "1 in #{1, 2}",
+			want: `== script ==
+0000 CONSTANT 0 (1)
+0003 CONSTANT 0 (1)
+0006 CONSTANT 1 (2)
+0009 SET 2
+0012 IN
+0013 POP
+0014 NIL
+0015 RETURN
+`,
+		},
+		{
+			name:  "not_in",
+			input: "y not in l",
+			want: `== script ==
+0000 GET_GLOBAL 0 (y)
+0003 GET_GLOBAL 1 (l)
+0006 IN
+0007 NOT
+0008 POP
+0009 NIL
+0010 RETURN
+`,
+		},
+		{
+			name:  "method_call",
+			input: "xs.push(1)",
+			want: `== script ==
+0000 GET_GLOBAL 0 (xs)
+0003 GET_ATTR 1 (push)
+0006 CONSTANT 2 (1)
+0009 CALL 1
+0011 POP
+0012 NIL
+0013 RETURN
+`,
+		},
+		{
+			name:  "class_super",
+			input: "class A < B { f() { return super.f() } }",
+			want: `== script ==
+0000 GET_GLOBAL 0 (B)
+0003 DUP
+0004 PUSH_SCOPE 1
+0007 DEFINE_LOCAL 0
+0010 CLOSURE 1 (<fun-f>)
+0013 CLASS 2 1 (A)
+0018 POP_SCOPE
+0019 DEFINE_GLOBAL 2 (A)
+0022 NIL
+0023 RETURN
+== f ==
+0000 GET_LOCAL 2 0
+0005 GET_LOCAL 1 0
+0010 GET_SUPER 0 (f)
+0013 CALL 0
+0015 RETURN
+0016 NIL
+0017 RETURN
+`,
+		},
+		{
+			name:  "multiple_values",
+			input: "fun f() { return 1, 2 } { var a, b = f() }",
+			want: `== script ==
+0000 CLOSURE 0 (<fun-f>)
+0003 DUP
+0004 DEFINE_GLOBAL 1 (f)
+0007 POP
+0008 PUSH_SCOPE 2
+0011 GET_GLOBAL 1 (f)
+0014 CALL 0
+0016 UNPACK 2
+0019 DEFINE_LOCAL 0
+0022 DEFINE_LOCAL 1
+0025 POP_SCOPE
+0026 NIL
+0027 RETURN
+== f ==
+0000 CONSTANT 0 (1)
+0003 CONSTANT 1 (2)
+0006 TUPLE 2
+0009 RETURN
+0010 NIL
+0011 RETURN
+`,
+		},
+		{
+			name:  "destructuring",
+			input: "{ var [a, ...b] = [1] var {c} = {}\n[a, c] = [c, a] }",
+			want: `== script ==
+0000 PUSH_SCOPE 3
+0003 CONSTANT 0 (1)
+0006 LIST 1
+0009 UNPACK_REST 1
+0012 DEFINE_LOCAL 0
+0015 DEFINE_LOCAL 1
+0018 MAP 0
+0021 DUP
+0022 GET_KEY 1 (c)
+0025 DEFINE_LOCAL 2
+0028 POP
+0029 GET_LOCAL 0 2
+0034 GET_LOCAL 0 0
+0039 LIST 2
+0042 UNPACK 2
+0045 SET_LOCAL 0 0
+0050 SET_LOCAL 0 2
+0055 NIL
+0056 POP
+0057 POP_SCOPE
+0058 NIL
+0059 RETURN
+`,
+		},
+		{
+			name:  "match",
+			input: "var r = match ([1, 2]) { [a, b] if a > 1 => a, [a, _] => a + 10 }",
+			want: `== script ==
+0000 CONSTANT 0 (1)
+0003 CONSTANT 1 (2)
+0006 LIST 2
+0009 PUSH_SCOPE 2
+0012 MATCH
+0013 JUMP_IF_FALSE 22
+0016 GET_LOCAL 0 0
+0021 CONSTANT 0 (1)
+0024 GT
+0025 JUMP_IF_FALSE 10
+0028 POP
+0029 GET_LOCAL 0 0
+0034 POP_SCOPE
+0035 JUMP 24
+0038 POP_SCOPE
+0039 PUSH_SCOPE 1
+0042 MATCH
+0043 JUMP_IF_FALSE 14
+0046 POP
+0047 GET_LOCAL 0 0
+0052 CONSTANT 2 (10)
+0055 ADD
+0056 POP_SCOPE
+0057 JUMP 2
+0060 POP_SCOPE
+0061 NO_MATCH
+0062 DEFINE_GLOBAL 3 (r)
+0065 NIL
+0066 RETURN
+`,
+		},
+		{
+			name:  "try_catch_finally",
+			input: "try { throw 1 } catch (e) { print(e) } finally { print(2) }",
+			want: `== script ==
+0000 TRY 25
+0003 PUSH_SCOPE 0
+0006 CONSTANT 0 (1)
+0009 THROW
+0010 POP_SCOPE
+0011 END_TRY
+0012 PUSH_SCOPE 0
+0015 GET_GLOBAL 1 (print)
+0018 CONSTANT 2 (2)
+0021 CALL 1
+0023 POP
+0024 POP_SCOPE
+0025 JUMP 56
+0028 TRY_FINALLY 39
+0031 PUSH_SCOPE 1
+0034 DEFINE_LOCAL 0
+0037 PUSH_SCOPE 0
+0040 GET_GLOBAL 1 (print)
+0043 GET_LOCAL 1 0
+0048 CALL 1
+0050 POP
+0051 POP_SCOPE
+0052 POP_SCOPE
+0053 END_TRY
+0054 PUSH_SCOPE 0
+0057 GET_GLOBAL 1 (print)
+0060 CONSTANT 2 (2)
+0063 CALL 1
+0065 POP
+0066 POP_SCOPE
+0067 JUMP 14
+0070 PUSH_SCOPE 0
+0073 GET_GLOBAL 1 (print)
+0076 CONSTANT 2 (2)
+0079 CALL 1
+0081 POP
+0082 POP_SCOPE
+0083 RETHROW
+0084 NIL
+0085 RETURN
+`,
+		},
+		{
+			name:  "struct_field_assignment",
+			input: "struct P { x } var p = P(1) p.x += 2",
+			want: `== script ==
+0000 STRUCT 0 (<struct P>)
+0003 DEFINE_GLOBAL 1 (P)
+0006 GET_GLOBAL 1 (P)
+0009 CONSTANT 2 (1)
+0012 CALL 1
+0014 DEFINE_GLOBAL 3 (p)
+0017 GET_GLOBAL 3 (p)
+0020 DUP
+0021 GET_ATTR 4 (x)
+0024 CONSTANT 5 (2)
+0027 ADD
+0028 SET_ATTR 4 (x)
+0031 NIL
+0032 POP
+0033 NIL
+0034 RETURN
+`,
+		},
+		{
+			name:  "for_in_continue",
+			input: "for (k, v in m) { continue }",
+			want: `== script ==
+0000 GET_GLOBAL 0 (m)
+0003 ITER
+0004 ITER_NEXT 22 2
+0008 PUSH_SCOPE 2
+0011 DEFINE_LOCAL 1
+0014 DEFINE_LOCAL 0
+0017 PUSH_SCOPE 0
+0020 POP_SCOPE
+0021 POP_SCOPE
+0022 LOOP 21
+0025 POP_SCOPE
+0026 POP_SCOPE
+0027 LOOP 26
+0030 POP
+0031 NIL
+0032 RETURN
+`,
+		},
+		{
+			name:  "function",
+			input: "fun add(a, b) { return a + b }",
+			want: `== script ==
+0000 CLOSURE 0 (<fun-add>)
+0003 DUP
+0004 DEFINE_GLOBAL 1 (add)
+0007 POP
+0008 NIL
+0009 RETURN
+== add ==
+0000 GET_LOCAL 0 0
+0005 GET_LOCAL 0 1
+0010 ADD
+0011 RETURN
+0012 NIL
+0013 RETURN
+`,
+		},
+		{
+			name:  "defer",
+			input: "fun f(a) { defer print(a) }",
+			want: `== script ==
+0000 CLOSURE 0 (<fun-f>)
+0003 DUP
+0004 DEFINE_GLOBAL 1 (f)
+0007 POP
+0008 NIL
+0009 RETURN
+== f ==
+0000 CLOSURE 0 (<fun-defer>)
+0003 DEFER
+0004 NIL
+0005 RETURN
+== defer ==
+0000 GET_GLOBAL 0 (print)
+0003 GET_LOCAL 1 0
+0008 CALL 1
+0010 POP
+0011 NIL
+0012 RETURN
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ast.New(lex.New(tt.input)).RootNode()
+			assert.Nil(t, err)
+			assert.Nil(t, NewResolver().WithBuiltins([]string{"print", "l", "m", "y", "xs", "B"}).Resolve(root))
+
+			fn, err := New().Compile(root)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, Disassemble(fn))
+		})
+	}
+}
+
+func TestCompiler_CompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "break_outside_loop",
+			input: "break",
+			want:  "'break' outside of a loop",
+		},
+		{
+			name:  "return_outside_function",
+			input: "return 1",
+			want:  "'return' outside of a function",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ast.New(lex.New(tt.input)).RootNode()
+			assert.Nil(t, err)
+
+			assert.Nil(t, NewResolver().Resolve(root))
+
+			_, err = New().Compile(root)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package compile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Disassemble returns a human readable listing of a compiled function and all functions nested in it
+func Disassemble(fn *Function) string {
+	var sb strings.Builder
+	disassemble(&sb, fn)
+	return sb.String()
+}
+
+func disassemble(sb *strings.Builder, fn *Function) {
+	fmt.Fprintf(sb, "== %s ==\n", fn.Name)
+
+	chunk := fn.Chunk
+	nested := make([]*Function, 0)
+	for offset := 0; offset < len(chunk.Code); {
+		op := Opcode(chunk.Code[offset])
+		fmt.Fprintf(sb, "%04d %s", offset, op)
+
+		pos := offset + 1
+		for _, w := range operandWidths[op] {
+			var operand int
+			if w == 1 {
+				operand = int(chunk.Code[pos])
+			} else {
+				operand = chunk.ReadUint16(pos)
+			}
+			fmt.Fprintf(sb, " %d", operand)
+			pos += w
+		}
+
+		switch op {
+		case OP_CONSTANT, OP_DEFINE_GLOBAL, OP_GET_GLOBAL, OP_SET_GLOBAL, OP_IMPORT, OP_CLOSURE,
+			OP_GET_ATTR, OP_SET_ATTR, OP_STRUCT, OP_CLASS, OP_GET_SUPER, OP_GET_KEY:
+			constant := chunk.Constants[chunk.ReadUint16(offset+1)]
+			if fn, ok := constant.(*Function); ok {
+				nested = append(nested, fn)
+				fmt.Fprintf(sb, " (<fun-%s>)", fn.Name)
+			} else {
+				fmt.Fprintf(sb, " (%v)", constant)
+			}
+		}
+
+		sb.WriteString("\n")
+		offset += op.Size()
+	}
+
+	for _, fn := range nested {
+		disassemble(sb, fn)
+	}
+}
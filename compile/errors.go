@@ -0,0 +1,38 @@
+package compile
+
+import (
+	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/lex"
+)
+
+type CompileError struct {
+	Err  string
+	Node ast.Node
+}
+
+func NewCompileError(err string, node ast.Node) CompileError {
+	return CompileError{
+		Err:  err,
+		Node: node,
+	}
+}
+
+func (e CompileError) Error() string {
+	return e.Err
+}
+
+func (e CompileError) Inner() error {
+	return nil
+}
+
+func (e CompileError) ErrorType() string {
+	return "compile"
+}
+
+func (e CompileError) Begin() lex.Position {
+	return e.Node.Begin()
+}
+
+func (e CompileError) End() lex.Position {
+	return e.Node.End()
+}
@@ -0,0 +1,264 @@
+package compile
+
+type Opcode byte
+
+const (
+	// Constants + Literals
+	OP_CONSTANT Opcode = iota
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+
+	// Stack
+	OP_POP
+	OP_DUP
+	OP_DUP2
+
+	// Variables
+	OP_DEFINE_GLOBAL
+	OP_GET_GLOBAL
+	OP_SET_GLOBAL
+	OP_DEFINE_LOCAL
+	OP_GET_LOCAL
+	OP_SET_LOCAL
+	OP_PUSH_SCOPE
+	OP_POP_SCOPE
+
+	// Operators
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_MODULO
+	OP_NEGATE
+	OP_NOT
+	OP_TRUTHY
+	OP_AND
+	OP_EQ
+	OP_NEQ
+	OP_LT
+	OP_LTE
+	OP_GT
+	OP_GTE
+	OP_IN
+
+	// Control flow
+	OP_JUMP
+	OP_JUMP_IF_FALSE
+	OP_LOOP
+	OP_ITER
+	OP_ITER_NEXT
+	OP_CALL
+	OP_RETURN
+	OP_CLOSURE
+	OP_DEFER
+	OP_TRY
+	OP_TRY_FINALLY
+	OP_END_TRY
+	OP_THROW
+	OP_RETHROW
+	OP_MATCH
+	OP_NO_MATCH
+
+	// Collections
+	OP_LIST
+	OP_TUPLE
+	OP_UNPACK
+	OP_UNPACK_REST
+	OP_GET_KEY
+	OP_MAP
+	OP_SET
+	OP_INDEX
+	OP_SET_INDEX
+	OP_SLICE
+	OP_GET_ATTR
+	OP_SET_ATTR
+	OP_STRUCT
+	OP_CLASS
+	OP_GET_SUPER
+
+	// Misc
+	OP_INTERPOLATE
+	OP_ASSERT
+	OP_IMPORT
+)
+
+// operandWidths holds the width in bytes of each operand of an opcode
+var operandWidths = map[Opcode][]int{
+	OP_CONSTANT:      {2},
+	OP_DEFINE_GLOBAL: {2},
+	OP_GET_GLOBAL:    {2},
+	OP_SET_GLOBAL:    {2},
+	OP_DEFINE_LOCAL:  {2},
+	OP_GET_LOCAL:     {2, 2},
+	OP_SET_LOCAL:     {2, 2},
+	OP_PUSH_SCOPE:    {2},
+	OP_JUMP:          {2},
+	OP_JUMP_IF_FALSE: {2},
+	OP_LOOP:          {2},
+	OP_ITER_NEXT:     {2, 1},
+	OP_CALL:          {1},
+	OP_CLOSURE:       {2},
+	OP_TRY:           {2},
+	OP_TRY_FINALLY:   {2},
+	OP_LIST:          {2},
+	OP_TUPLE:         {2},
+	OP_UNPACK:        {2},
+	OP_UNPACK_REST:   {2},
+	OP_GET_KEY:       {2},
+	OP_INTERPOLATE:   {2},
+	OP_MAP:           {2},
+	OP_SET:           {2},
+	OP_GET_ATTR:      {2},
+	OP_SET_ATTR:      {2},
+	OP_STRUCT:        {2},
+	OP_CLASS:         {2, 2},
+	OP_GET_SUPER:     {2},
+	OP_IMPORT:        {2},
+}
+
+// Size returns the size in bytes of an instruction, including its operands
+func (o Opcode) Size() int {
+	size := 1
+	for _, w := range operandWidths[o] {
+		size += w
+	}
+	return size
+}
+
+func (o Opcode) String() string {
+	switch o {
+	case OP_CONSTANT:
+		return "CONSTANT"
+	case OP_NIL:
+		return "NIL"
+	case OP_TRUE:
+		return "TRUE"
+	case OP_FALSE:
+		return "FALSE"
+	case OP_POP:
+		return "POP"
+	case OP_DUP:
+		return "DUP"
+	case OP_DUP2:
+		return "DUP2"
+	case OP_DEFINE_GLOBAL:
+		return "DEFINE_GLOBAL"
+	case OP_GET_GLOBAL:
+		return "GET_GLOBAL"
+	case OP_SET_GLOBAL:
+		return "SET_GLOBAL"
+	case OP_DEFINE_LOCAL:
+		return "DEFINE_LOCAL"
+	case OP_GET_LOCAL:
+		return "GET_LOCAL"
+	case OP_SET_LOCAL:
+		return "SET_LOCAL"
+	case OP_PUSH_SCOPE:
+		return "PUSH_SCOPE"
+	case OP_POP_SCOPE:
+		return "POP_SCOPE"
+	case OP_ADD:
+		return "ADD"
+	case OP_SUBTRACT:
+		return "SUBTRACT"
+	case OP_MULTIPLY:
+		return "MULTIPLY"
+	case OP_DIVIDE:
+		return "DIVIDE"
+	case OP_MODULO:
+		return "MODULO"
+	case OP_NEGATE:
+		return "NEGATE"
+	case OP_NOT:
+		return "NOT"
+	case OP_TRUTHY:
+		return "TRUTHY"
+	case OP_AND:
+		return "AND"
+	case OP_EQ:
+		return "EQ"
+	case OP_NEQ:
+		return "NEQ"
+	case OP_LT:
+		return "LT"
+	case OP_LTE:
+		return "LTE"
+	case OP_GT:
+		return "GT"
+	case OP_GTE:
+		return "GTE"
+	case OP_IN:
+		return "IN"
+	case OP_JUMP:
+		return "JUMP"
+	case OP_JUMP_IF_FALSE:
+		return "JUMP_IF_FALSE"
+	case OP_LOOP:
+		return "LOOP"
+	case OP_ITER:
+		return "ITER"
+	case OP_ITER_NEXT:
+		return "ITER_NEXT"
+	case OP_CALL:
+		return "CALL"
+	case OP_RETURN:
+		return "RETURN"
+	case OP_CLOSURE:
+		return "CLOSURE"
+	case OP_DEFER:
+		return "DEFER"
+	case OP_TRY:
+		return "TRY"
+	case OP_TRY_FINALLY:
+		return "TRY_FINALLY"
+	case OP_END_TRY:
+		return "END_TRY"
+	case OP_THROW:
+		return "THROW"
+	case OP_RETHROW:
+		return "RETHROW"
+	case OP_MATCH:
+		return "MATCH"
+	case OP_NO_MATCH:
+		return "NO_MATCH"
+	case OP_LIST:
+		return "LIST"
+	case OP_TUPLE:
+		return "TUPLE"
+	case OP_UNPACK:
+		return "UNPACK"
+	case OP_UNPACK_REST:
+		return "UNPACK_REST"
+	case OP_GET_KEY:
+		return "GET_KEY"
+	case OP_MAP:
+		return "MAP"
+	case OP_SET:
+		return "SET"
+	case OP_INDEX:
+		return "INDEX"
+	case OP_SET_INDEX:
+		return "SET_INDEX"
+	case OP_SLICE:
+		return "SLICE"
+	case OP_GET_ATTR:
+		return "GET_ATTR"
+	case OP_SET_ATTR:
+		return "SET_ATTR"
+	case OP_STRUCT:
+		return "STRUCT"
+	case OP_CLASS:
+		return "CLASS"
+	case OP_GET_SUPER:
+		return "GET_SUPER"
+	case OP_INTERPOLATE:
+		return "INTERPOLATE"
+	case OP_ASSERT:
+		return "ASSERT"
+	case OP_IMPORT:
+		return "IMPORT"
+	default:
+		return "<UNKNOWN>"
+	}
+}
@@ -0,0 +1,626 @@
+package compile
+
+import (
+	"fmt"
+
+	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/lex"
+)
+
+// ImportHandler is called by the resolver for every import statement so that
+// the globals declared by the imported module are known before they are used
+type ImportHandler func(path string) error
+
+// Resolver statically binds every identifier to the variable it refers to
+//
+// Globals (variables declared at the top level of a module) are bound by name
+// since modules declare globals for each other through imports. All other
+// variables are bound to a (depth, slot) pair which mirrors the environments
+// the evaluator creates at runtime: one per block, one per function call
+// holding its parameters and the declarations in its body, and one per deferred call.
+//
+// Functions may refer to globals declared anywhere at the top level of their
+// module, everything else must be declared before it is used.
+type Resolver struct {
+	globals      map[string]struct{}
+	types        map[string]struct{}
+	hoisted      map[string]struct{}
+	initializing map[string]struct{}
+	scopes       []*resolverScope
+	funcDepth    int
+	classes      []bool
+	importer     ImportHandler
+}
+
+type resolverScope struct {
+	locals    map[string]*local
+	funcDepth int
+}
+
+type local struct {
+	slot   int
+	ready  bool
+	isType bool
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{
+		globals:      make(map[string]struct{}),
+		types:        make(map[string]struct{}),
+		hoisted:      make(map[string]struct{}),
+		initializing: make(map[string]struct{}),
+		scopes:       make([]*resolverScope, 0, 16),
+		importer:     func(string) error { return nil },
+	}
+}
+
+// WithBuiltins registers names that are always declared, programs may shadow them with their own variables
+func (r *Resolver) WithBuiltins(names []string) *Resolver {
+	for _, name := range names {
+		r.globals[name] = struct{}{}
+	}
+	return r
+}
+
+// WithTypes registers the names of built-in types, which type patterns accept besides classes and structs
+func (r *Resolver) WithTypes(names []string) *Resolver {
+	for _, name := range names {
+		r.types[name] = struct{}{}
+	}
+	return r
+}
+
+func (r *Resolver) WithImporter(importer ImportHandler) *Resolver {
+	r.importer = importer
+	return r
+}
+
+// Fork returns a resolver for another module which shares declared globals with this resolver
+func (r *Resolver) Fork() *Resolver {
+	f := NewResolver().WithImporter(r.importer)
+	f.globals = r.globals
+	f.types = r.types
+	return f
+}
+
+// Resolve binds all identifiers in a program or a single expression
+func (r *Resolver) Resolve(root ast.Node) error {
+	r.hoisted = make(map[string]struct{})
+	r.initializing = make(map[string]struct{})
+	r.scopes = r.scopes[:0]
+	r.funcDepth = 0
+	r.classes = r.classes[:0]
+
+	if program, ok := root.(ast.ProgramNode); ok {
+		r.hoist(program.Declarations)
+	}
+	return r.resolve(root)
+}
+
+func (r *Resolver) resolve(node ast.Node) error {
+	switch node := node.(type) {
+	case ast.ProgramNode:
+		return r.resolveAll(node.Declarations)
+	case ast.BlockNode:
+		r.beginScope()
+		defer r.endScope()
+		return r.resolveAll(node.Declarations)
+	case ast.VarStmtNode:
+		return r.varStmt(node)
+	case ast.MultiVarStmtNode:
+		return r.vars(node.Identifiers, node.Value)
+	case ast.DestructureVarStmtNode:
+		return r.vars(node.Pattern.Identifiers(), node.Value)
+	case ast.FunctionNode:
+		return r.function(node)
+	case ast.IdentifierNode:
+		return r.identifier(node, true)
+	case ast.AssignmentNode:
+		if node.Identifier.Token.Type == lex.TT_THIS {
+			return NewCompileError("cannot assign to 'this'", node.Identifier)
+		}
+		if err := r.resolve(node.Value); err != nil {
+			return err
+		}
+		return r.identifier(node.Identifier, false)
+	case ast.DestructureAssignmentNode:
+		if err := r.resolve(node.Value); err != nil {
+			return err
+		}
+		for _, identifier := range node.Pattern.Elements {
+			if identifier.Token.Type == lex.TT_THIS {
+				return NewCompileError("cannot assign to 'this'", identifier)
+			}
+			if err := r.identifier(identifier, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.IndexAssignmentNode:
+		return r.resolveAll([]ast.Node{node.Target.Sequence, node.Target.Index, node.Value})
+	case ast.AttrAssignmentNode:
+		return r.resolveAll([]ast.Node{node.Target.Object, node.Value})
+	case ast.StructStmtNode:
+		if err := r.declare(node.Identifier); err != nil {
+			return err
+		}
+		r.define(node.Identifier.Token.Literal)
+		r.defineType(node.Identifier.Token.Literal)
+		return nil
+	case ast.ClassStmtNode:
+		return r.class(node)
+	case ast.ImportStmtNode:
+		return r.importer(node.Name.Token.Literal)
+	case ast.ExpStmtNode:
+		return r.resolve(node.Exp)
+	case ast.ExpNode:
+		return r.resolve(node.Exp)
+	case ast.IfStmtNode:
+		return r.resolveAll([]ast.Node{node.Exp, node.TrueStmt, node.FalseStmt})
+	case ast.WhileStmtNode:
+		return r.resolveAll([]ast.Node{node.Condition, node.Body})
+	case ast.DoWhileStmtNode:
+		return r.resolveAll([]ast.Node{node.Body, node.Condition})
+	case ast.ForStmtNode:
+		r.beginScope()
+		defer r.endScope()
+		return r.resolveAll([]ast.Node{node.Init, node.Condition, node.Update, node.Body})
+	case ast.ForInStmtNode:
+		return r.forInStmt(node)
+	case ast.ReturnStmtNode:
+		return r.resolve(node.Exp)
+	case ast.DeferStmtNode:
+		r.beginScope()
+		defer r.endScope()
+		return r.resolve(node.Call)
+	case ast.AssertStmtNode:
+		return r.resolve(node.Exp)
+	case ast.ThrowStmtNode:
+		return r.resolve(node.Exp)
+	case ast.TryStmtNode:
+		return r.tryStmt(node)
+	case ast.MatchNode:
+		if err := r.resolve(node.Value); err != nil {
+			return err
+		}
+		for _, arm := range node.Arms {
+			if err := r.matchArm(arm); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.TernaryOpNode:
+		return r.resolveAll([]ast.Node{node.Exp, node.TrueExp, node.FalseExp})
+	case ast.BinaryOpNode:
+		return r.resolveAll([]ast.Node{node.LeftExp, node.RightExp})
+	case ast.UnaryOpNode:
+		return r.resolve(node.Operand)
+	case ast.LogicalAndNode:
+		return r.resolveAll([]ast.Node{node.LHS, node.RHS})
+	case ast.LogicalOrNode:
+		return r.resolveAll([]ast.Node{node.LHS, node.RHS})
+	case ast.InterpolatedStringNode:
+		return r.resolveAll(node.Segments)
+	case ast.ListNode:
+		return r.resolveAll(node.Elements)
+	case ast.TupleNode:
+		return r.resolveAll(node.Elements)
+	case ast.SetNode:
+		return r.resolveAll(node.Elements)
+	case ast.MapNode:
+		for _, kvp := range node.Elements {
+			if err := r.resolveAll([]ast.Node{kvp.Key, kvp.Value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.CallNode:
+		if err := r.resolve(node.Callee); err != nil {
+			return err
+		}
+		return r.resolveAll(node.Arguments)
+	case ast.IndexOfNode:
+		return r.resolveAll([]ast.Node{node.Sequence, node.Index})
+	case ast.GetAttrNode:
+		return r.resolve(node.Object)
+	case ast.SuperNode:
+		if err := r.identifier(node.Super, true); err != nil {
+			return err
+		}
+		return r.identifier(node.This, true)
+	case ast.SliceNode:
+		return r.resolveAll([]ast.Node{node.Sequence, node.Start, node.Stop, node.Step})
+	case nil, ast.NumberNode, ast.StringNode, ast.BooleanNode, ast.NilNode, ast.CommentNode,
+		ast.BreakStmtNode, ast.ContinueStmtNode:
+		return nil
+	}
+	return NewCompileError(fmt.Sprintf("invalid node: %T", node), node)
+}
+
+func (r *Resolver) resolveAll(nodes []ast.Node) error {
+	for _, node := range nodes {
+		if err := r.resolve(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) varStmt(node ast.VarStmtNode) error {
+	name := node.Identifier.Token.Literal
+
+	if r.global() {
+		r.initializing[name] = struct{}{}
+		if err := r.resolve(node.Value); err != nil {
+			return err
+		}
+		delete(r.initializing, name)
+		return r.declare(node.Identifier)
+	}
+
+	if err := r.declare(node.Identifier); err != nil {
+		return err
+	}
+	if err := r.resolve(node.Value); err != nil {
+		return err
+	}
+	r.define(name)
+	return nil
+}
+
+// vars declares the variables of a declaration which unpacks its value
+func (r *Resolver) vars(identifiers []ast.IdentifierNode, value ast.Node) error {
+	if r.global() {
+		for _, identifier := range identifiers {
+			r.initializing[identifier.Token.Literal] = struct{}{}
+		}
+		if err := r.resolve(value); err != nil {
+			return err
+		}
+		for _, identifier := range identifiers {
+			delete(r.initializing, identifier.Token.Literal)
+			if err := r.declare(identifier); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, identifier := range identifiers {
+		if err := r.declare(identifier); err != nil {
+			return err
+		}
+	}
+	if err := r.resolve(value); err != nil {
+		return err
+	}
+	for _, identifier := range identifiers {
+		r.define(identifier.Token.Literal)
+	}
+	return nil
+}
+
+// forInStmt declares the loop variables in a scope of their own which is recreated on every iteration
+func (r *Resolver) forInStmt(node ast.ForInStmtNode) error {
+	if err := r.resolve(node.Iterable); err != nil {
+		return err
+	}
+
+	r.beginScope()
+	defer r.endScope()
+
+	for _, variable := range node.Variables {
+		if err := r.declare(variable); err != nil {
+			return err
+		}
+		r.define(variable.Token.Literal)
+	}
+	return r.resolve(node.Body)
+}
+
+func (r *Resolver) tryStmt(node ast.TryStmtNode) error {
+	if err := r.resolve(node.Body); err != nil {
+		return err
+	}
+	if err := r.catchClause(node); err != nil {
+		return err
+	}
+	return r.resolve(node.Finally)
+}
+
+// catchClause declares the catch variable in a scope of its own enclosing the catch block
+func (r *Resolver) catchClause(node ast.TryStmtNode) error {
+	if node.Catch == nil {
+		return nil
+	}
+
+	r.beginScope()
+	defer r.endScope()
+
+	if err := r.declare(node.Variable); err != nil {
+		return err
+	}
+	r.define(node.Variable.Token.Literal)
+	return r.resolve(node.Catch)
+}
+
+// matchArm declares the captures of a pattern in a scope of their own enclosing the guard and the body
+func (r *Resolver) matchArm(node ast.MatchArmNode) error {
+	if err := r.typePatterns(node.Pattern); err != nil {
+		return err
+	}
+
+	r.beginScope()
+	defer r.endScope()
+
+	for _, capture := range ast.Captures(node.Pattern) {
+		if err := r.declare(capture); err != nil {
+			return err
+		}
+		r.define(capture.Token.Literal)
+	}
+
+	if node.Guard != nil {
+		if err := r.resolve(node.Guard); err != nil {
+			return err
+		}
+	}
+	return r.resolve(node.Body)
+}
+
+// typePatterns checks that the capture patterns of a pattern only name known types
+// Values are matched against the name of their type, so the names must be built-in types,
+// or classes and structs declared in scope rather than variables holding them
+func (r *Resolver) typePatterns(pattern ast.Node) error {
+	switch pattern := pattern.(type) {
+	case ast.CapturePatternNode:
+		if pattern.Type == nil {
+			return nil
+		}
+		if name := pattern.Type.(ast.IdentifierNode).Token.Literal; !r.isType(name) {
+			return NewCompileError(fmt.Sprintf("unknown type: %s", name), pattern.Type)
+		}
+	case ast.ListMatchPatternNode:
+		for _, elem := range pattern.Elements {
+			if err := r.typePatterns(elem); err != nil {
+				return err
+			}
+		}
+	case ast.MapMatchPatternNode:
+		for _, value := range pattern.Values {
+			if err := r.typePatterns(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) isType(name string) bool {
+	if _, ok := r.types[name]; ok {
+		return true
+	}
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if l, ok := r.scopes[i].locals[name]; ok && l.isType {
+			return true
+		}
+	}
+	return false
+}
+
+// function declares the function name before resolving the body so that functions can recurse
+func (r *Resolver) function(node ast.FunctionNode) error {
+	if err := r.declare(node.Identifier); err != nil {
+		return err
+	}
+	r.define(node.Identifier.Token.Literal)
+
+	r.funcDepth++
+	r.beginScope()
+	defer func() {
+		r.endScope()
+		r.funcDepth--
+	}()
+
+	for _, param := range node.Parameters {
+		if err := r.declare(param); err != nil {
+			return err
+		}
+		r.define(param.Token.Literal)
+	}
+	return r.resolveAll(node.Body.Declarations)
+}
+
+// class declares the class name before resolving its methods so that methods can refer to it
+//
+// Methods are resolved in a scope holding "this", which the evaluator creates whenever a method is
+// bound to an instance. When the class has a superclass, it's bound to "super" in a scope enclosing it.
+func (r *Resolver) class(node ast.ClassStmtNode) error {
+	if err := r.declare(node.Identifier); err != nil {
+		return err
+	}
+	r.define(node.Identifier.Token.Literal)
+	r.defineType(node.Identifier.Token.Literal)
+
+	hasSuperclass := node.Superclass != nil
+	if hasSuperclass {
+		if node.Superclass.(ast.IdentifierNode).Token.Literal == node.Identifier.Token.Literal {
+			return NewCompileError("a class cannot inherit from itself", node.Superclass)
+		}
+		if err := r.resolve(node.Superclass); err != nil {
+			return err
+		}
+
+		r.beginScope()
+		defer r.endScope()
+		r.scopes[len(r.scopes)-1].locals["super"] = &local{slot: 0, ready: true}
+	}
+
+	r.beginScope()
+	r.scopes[len(r.scopes)-1].locals["this"] = &local{slot: 0, ready: true}
+	r.classes = append(r.classes, hasSuperclass)
+	defer func() {
+		r.classes = r.classes[:len(r.classes)-1]
+		r.endScope()
+	}()
+
+	for _, method := range node.Methods {
+		if err := r.method(method); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// method resolves a method like a function, except that methods aren't variables of the enclosing scope
+func (r *Resolver) method(node ast.FunctionNode) error {
+	r.funcDepth++
+	r.beginScope()
+	defer func() {
+		r.endScope()
+		r.funcDepth--
+	}()
+
+	for _, param := range node.Parameters {
+		if err := r.declare(param); err != nil {
+			return err
+		}
+		r.define(param.Token.Literal)
+	}
+	return r.resolveAll(node.Body.Declarations)
+}
+
+// identifier binds an identifier to the innermost variable with the same name
+func (r *Resolver) identifier(node ast.IdentifierNode, read bool) error {
+	name := node.Token.Literal
+	if err := r.classKeyword(node); err != nil {
+		return err
+	}
+
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		scope := r.scopes[i]
+		if l, ok := scope.locals[name]; ok {
+			if read && !l.ready && scope.funcDepth == r.funcDepth {
+				return NewCompileError(fmt.Sprintf("variable read in its own initializer: %s", name), node)
+			}
+			*node.Binding = ast.Binding{Local: true, Depth: len(r.scopes) - 1 - i, Slot: l.slot}
+			return nil
+		}
+	}
+
+	if _, ok := r.initializing[name]; ok && read && r.funcDepth == 0 {
+		return NewCompileError(fmt.Sprintf("variable read in its own initializer: %s", name), node)
+	}
+
+	_, declared := r.globals[name]
+	if _, ok := r.hoisted[name]; ok && r.funcDepth > 0 {
+		declared = true
+	}
+
+	if !declared {
+		return NewCompileError(fmt.Sprintf("use of undeclared variable: %s", name), node)
+	}
+
+	*node.Binding = ast.Binding{}
+	return nil
+}
+
+// classKeyword checks that "this" and "super" are used inside the methods of a class
+func (r *Resolver) classKeyword(node ast.IdentifierNode) error {
+	switch node.Token.Type {
+	case lex.TT_THIS:
+		if len(r.classes) == 0 {
+			return NewCompileError("cannot use 'this' outside of a class", node)
+		}
+	case lex.TT_SUPER:
+		if len(r.classes) == 0 {
+			return NewCompileError("cannot use 'super' outside of a class", node)
+		} else if !r.classes[len(r.classes)-1] {
+			return NewCompileError("cannot use 'super' in a class without a superclass", node)
+		}
+	}
+	return nil
+}
+
+// declare adds a variable to the current scope
+// Redeclaring a global is a runtime error since globals can be redeclared across REPL inputs
+func (r *Resolver) declare(identifier ast.IdentifierNode) error {
+	name := identifier.Token.Literal
+	if r.global() {
+		r.globals[name] = struct{}{}
+		*identifier.Binding = ast.Binding{}
+		return nil
+	}
+
+	scope := r.scopes[len(r.scopes)-1]
+	if _, ok := scope.locals[name]; ok {
+		return NewCompileError(fmt.Sprintf("cannot redeclare symbol: %s", name), identifier)
+	}
+
+	slot := len(scope.locals)
+	scope.locals[name] = &local{slot: slot}
+	*identifier.Binding = ast.Binding{Local: true, Slot: slot}
+	return nil
+}
+
+// define marks a local variable as initialized
+func (r *Resolver) define(name string) {
+	if r.global() {
+		return
+	}
+	r.scopes[len(r.scopes)-1].locals[name].ready = true
+}
+
+// defineType marks a declared variable as a class or struct
+func (r *Resolver) defineType(name string) {
+	if r.global() {
+		r.types[name] = struct{}{}
+		return
+	}
+	r.scopes[len(r.scopes)-1].locals[name].isType = true
+}
+
+// hoist records the globals declared at the top level of a module
+func (r *Resolver) hoist(declarations []ast.Node) {
+	for _, decl := range declarations {
+		switch decl := decl.(type) {
+		case ast.VarStmtNode:
+			r.hoisted[decl.Identifier.Token.Literal] = struct{}{}
+		case ast.MultiVarStmtNode:
+			for _, identifier := range decl.Identifiers {
+				r.hoisted[identifier.Token.Literal] = struct{}{}
+			}
+		case ast.DestructureVarStmtNode:
+			for _, identifier := range decl.Pattern.Identifiers() {
+				r.hoisted[identifier.Token.Literal] = struct{}{}
+			}
+		case ast.FunctionNode:
+			r.hoisted[decl.Identifier.Token.Literal] = struct{}{}
+		case ast.StructStmtNode:
+			r.hoisted[decl.Identifier.Token.Literal] = struct{}{}
+			r.types[decl.Identifier.Token.Literal] = struct{}{}
+		case ast.ClassStmtNode:
+			r.hoisted[decl.Identifier.Token.Literal] = struct{}{}
+			r.types[decl.Identifier.Token.Literal] = struct{}{}
+		case ast.CallNode:
+			if fun, ok := decl.Callee.(ast.FunctionNode); ok {
+				r.hoisted[fun.Identifier.Token.Literal] = struct{}{}
+			}
+		}
+	}
+}
+
+func (r *Resolver) global() bool {
+	return len(r.scopes) == 0
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, &resolverScope{
+		locals:    make(map[string]*local),
+		funcDepth: r.funcDepth,
+	})
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
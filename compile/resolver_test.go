@@ -0,0 +1,187 @@
+package compile
+
+import (
+	"testing"
+
+	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/lex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolver_Bindings(t *testing.T) {
+	root, err := ast.New(lex.New("var g = 1 { var a = g fun f(p) { return a + p } }")).RootNode()
+	assert.Nil(t, err)
+	assert.Nil(t, NewResolver().Resolve(root))
+
+	block := root.(ast.ProgramNode).Declarations[1].(ast.BlockNode)
+	varA := block.Declarations[0].(ast.VarStmtNode)
+	assert.Equal(t, ast.Binding{Local: true, Depth: 0, Slot: 0}, *varA.Identifier.Binding)
+	assert.Equal(t, ast.Binding{}, *varA.Value.(ast.IdentifierNode).Binding)
+
+	fun := block.Declarations[1].(ast.FunctionNode)
+	assert.Equal(t, ast.Binding{Local: true, Depth: 0, Slot: 1}, *fun.Identifier.Binding)
+
+	sum := fun.Body.Declarations[0].(ast.ReturnStmtNode).Exp.(ast.BinaryOpNode)
+	assert.Equal(t, ast.Binding{Local: true, Depth: 1, Slot: 0}, *sum.LeftExp.(ast.IdentifierNode).Binding)
+	assert.Equal(t, ast.Binding{Local: true, Depth: 0, Slot: 0}, *sum.RightExp.(ast.IdentifierNode).Binding)
+}
+
+func TestResolver_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "undeclared",
+			input: "{ var a = b }",
+			want:  "use of undeclared variable: b",
+		},
+		{
+			name:  "undeclared_global",
+			input: "println(x) var x = 1",
+			want:  "use of undeclared variable: x",
+		},
+		{
+			name:  "own_initializer",
+			input: "var a = 1 { var a = a + 1 }",
+			want:  "variable read in its own initializer: a",
+		},
+		{
+			name:  "own_initializer_global",
+			input: "var a = a",
+			want:  "variable read in its own initializer: a",
+		},
+		{
+			name:  "match_capture_scope",
+			input: "fun f(v) { var r = match (v) { [x] => x, _ => 0 } return x }",
+			want:  "use of undeclared variable: x",
+		},
+		{
+			name:  "destructure_undeclared",
+			input: "var a = 1\n[a, b] = [b, a]",
+			want:  "use of undeclared variable: b",
+		},
+		{
+			name:  "own_initializer_pattern",
+			input: "{ var [a, ...b] = b }",
+			want:  "variable read in its own initializer: b",
+		},
+		{
+			name:  "own_initializer_multiple",
+			input: "{ var a, b = 1, a }",
+			want:  "variable read in its own initializer: a",
+		},
+		{
+			name:  "redeclare_local",
+			input: "fun f(a, a) {}",
+			want:  "cannot redeclare symbol: a",
+		},
+		{
+			name:  "local_declared_later",
+			input: "{ fun f() { return x } var x = 1 }",
+			want:  "use of undeclared variable: x",
+		},
+		{
+			name:  "this_outside_class",
+			input: "fun f() { return this }",
+			want:  "cannot use 'this' outside of a class",
+		},
+		{
+			name:  "super_without_superclass",
+			input: "class A { f() { return super.f() } }",
+			want:  "cannot use 'super' in a class without a superclass",
+		},
+		{
+			name:  "catch_variable_scope",
+			input: "try {} catch (e) {} e",
+			want:  "use of undeclared variable: e",
+		},
+		{
+			name:  "inherit_from_itself",
+			input: "class A < A {}",
+			want:  "a class cannot inherit from itself",
+		},
+		{
+			name:  "assign_this",
+			input: "class A { f() { this = 1 } }",
+			want:  "cannot assign to 'this'",
+		},
+		{
+			name:  "unknown_pattern_type",
+			input: "match (1) { x: Foo => x }",
+			want:  "unknown type: Foo",
+		},
+		{
+			name:  "nested_list_unknown_pattern_type",
+			input: "match ([1]) { [x, [y: Foo]] => x }",
+			want:  "unknown type: Foo",
+		},
+		{
+			name:  "nested_map_unknown_pattern_type",
+			input: "match ({}) { {\"k\": [z, {\"v\": w: Bar}]} => z }",
+			want:  "unknown type: Bar",
+		},
+		{
+			name:  "variable_pattern_type",
+			input: "{ var Foo = 1 match (1) { x: Foo => x } }",
+			want:  "unknown type: Foo",
+		},
+		{
+			name:  "class_out_of_scope_pattern_type",
+			input: "{ class Foo {} } match (1) { x: Foo => x }",
+			want:  "unknown type: Foo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ast.New(lex.New(tt.input)).RootNode()
+			assert.Nil(t, err)
+			assert.EqualError(t, NewResolver().WithBuiltins([]string{"println", "len"}).Resolve(root), tt.want)
+		})
+	}
+}
+
+func TestResolver_ShadowBuiltins(t *testing.T) {
+	root, err := ast.New(lex.New("var type = 1 { var len = type len } fun f(len) { return len }")).RootNode()
+	assert.Nil(t, err)
+	assert.Nil(t, NewResolver().WithBuiltins([]string{"len", "type"}).Resolve(root))
+
+	block := root.(ast.ProgramNode).Declarations[1].(ast.BlockNode)
+	varLen := block.Declarations[0].(ast.VarStmtNode)
+	assert.Equal(t, ast.Binding{Local: true, Depth: 0, Slot: 0}, *varLen.Identifier.Binding)
+	assert.Equal(t, ast.Binding{}, *varLen.Value.(ast.IdentifierNode).Binding)
+	assert.Equal(t, ast.Binding{Local: true, Depth: 0, Slot: 0}, *block.Declarations[1].(ast.ExpStmtNode).Exp.(ast.IdentifierNode).Binding)
+
+	fun := root.(ast.ProgramNode).Declarations[2].(ast.FunctionNode)
+	ret := fun.Body.Declarations[0].(ast.ReturnStmtNode)
+	assert.Equal(t, ast.Binding{Local: true, Depth: 0, Slot: 0}, *ret.Exp.(ast.IdentifierNode).Binding)
+}
+
+func TestResolver_Hoisting(t *testing.T) {
+	tests := []string{
+		"fun a() { return b() } fun b() { return 1 }",
+		"var f = fun (n) { return n == 0 ? 0 : f(n - 1) }",
+		"{ fun f(n) { return n == 0 ? 0 : f(n - 1) } }",
+		"fun f() { return A() } class A { make() { return A() } }",
+	}
+	for _, input := range tests {
+		root, err := ast.New(lex.New(input)).RootNode()
+		assert.Nil(t, err)
+		assert.Nil(t, NewResolver().Resolve(root), input)
+	}
+}
+
+func TestResolver_TypePatterns(t *testing.T) {
+	tests := []string{
+		"match (1) { x: int => x, [y: string] => y, {\"k\": z: list} => z }",
+		"match (1) { p: Point => p } struct Point { x, y }",
+		"fun f(v) { return match (v) { a: A => a } } class A {}",
+		"{ class B {} match (1) { b: B => b } }",
+	}
+	for _, input := range tests {
+		root, err := ast.New(lex.New(input)).RootNode()
+		assert.Nil(t, err)
+		assert.Nil(t, NewResolver().WithTypes([]string{"int", "string", "list"}).Resolve(root), input)
+	}
+}
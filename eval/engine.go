@@ -0,0 +1,22 @@
+package eval
+
+import (
+	"fmt"
+)
+
+type Engine string
+
+const (
+	// Tree-walking evaluator
+	EngineTree Engine = "tree"
+	// Bytecode compiler + stack VM
+	EngineVM Engine = "vm"
+)
+
+func ParseEngine(name string) (Engine, error) {
+	switch Engine(name) {
+	case EngineTree, EngineVM:
+		return Engine(name), nil
+	}
+	return "", fmt.Errorf("unknown engine: %s (expected %s or %s)", name, EngineTree, EngineVM)
+}
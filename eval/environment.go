@@ -2,6 +2,7 @@ package eval
 
 import (
 	"fmt"
+	"sort"
 )
 
 var globals map[string]Object = make(map[string]Object)
@@ -14,14 +15,29 @@ func RegisterGlobal(name string, obj Object) {
 	}
 }
 
+// GlobalNames returns the names of all registered globals
+func GlobalNames() []string {
+	names := make([]string, 0, len(globals))
+	for name := range globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environment holds the variables of a scope
+// Variables of the global scope are accessed by name, variables of all
+// other scopes are accessed by the slot assigned to them by the resolver
 type Environment struct {
 	scopeVariables map[string]Object
+	slots          []Object
 	enclosing      *Environment
 }
 
 func NewEnvironment() *Environment {
 	env := Environment{
 		scopeVariables: make(map[string]Object),
+		slots:          make([]Object, 0, 8),
 		enclosing:      nil,
 	}
 
@@ -34,10 +50,6 @@ func (e *Environment) WithEnclosing(env *Environment) *Environment {
 }
 
 func (e *Environment) Declare(varName string, varValue Object) error {
-	if _, ok := globals[varName]; ok {
-		return fmt.Errorf("cannot redeclare global: %s", varName)
-	}
-
 	if _, ok := e.scopeVariables[varName]; ok {
 		return fmt.Errorf("cannot redeclare symbol: %s", varName)
 	}
@@ -56,16 +68,46 @@ func (e *Environment) Assign(varName string, varValue Object) error {
 	return nil
 }
 
+// Get looks up a variable by name, variables declared by the program shadow registered globals
 func (e *Environment) Get(varName string) (Object, error) {
-	if val, ok := globals[varName]; ok {
-		return val, nil
-	}
-
 	if _, ok := e.scopeVariables[varName]; !ok {
 		if e.enclosing != nil {
 			return e.enclosing.Get(varName)
 		}
+		if val, ok := globals[varName]; ok {
+			return val, nil
+		}
 		return NIL, fmt.Errorf("symbol not declared: %s", varName)
 	}
 	return e.scopeVariables[varName], nil
 }
+
+// Define sets the value of a slot in this scope
+func (e *Environment) Define(slot int, varValue Object) {
+	for slot >= len(e.slots) {
+		e.slots = append(e.slots, NIL)
+	}
+	e.slots[slot] = varValue
+}
+
+// AssignAt sets the value of a slot in the scope depth levels up from this scope
+func (e *Environment) AssignAt(depth int, slot int, varValue Object) {
+	e.ancestor(depth).Define(slot, varValue)
+}
+
+// GetAt gets the value of a slot in the scope depth levels up from this scope
+func (e *Environment) GetAt(depth int, slot int) Object {
+	env := e.ancestor(depth)
+	if slot >= len(env.slots) {
+		return NIL
+	}
+	return env.slots[slot]
+}
+
+func (e *Environment) ancestor(depth int) *Environment {
+	env := e
+	for i := 0; i < depth; i++ {
+		env = env.enclosing
+	}
+	return env
+}
@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/shreerangdixit/yeti/ast"
@@ -40,6 +41,10 @@ func (e EvaluateError) Inner() error {
 	return e.inner
 }
 
+func (e EvaluateError) Unwrap() error {
+	return e.err
+}
+
 func (e EvaluateError) ErrorType() string {
 	return "runtime"
 }
@@ -97,3 +102,101 @@ func NewAssertError(exp ast.Node) AssertError {
 func (e AssertError) Error() string {
 	return fmt.Sprintf("assert failed: %s", e.Exp)
 }
+
+// Error thrown by a throw statement
+type ThrowError struct {
+	Value Object
+}
+
+func NewThrowError(value Object) ThrowError {
+	return ThrowError{
+		Value: value,
+	}
+}
+
+func (e ThrowError) Error() string { return e.Value.String() }
+
+// Kinds of errors, caught errors expose them through their kind attribute
+const (
+	KindRuntime      = "runtime"
+	KindError        = "error"
+	KindZeroDivision = "zero_division"
+	KindIndex        = "index"
+	KindAssert       = "assert"
+	KindIO           = "io"
+	KindMatch        = "match"
+)
+
+// KindedError is a runtime error of a kind other than KindRuntime
+type KindedError struct {
+	Kind string
+	err  error
+}
+
+func NewKindedError(kind string, err error) KindedError {
+	return KindedError{
+		Kind: kind,
+		err:  err,
+	}
+}
+
+func (e KindedError) Error() string { return e.err.Error() }
+
+var (
+	errDivideByZero = NewKindedError(KindZeroDivision, errors.New("Divide by zero error"))
+	errModuloByZero = NewKindedError(KindZeroDivision, errors.New("Modulo by zero error"))
+)
+
+func indexOutOfRange(t ObjectType) error {
+	return NewKindedError(KindIndex, fmt.Errorf("%s index out of range", t))
+}
+
+func noMatch(value Object) error {
+	return NewKindedError(KindMatch, fmt.Errorf("no match arm for value: %s", value))
+}
+
+// Caught converts an error into the value bound by a catch clause
+// Thrown values are caught as they are, other errors are caught as Error objects positioned
+// where they occurred. Errors which implement control flow can't be caught.
+func Caught(err error) (Object, bool) {
+	switch err.(type) {
+	case BreakError, ContinueError, ReturnError:
+		return NIL, false
+	}
+
+	var line, column int
+	if err, ok := err.(PositionError); ok {
+		pos := innermostError(err).End()
+		line, column = pos.Line, pos.Column
+	}
+
+	var thrown ThrowError
+	if errors.As(err, &thrown) {
+		if e, ok := thrown.Value.(*Error); ok && e.Line == 0 {
+			e.Line, e.Column = line, column
+		}
+		return thrown.Value, true
+	}
+
+	kind := KindRuntime
+	var kinded KindedError
+	var assertErr AssertError
+	if errors.As(err, &kinded) {
+		kind = kinded.Kind
+	} else if errors.As(err, &assertErr) {
+		kind = KindAssert
+	}
+	return &Error{Message: err.Error(), Kind: kind, Line: line, Column: column}, true
+}
+
+// innermostError unwinds the stack trace of an error to the position where it occurred
+func innermostError(err PositionError) PositionError {
+	for err.Inner() != nil {
+		inner, ok := err.Inner().(PositionError)
+		if !ok {
+			break
+		}
+		err = inner
+	}
+	return err
+}
@@ -29,14 +29,7 @@ type ErrorFormatter struct {
 
 func NewErrorFormatter(err error, mod Module) (*ErrorFormatter, bool) {
 	if err, ok := err.(PositionError); ok {
-		// Unwind stack trace
-		for err.Inner() != nil {
-			var ok bool
-			if err, ok = err.Inner().(PositionError); ok {
-				continue
-			}
-			break
-		}
+		err = innermostError(err)
 		if data, e := mod.Data(); e == nil {
 			lines := strings.Split(data, "\n")
 			lines = append(lines, "\n") // Hack to ensure we can highlight errors on the last line
@@ -54,30 +47,42 @@ func NewErrorFormatter(err error, mod Module) (*ErrorFormatter, bool) {
 
 func (f *ErrorFormatter) Format() string {
 	str := fmt.Sprintf("\n%s:%d:%d %s error: %v\n", f.mod.Path(), f.err.End().Line, f.err.End().Column, f.err.ErrorType(), f.err)
-	endLine := f.err.End().Line - 1
-	if endLine < 0 {
-		endLine = 0
-	}
-	str += fmt.Sprintf("%s\n", f.lines[endLine])
+	str += fmt.Sprintf("%s\n", f.lines[f.endLine()])
 	str += fmt.Sprintf("%s\n", f.arrows())
 	return str
 }
 
+// arrows underlines the error on the last line it spans, errors spanning multiple lines are underlined from the start of the line
+// Columns count characters rather than bytes, tabs in the source line are repeated so that the carets line up with multibyte
+// and tab characters above them
 func (f *ErrorFormatter) arrows() string {
-	str := ""
-	beginCol := f.err.Begin().Column
-	endCol := f.err.End().Column
-	if beginCol < endCol {
-		for i := 1; i < beginCol; i++ {
-			str += " "
-		}
-		for i := beginCol; i <= endCol; i++ {
-			str += "^"
-		}
-	} else {
-		for i := 0; i <= endCol; i++ {
-			str += "^"
+	begin := f.err.Begin()
+	end := f.err.End()
+
+	beginCol := begin.Column
+	if begin.Line != end.Line || beginCol > end.Column {
+		beginCol = 1
+	}
+
+	line := []rune(f.lines[f.endLine()])
+	var sb strings.Builder
+	for i := 1; i < beginCol; i++ {
+		if i <= len(line) && line[i-1] == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
 		}
 	}
-	return str
+	for i := beginCol; i <= end.Column; i++ {
+		sb.WriteRune('^')
+	}
+	return sb.String()
+}
+
+func (f *ErrorFormatter) endLine() int {
+	endLine := f.err.End().Line - 1
+	if endLine < 0 {
+		endLine = 0
+	}
+	return endLine
 }
@@ -5,27 +5,67 @@ import (
 	"strconv"
 
 	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/compile"
 	"github.com/shreerangdixit/yeti/lex"
 )
 
 type Evaluator struct {
 	Importer *Importer
 
-	env      *Environment
-	deferred []ast.CallNode
+	globalEnv *Environment
+	env       *Environment
+	deferred  []deferredCall
+	resolver  *compile.Resolver
+	engine    Engine
+	vm        *VM
+}
+
+// deferredCall is a deferred call along with the environment it was deferred in
+type deferredCall struct {
+	call ast.CallNode
+	env  *Environment
 }
 
 func NewEvaluator() *Evaluator {
+	globalEnv := NewEnvironment()
 	e := Evaluator{
-		env:      NewEnvironment(),
-		deferred: make([]ast.CallNode, 0, 20),
+		globalEnv: globalEnv,
+		env:       globalEnv,
+		deferred:  make([]deferredCall, 0, 20),
+		engine:    EngineTree,
 	}
 	e.Importer = NewImporter(&e)
+	e.resolver = compile.NewResolver().WithBuiltins(GlobalNames()).WithTypes(TypeNames()).WithImporter(e.Importer.resolveImport)
+	e.vm = NewVM(&e)
 	return &e
 }
 
+// WithEngine selects the engine used to run programs passed to Evaluate
+func (e *Evaluator) WithEngine(engine Engine) *Evaluator {
+	e.engine = engine
+	return e
+}
+
+// Resolve binds the identifiers in a program to their variables, it must be called before Evaluate
+func (e *Evaluator) Resolve(root ast.Node) error {
+	return e.resolver.Resolve(root)
+}
+
 func (e *Evaluator) Evaluate(root ast.Node) (Object, error) {
-	return e.eval(root)
+	if e.engine == EngineVM {
+		return e.vm.Run(root)
+	}
+
+	// Programs are always evaluated in the global scope, even when imported from a nested scope
+	prev := e.env
+	defer func() {
+		e.env = prev
+	}()
+
+	e.env = e.globalEnv
+	return e.withDeferred(func() (Object, error) {
+		return e.eval(root)
+	})
 }
 
 func (e *Evaluator) eval(node ast.Node) (Object, error) {
@@ -39,6 +79,18 @@ func (e *Evaluator) eval(node ast.Node) (Object, error) {
 	case ast.VarStmtNode:
 		obj, err := e.evalVarStmtNode(node)
 		return e.wrapResult(node, obj, err)
+	case ast.MultiVarStmtNode:
+		obj, err := e.evalMultiVarStmtNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.DestructureVarStmtNode:
+		obj, err := e.evalDestructureVarStmtNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.StructStmtNode:
+		obj, err := e.evalStructStmtNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.ClassStmtNode:
+		obj, err := e.evalClassStmtNode(node)
+		return e.wrapResult(node, obj, err)
 	case ast.ExpStmtNode:
 		obj, err := e.evalExpStmtNode(node)
 		return e.wrapResult(node, obj, err)
@@ -48,6 +100,15 @@ func (e *Evaluator) eval(node ast.Node) (Object, error) {
 	case ast.WhileStmtNode:
 		obj, err := e.evalWhileStmtNode(node)
 		return e.wrapResult(node, obj, err)
+	case ast.DoWhileStmtNode:
+		obj, err := e.evalDoWhileStmtNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.ForStmtNode:
+		obj, err := e.evalForStmtNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.ForInStmtNode:
+		obj, err := e.evalForInStmtNode(node)
+		return e.wrapResult(node, obj, err)
 	case ast.BreakStmtNode:
 		obj, err := e.evalBreakStmtNode(node)
 		return e.wrapResult(node, obj, err)
@@ -60,6 +121,24 @@ func (e *Evaluator) eval(node ast.Node) (Object, error) {
 	case ast.AssignmentNode:
 		obj, err := e.evalAssignmentNode(node)
 		return e.wrapResult(node, obj, err)
+	case ast.DestructureAssignmentNode:
+		obj, err := e.evalDestructureAssignmentNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.IndexAssignmentNode:
+		obj, err := e.evalIndexAssignmentNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.AttrAssignmentNode:
+		obj, err := e.evalAttrAssignmentNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.GetAttrNode:
+		obj, err := e.evalGetAttrNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.SuperNode:
+		obj, err := e.evalSuperNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.SliceNode:
+		obj, err := e.evalSliceNode(node)
+		return e.wrapResult(node, obj, err)
 	case ast.LogicalAndNode:
 		obj, err := e.evalLogicalAndNode(node)
 		return e.wrapResult(node, obj, err)
@@ -90,9 +169,18 @@ func (e *Evaluator) eval(node ast.Node) (Object, error) {
 	case ast.StringNode:
 		obj, err := e.evalStringNode(node)
 		return e.wrapResult(node, obj, err)
+	case ast.InterpolatedStringNode:
+		obj, err := e.evalInterpolatedStringNode(node)
+		return e.wrapResult(node, obj, err)
 	case ast.ListNode:
 		obj, err := e.evalListNode(node)
 		return e.wrapResult(node, obj, err)
+	case ast.TupleNode:
+		obj, err := e.evalTupleNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.SetNode:
+		obj, err := e.evalSetNode(node)
+		return e.wrapResult(node, obj, err)
 	case ast.MapNode:
 		obj, err := e.evalMapNode(node)
 		return e.wrapResult(node, obj, err)
@@ -114,6 +202,15 @@ func (e *Evaluator) eval(node ast.Node) (Object, error) {
 	case ast.AssertStmtNode:
 		obj, err := e.evalAssertStmtNode(node)
 		return e.wrapResult(node, obj, err)
+	case ast.ThrowStmtNode:
+		obj, err := e.evalThrowStmtNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.TryStmtNode:
+		obj, err := e.evalTryStmtNode(node)
+		return e.wrapResult(node, obj, err)
+	case ast.MatchNode:
+		obj, err := e.evalMatchNode(node)
+		return e.wrapResult(node, obj, err)
 	case ast.CommentNode:
 		obj, err := e.evalCommentNode(node)
 		return e.wrapResult(node, obj, err)
@@ -169,21 +266,66 @@ func (e *Evaluator) evalBlockNodeWithEnv(node ast.BlockNode, env *Environment) (
 			return NIL, err
 		}
 	}
+	return NIL, nil
+}
+
+// withDeferred runs a function or a program with a list of deferred calls of its own, which are
+// run once it returns or fails. An error raised by a deferred call replaces its result.
+func (e *Evaluator) withDeferred(run func() (Object, error)) (Object, error) {
+	outer := e.deferred
+	e.deferred = nil
+	defer func() {
+		e.deferred = outer
+	}()
 
-	return e.runDeferred()
+	val, err := run()
+	if deferredErr := e.runDeferred(); deferredErr != nil {
+		return NIL, deferredErr
+	}
+	return val, err
 }
 
-func (e *Evaluator) runDeferred() (Object, error) {
+// runDeferred runs the deferred calls in order, an error stops the remaining calls
+func (e *Evaluator) runDeferred() error {
 	deferred := e.deferred
-	e.deferred = make([]ast.CallNode, 0, 20)
-	for _, call := range deferred {
-		o, err := e.eval(call)
-		if err != nil {
-			return o, err
+	e.deferred = nil
+	for _, d := range deferred {
+		if _, err := e.evalWithEnv(d.call, d.env); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return NIL, nil
+func (e *Evaluator) evalWithEnv(node ast.Node, env *Environment) (Object, error) {
+	prev := e.env
+	defer func() {
+		e.env = prev
+	}()
+
+	e.env = env
+	return e.eval(node)
+}
+
+// declare binds a value to a newly declared variable
+func (e *Evaluator) declare(identifier ast.IdentifierNode, value Object) error {
+	if identifier.Binding.Local {
+		e.env.Define(identifier.Binding.Slot, value)
+		return nil
+	}
+	if err := e.globalEnv.Declare(identifier.Token.Literal, value); err != nil {
+		return NewEvaluateError(identifier, err)
+	}
+	return nil
+}
+
+// assign binds a value to an existing variable
+func (e *Evaluator) assign(identifier ast.IdentifierNode, value Object) error {
+	if binding := identifier.Binding; binding.Local {
+		e.env.AssignAt(binding.Depth, binding.Slot, value)
+		return nil
+	}
+	return e.globalEnv.Assign(identifier.Token.Literal, value)
 }
 
 func (e *Evaluator) evalVarStmtNode(node ast.VarStmtNode) (Object, error) {
@@ -192,9 +334,63 @@ func (e *Evaluator) evalVarStmtNode(node ast.VarStmtNode) (Object, error) {
 		return NIL, err
 	}
 
-	if err := e.env.Declare(node.Identifier.Token.Literal, value); err != nil {
+	if err := e.declare(node.Identifier, value); err != nil {
+		return NIL, err
+	}
+	return NIL, nil
+}
+
+func (e *Evaluator) evalMultiVarStmtNode(node ast.MultiVarStmtNode) (Object, error) {
+	value, err := e.eval(node.Value)
+	if err != nil {
+		return NIL, err
+	}
+
+	values, err := Unpack(value, len(node.Identifiers))
+	if err != nil {
+		return NIL, err
+	}
+
+	for i, identifier := range node.Identifiers {
+		if err := e.declare(identifier, values[i]); err != nil {
+			return NIL, err
+		}
+	}
+	return NIL, nil
+}
+
+func (e *Evaluator) evalDestructureVarStmtNode(node ast.DestructureVarStmtNode) (Object, error) {
+	value, err := e.eval(node.Value)
+	if err != nil {
+		return NIL, err
+	}
+
+	var values []Object
+	switch pattern := node.Pattern.(type) {
+	case ast.ListPatternNode:
+		if pattern.Rest == nil {
+			values, err = Unpack(value, len(pattern.Elements))
+		} else {
+			values, err = UnpackRest(value, len(pattern.Elements))
+		}
+	case ast.MapPatternNode:
+		for _, key := range pattern.Keys {
+			var val Object
+			if val, err = UnpackKey(value, key.Token.Literal); err != nil {
+				break
+			}
+			values = append(values, val)
+		}
+	}
+	if err != nil {
 		return NIL, err
 	}
+
+	for i, identifier := range node.Pattern.Identifiers() {
+		if err := e.declare(identifier, values[i]); err != nil {
+			return NIL, err
+		}
+	}
 	return NIL, nil
 }
 
@@ -202,13 +398,22 @@ func (e *Evaluator) evalExpStmtNode(node ast.ExpStmtNode) (Object, error) {
 	return e.eval(node.Exp)
 }
 
+// evalCondition evaluates an expression and reports whether its value is truthy
+func (e *Evaluator) evalCondition(node ast.Node) (bool, error) {
+	value, err := e.eval(node)
+	if err != nil {
+		return false, err
+	}
+	return IsTruthy(value)
+}
+
 func (e *Evaluator) evalIfStmtNode(node ast.IfStmtNode) (Object, error) {
-	value, err := e.eval(node.Exp)
+	truthy, err := e.evalCondition(node.Exp)
 	if err != nil {
 		return NIL, err
 	}
 
-	if IsTruthy(value) {
+	if truthy {
 		return e.eval(node.TrueStmt)
 	} else if node.FalseStmt != nil {
 		return e.eval(node.FalseStmt)
@@ -219,21 +424,132 @@ func (e *Evaluator) evalIfStmtNode(node ast.IfStmtNode) (Object, error) {
 
 func (e *Evaluator) evalWhileStmtNode(node ast.WhileStmtNode) (Object, error) {
 	for {
-		condition, err := e.eval(node.Condition)
+		truthy, err := e.evalCondition(node.Condition)
 		if err != nil {
 			return NIL, err
 		}
 
-		if !IsTruthy(condition) {
+		if !truthy {
 			break
 		}
 
 		_, err = e.eval(node.Body)
 		if err != nil {
 			switch err := err.(type) {
-			case *BreakError:
+			case BreakError:
 				return NIL, nil
-			case *ContinueError:
+			case ContinueError:
+				continue
+			default:
+				return NIL, err
+			}
+		}
+	}
+	return NIL, nil
+}
+
+func (e *Evaluator) evalDoWhileStmtNode(node ast.DoWhileStmtNode) (Object, error) {
+	for {
+		_, err := e.eval(node.Body)
+		if err != nil {
+			switch err := err.(type) {
+			case BreakError:
+				return NIL, nil
+			case ContinueError:
+			default:
+				return NIL, err
+			}
+		}
+
+		truthy, err := e.evalCondition(node.Condition)
+		if err != nil {
+			return NIL, err
+		}
+
+		if !truthy {
+			break
+		}
+	}
+	return NIL, nil
+}
+
+// evalForStmtNode evaluates the loop in an environment of its own which holds the variables declared by the initializer
+func (e *Evaluator) evalForStmtNode(node ast.ForStmtNode) (Object, error) {
+	prev := e.env
+	defer func() {
+		e.env = prev
+	}()
+
+	e.env = NewEnvironment().WithEnclosing(e.env)
+	if node.Init != nil {
+		if _, err := e.eval(node.Init); err != nil {
+			return NIL, err
+		}
+	}
+
+	for {
+		if node.Condition != nil {
+			truthy, err := e.evalCondition(node.Condition)
+			if err != nil {
+				return NIL, err
+			}
+
+			if !truthy {
+				break
+			}
+		}
+
+		_, err := e.eval(node.Body)
+		if err != nil {
+			switch err := err.(type) {
+			case BreakError:
+				return NIL, nil
+			case ContinueError:
+			default:
+				return NIL, err
+			}
+		}
+
+		if node.Update != nil {
+			if _, err := e.eval(node.Update); err != nil {
+				return NIL, err
+			}
+		}
+	}
+	return NIL, nil
+}
+
+// evalForInStmtNode binds the loop variables in a fresh environment on every iteration
+// so that closures created in the body capture the values of that iteration
+func (e *Evaluator) evalForInStmtNode(node ast.ForInStmtNode) (Object, error) {
+	iterable, err := e.eval(node.Iterable)
+	if err != nil {
+		return NIL, err
+	}
+
+	seq, ok := iterable.(Sequence)
+	if !ok {
+		return NIL, NewEvaluateError(node.Iterable, fmt.Errorf("%s is not iterable", iterable.Type()))
+	}
+
+	it := seq.Iterator()
+	for {
+		values, ok := it.NextVariables(len(node.Variables))
+		if !ok {
+			break
+		}
+
+		env := NewEnvironment().WithEnclosing(e.env)
+		for i, variable := range node.Variables {
+			env.Define(variable.Binding.Slot, values[i])
+		}
+
+		_, err = e.evalWithEnv(node.Body, env)
+		if err != nil {
+			switch err := err.(type) {
+			case BreakError:
+				return NIL, nil
+			case ContinueError:
 				continue
 			default:
 				return NIL, err
@@ -252,12 +568,40 @@ func (e *Evaluator) evalContinueStmtNode(node ast.ContinueStmtNode) (Object, err
 }
 
 func (e *Evaluator) evalAssignmentNode(node ast.AssignmentNode) (Object, error) {
+	var current Object
+	var err error
+	if _, ok := lex.CompoundAssignmentOp(node.Op.Type); ok {
+		current, err = e.eval(node.Identifier)
+		if err != nil {
+			return NIL, err
+		}
+	}
+
+	value, err := e.assignedValue(node.Op, current, node.Value)
+	if err != nil {
+		return NIL, err
+	}
+
+	return NIL, e.assign(node.Identifier, value)
+}
+
+func (e *Evaluator) evalDestructureAssignmentNode(node ast.DestructureAssignmentNode) (Object, error) {
 	value, err := e.eval(node.Value)
 	if err != nil {
 		return NIL, err
 	}
 
-	return NIL, e.env.Assign(node.Identifier.Token.Literal, value)
+	values, err := Unpack(value, len(node.Pattern.Elements))
+	if err != nil {
+		return NIL, err
+	}
+
+	for i, identifier := range node.Pattern.Elements {
+		if err := e.assign(identifier, values[i]); err != nil {
+			return NIL, err
+		}
+	}
+	return NIL, nil
 }
 
 func (e *Evaluator) evalLogicalAndNode(node ast.LogicalAndNode) (Object, error) {
@@ -271,34 +615,30 @@ func (e *Evaluator) evalLogicalAndNode(node ast.LogicalAndNode) (Object, error)
 		return NIL, err
 	}
 
-	return NewBool(IsTruthy(left) && IsTruthy(right)), nil
+	return And(left, right)
 }
 
 func (e *Evaluator) evalLogicalOrNode(node ast.LogicalOrNode) (Object, error) {
-	left, err := e.eval(node.LHS)
+	left, err := e.evalCondition(node.LHS)
 	if err != nil {
 		return NIL, err
 	}
 
-	if IsTruthy(left) {
+	if left {
 		return TRUE, nil
 	}
 
-	right, err := e.eval(node.RHS)
-	if err != nil {
-		return NIL, err
-	}
-
-	return NewBool(IsTruthy(right)), nil
+	right, err := e.evalCondition(node.RHS)
+	return NewBool(right), err
 }
 
 func (e *Evaluator) evalTernaryOpNode(node ast.TernaryOpNode) (Object, error) {
-	value, err := e.eval(node.Exp)
+	truthy, err := e.evalCondition(node.Exp)
 	if err != nil {
 		return NIL, err
 	}
 
-	if IsTruthy(value) {
+	if truthy {
 		return e.eval(node.TrueExp)
 	} else {
 		return e.eval(node.FalseExp)
@@ -316,7 +656,11 @@ func (e *Evaluator) evalBinaryOpNode(node ast.BinaryOpNode) (Object, error) {
 		return NIL, err
 	}
 
-	switch node.Op.Type {
+	return binaryOp(node.Op.Type, left, right)
+}
+
+func binaryOp(op lex.TokenType, left Object, right Object) (Object, error) {
+	switch op {
 	case lex.TT_PLUS:
 		return Add(left, right)
 	case lex.TT_MINUS:
@@ -328,19 +672,38 @@ func (e *Evaluator) evalBinaryOpNode(node ast.BinaryOpNode) (Object, error) {
 	case lex.TT_MODULO:
 		return Modulo(left, right)
 	case lex.TT_EQ:
-		return EqualTo(left, right), nil
+		return EqualTo(left, right)
 	case lex.TT_NEQ:
-		return NotEqualTo(left, right), nil
+		return NotEqualTo(left, right)
 	case lex.TT_LT:
-		return LessThan(left, right), nil
+		return LessThan(left, right)
 	case lex.TT_LTE:
-		return LessThanEq(left, right), nil
+		return LessThanEq(left, right)
 	case lex.TT_GT:
-		return GreaterThan(left, right), nil
+		return GreaterThan(left, right)
 	case lex.TT_GTE:
-		return GreaterThanEq(left, right), nil
+		return GreaterThanEq(left, right)
+	case lex.TT_IN:
+		return In(left, right)
+	case lex.TT_NOT_IN:
+		return NotIn(left, right)
+	}
+	return NIL, fmt.Errorf("invalid binary op: %s", op)
+}
+
+// assignedValue returns the value stored by an assignment, compound assignments such as "+="
+// combine the current value of the target with the assigned value
+// The current value is read before the assigned value is evaluated, current is nil for plain assignments
+func (e *Evaluator) assignedValue(op lex.Token, current Object, node ast.Node) (Object, error) {
+	value, err := e.eval(node)
+	if err != nil {
+		return NIL, err
+	}
+
+	if binOp, ok := lex.CompoundAssignmentOp(op.Type); ok {
+		return binaryOp(binOp, current, value)
 	}
-	return NIL, fmt.Errorf("invalid binary op: %s", node.Op.Type)
+	return value, nil
 }
 
 func (e *Evaluator) evalUnaryOpNode(node ast.UnaryOpNode) (Object, error) {
@@ -359,11 +722,35 @@ func (e *Evaluator) evalUnaryOpNode(node ast.UnaryOpNode) (Object, error) {
 }
 
 func (e *Evaluator) evalIdentifierNode(node ast.IdentifierNode) (Object, error) {
-	return e.env.Get(node.Token.Literal)
+	if binding := node.Binding; binding.Local {
+		return e.env.GetAt(binding.Depth, binding.Slot), nil
+	}
+	return e.globalEnv.Get(node.Token.Literal)
 }
 
 func (e *Evaluator) evalNumberNode(node ast.NumberNode) (Object, error) {
-	val, err := strconv.ParseFloat(node.Token.Literal, 10)
+	switch lex.NumberKindOf(node.Token.Literal) {
+	case lex.NUM_INT:
+		val, err := lex.ParseInt(node.Token.Literal)
+		if err != nil {
+			return NIL, err
+		}
+		return NewInt(val), nil
+	case lex.NUM_BIGINT:
+		val, err := lex.ParseBigInt(node.Token.Literal)
+		if err != nil {
+			return NIL, err
+		}
+		return NewBigInt(val), nil
+	case lex.NUM_DECIMAL:
+		val, scale, err := lex.ParseDecimal(node.Token.Literal)
+		if err != nil {
+			return NIL, err
+		}
+		return NewDecimal(val, scale), nil
+	}
+
+	val, err := lex.ParseFloat(node.Token.Literal)
 	if err != nil {
 		return NIL, err
 	}
@@ -384,6 +771,15 @@ func (e *Evaluator) evalStringNode(node ast.StringNode) (Object, error) {
 	return NewString(node.Token.Literal), nil
 }
 
+func (e *Evaluator) evalInterpolatedStringNode(node ast.InterpolatedStringNode) (Object, error) {
+	values, err := e.evalNodes(node.Segments)
+	if err != nil {
+		return nil, err
+	}
+
+	return Interpolate(values)
+}
+
 func (e *Evaluator) evalListNode(node ast.ListNode) (Object, error) {
 	elements, err := e.evalNodes(node.Elements)
 	if err != nil {
@@ -393,6 +789,32 @@ func (e *Evaluator) evalListNode(node ast.ListNode) (Object, error) {
 	return NewList(elements), nil
 }
 
+func (e *Evaluator) evalTupleNode(node ast.TupleNode) (Object, error) {
+	elements, err := e.evalNodes(node.Elements)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTuple(elements), nil
+}
+
+func (e *Evaluator) evalSetNode(node ast.SetNode) (Object, error) {
+	set := NewSet()
+
+	for _, elem := range node.Elements {
+		value, err := e.eval(elem)
+		if err != nil {
+			return NIL, err
+		}
+
+		if err := set.Add(value); err != nil {
+			return NIL, err
+		}
+	}
+
+	return set, nil
+}
+
 func (e *Evaluator) evalMapNode(node ast.MapNode) (Object, error) {
 	m := NewMap()
 
@@ -407,8 +829,7 @@ func (e *Evaluator) evalMapNode(node ast.MapNode) (Object, error) {
 			return NIL, err
 		}
 
-		m, err = m.Add(key, value)
-		if err != nil {
+		if err := m.Add(key, value); err != nil {
 			return NIL, err
 		}
 	}
@@ -427,7 +848,7 @@ func (e *Evaluator) evalCallNode(node ast.CallNode) (Object, error) {
 
 	callable, ok := calleeNode.(Callable)
 	if !ok { // If the callee node itself isn't callable, check if it's value is callable
-		calleeValue, err := e.env.Get(calleeNode.String())
+		calleeValue, err := e.globalEnv.Get(calleeNode.String())
 		if err != nil {
 			return NIL, fmt.Errorf("%s is not callable", calleeNode.Type())
 		}
@@ -461,12 +882,162 @@ func (e *Evaluator) evalIndexOfNode(node ast.IndexOfNode) (Object, error) {
 		return nil, err
 	}
 
-	return ItemAtIndex(seq, idx)
+	val, err := ItemAtIndex(seq, idx)
+	if err != nil {
+		return NIL, NewEvaluateError(node.Index, err)
+	}
+	return val, nil
+}
+
+func (e *Evaluator) evalGetAttrNode(node ast.GetAttrNode) (Object, error) {
+	obj, err := e.eval(node.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := GetAttr(obj, node.Name.Token.Literal)
+	if err != nil {
+		return NIL, NewEvaluateError(node.Name, err)
+	}
+	return val, nil
+}
+
+func (e *Evaluator) evalSuperNode(node ast.SuperNode) (Object, error) {
+	superclass, err := e.eval(node.Super)
+	if err != nil {
+		return NIL, err
+	}
+
+	this, err := e.eval(node.This)
+	if err != nil {
+		return NIL, err
+	}
+
+	val, err := superclass.(*Class).Bound(this, node.Method.Token.Literal)
+	if err != nil {
+		return NIL, NewEvaluateError(node.Method, err)
+	}
+	return val, nil
+}
+
+func (e *Evaluator) evalSliceNode(node ast.SliceNode) (Object, error) {
+	seq, err := e.eval(node.Sequence)
+	if err != nil {
+		return NIL, err
+	}
+
+	bounds := make([]Object, 0, 3)
+	for _, bound := range []ast.Node{node.Start, node.Stop, node.Step} {
+		if bound == nil {
+			bounds = append(bounds, NIL)
+			continue
+		}
+
+		val, err := e.eval(bound)
+		if err != nil {
+			return NIL, err
+		}
+		bounds = append(bounds, val)
+	}
+
+	return SliceOf(seq, bounds[0], bounds[1], bounds[2])
+}
+
+// evalIndexAssignmentNode evaluates the collection, the index and then the value
+// Nested targets such as m["a"][0] work since the inner collection is shared with the outer one
+func (e *Evaluator) evalIndexAssignmentNode(node ast.IndexAssignmentNode) (Object, error) {
+	seq, err := e.eval(node.Target.Sequence)
+	if err != nil {
+		return NIL, err
+	}
+
+	idx, err := e.eval(node.Target.Index)
+	if err != nil {
+		return NIL, err
+	}
+
+	var current Object
+	if _, ok := lex.CompoundAssignmentOp(node.Op.Type); ok {
+		current, err = ItemAtIndex(seq, idx)
+		if err != nil {
+			return NIL, NewEvaluateError(node.Target.Index, err)
+		}
+	}
+
+	value, err := e.assignedValue(node.Op, current, node.Value)
+	if err != nil {
+		return NIL, err
+	}
+
+	if err := SetItemAtIndex(seq, idx, value); err != nil {
+		return NIL, NewEvaluateError(node.Target.Index, err)
+	}
+	return NIL, nil
+}
+
+func (e *Evaluator) evalAttrAssignmentNode(node ast.AttrAssignmentNode) (Object, error) {
+	obj, err := e.eval(node.Target.Object)
+	if err != nil {
+		return NIL, err
+	}
+
+	name := node.Target.Name.Token.Literal
+	var current Object
+	if _, ok := lex.CompoundAssignmentOp(node.Op.Type); ok {
+		current, err = GetAttr(obj, name)
+		if err != nil {
+			return NIL, NewEvaluateError(node.Target.Name, err)
+		}
+	}
+
+	value, err := e.assignedValue(node.Op, current, node.Value)
+	if err != nil {
+		return NIL, err
+	}
+
+	if err := SetAttr(obj, name, value); err != nil {
+		return NIL, NewEvaluateError(node.Target.Name, err)
+	}
+	return NIL, nil
+}
+
+func (e *Evaluator) evalStructStmtNode(node ast.StructStmtNode) (Object, error) {
+	fields := make([]string, 0, len(node.Fields))
+	for _, field := range node.Fields {
+		fields = append(fields, field.Token.Literal)
+	}
+	return NIL, e.declare(node.Identifier, NewStructType(node.Identifier.Token.Literal, fields))
+}
+
+// evalClassStmtNode closes the methods over an environment binding the superclass to "super"
+func (e *Evaluator) evalClassStmtNode(node ast.ClassStmtNode) (Object, error) {
+	var superclass Object = NIL
+	env := e.env
+	if node.Superclass != nil {
+		var err error
+		if superclass, err = e.eval(node.Superclass); err != nil {
+			return NIL, err
+		}
+
+		env = NewEnvironment().WithEnclosing(e.env)
+		env.Define(0, superclass)
+	}
+
+	methods := make(map[string]Method, len(node.Methods))
+	for _, method := range node.Methods {
+		methods[method.Identifier.Token.Literal] = NewUserFunction(method, env)
+	}
+
+	class, err := NewClass(node.Identifier.Token.Literal, superclass, methods)
+	if err != nil {
+		return NIL, NewEvaluateError(node.Superclass, err)
+	}
+	return NIL, e.declare(node.Identifier, class)
 }
 
 func (e *Evaluator) evalFunctionNode(node ast.FunctionNode) (Object, error) {
 	fun := NewUserFunction(node, e.env)
-	return fun, e.env.Declare(fun.Name(), fun)
+	return fun, e.declare(node.Identifier, fun)
 }
 
 func (e *Evaluator) evalReturnStmtNode(node ast.ReturnStmtNode) (Object, error) {
@@ -479,22 +1050,163 @@ func (e *Evaluator) evalReturnStmtNode(node ast.ReturnStmtNode) (Object, error)
 }
 
 func (e *Evaluator) evalDeferStmtNode(node ast.DeferStmtNode) (Object, error) {
-	e.deferred = append(e.deferred, node.Call)
+	e.deferred = append(e.deferred, deferredCall{call: node.Call, env: NewEnvironment().WithEnclosing(e.env)})
 	return NIL, nil
 }
 
 func (e *Evaluator) evalAssertStmtNode(node ast.AssertStmtNode) (Object, error) {
-	exp, err := e.eval(node.Exp)
+	truthy, err := e.evalCondition(node.Exp)
 	if err != nil {
 		return NIL, err
 	}
 
-	if !IsTruthy(exp) {
+	if !truthy {
 		return NIL, NewAssertError(node.Exp)
 	}
 	return NIL, nil
 }
 
+func (e *Evaluator) evalThrowStmtNode(node ast.ThrowStmtNode) (Object, error) {
+	value, err := e.eval(node.Exp)
+	if err != nil {
+		return NIL, err
+	}
+	return NIL, NewThrowError(value)
+}
+
+// evalTryStmtNode runs the finally block however the try statement is exited, including by
+// break, continue and return. Errors raised by the catch or finally blocks replace the caught error.
+func (e *Evaluator) evalTryStmtNode(node ast.TryStmtNode) (Object, error) {
+	_, err := e.eval(node.Body)
+	if err != nil && node.Catch != nil {
+		if value, ok := Caught(err); ok {
+			env := NewEnvironment().WithEnclosing(e.env)
+			env.Define(node.Variable.Binding.Slot, value)
+			_, err = e.evalWithEnv(node.Catch, env)
+		}
+	}
+
+	if node.Finally != nil {
+		if _, ferr := e.eval(node.Finally); ferr != nil {
+			return NIL, ferr
+		}
+	}
+	return NIL, err
+}
+
+func (e *Evaluator) evalMatchNode(node ast.MatchNode) (Object, error) {
+	value, err := e.eval(node.Value)
+	if err != nil {
+		return NIL, err
+	}
+
+	for _, arm := range node.Arms {
+		captures, ok, err := e.match(arm.Pattern, value, nil)
+		if err != nil {
+			return NIL, err
+		} else if !ok {
+			continue
+		}
+
+		env := NewEnvironment().WithEnclosing(e.env)
+		for i, capture := range ast.Captures(arm.Pattern) {
+			env.Define(capture.Binding.Slot, captures[i])
+		}
+
+		if arm.Guard != nil {
+			guard, err := e.evalWithEnv(arm.Guard, env)
+			if err != nil {
+				return NIL, err
+			} else if truthy, err := IsTruthy(guard); err != nil {
+				return NIL, err
+			} else if !truthy {
+				continue
+			}
+		}
+		return e.evalWithEnv(arm.Body, env)
+	}
+	return NIL, noMatch(value)
+}
+
+// match matches a value against a pattern and appends the values of its captures in the order
+// of ast.Captures. The VM matches patterns with it too so that both engines match alike.
+func (e *Evaluator) match(pattern ast.Node, value Object, captures []Object) ([]Object, bool, error) {
+	switch pattern := pattern.(type) {
+	case ast.CapturePatternNode:
+		if pattern.Type != nil && !IsOfType(value, pattern.Type.(ast.IdentifierNode).Token.Literal) {
+			return captures, false, nil
+		}
+		if pattern.Identifier.Token.Literal != "_" {
+			captures = append(captures, value)
+		}
+		return captures, true, nil
+	case ast.ListMatchPatternNode:
+		var elements []Object
+		switch value := value.(type) {
+		case *List:
+			elements = value.Values
+		case Tuple:
+			elements = value.Values
+		default:
+			return captures, false, nil
+		}
+
+		n := len(pattern.Elements)
+		if len(elements) < n || (pattern.Rest == nil && len(elements) != n) {
+			return captures, false, nil
+		}
+
+		for i, elem := range pattern.Elements {
+			var ok bool
+			var err error
+			if captures, ok, err = e.match(elem, elements[i], captures); !ok || err != nil {
+				return captures, ok, err
+			}
+		}
+
+		if pattern.Rest != nil && pattern.Rest.(ast.IdentifierNode).Token.Literal != "_" {
+			rest := make([]Object, len(elements)-n)
+			copy(rest, elements[n:])
+			captures = append(captures, NewList(rest))
+		}
+		return captures, true, nil
+	case ast.MapMatchPatternNode:
+		m, ok := value.(*Map)
+		if !ok {
+			return captures, false, nil
+		}
+
+		for i, keyNode := range pattern.Keys {
+			key, err := e.eval(keyNode)
+			if err != nil {
+				return captures, false, err
+			}
+
+			hasher, ok := AsHasher(key)
+			if !ok {
+				return captures, false, nil
+			}
+
+			_, kvp, _, err := m.lookup(hasher)
+			if kvp == nil {
+				return captures, false, err
+			}
+
+			if captures, ok, err = e.match(pattern.Values[i], kvp.Value, captures); !ok || err != nil {
+				return captures, ok, err
+			}
+		}
+		return captures, true, nil
+	default: // Literals match values equal to them
+		literal, err := e.eval(pattern)
+		if err != nil {
+			return captures, false, err
+		}
+		eq, err := EqualTo(value, literal)
+		return captures, eq.Value, err
+	}
+}
+
 func (e *Evaluator) evalImportNode(node ast.ImportStmtNode) (Object, error) {
 	m := NewFileModule(node.Name.Token.Literal)
 	return NIL, e.Importer.Import(m)
@@ -0,0 +1,67 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/lex"
+	"github.com/stretchr/testify/assert"
+)
+
+func evaluate(engine Engine, input string) (Object, error) {
+	root, err := ast.New(lex.New(input)).RootNode()
+	if err != nil {
+		return NIL, err
+	}
+
+	e := NewEvaluator().WithEngine(engine)
+	if err := e.Resolve(root); err != nil {
+		return NIL, err
+	}
+	return e.Evaluate(root)
+}
+
+func TestEvaluator_RedeclareGlobalPosition(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  lex.Position
+	}{
+		{name: "var", input: "var str = 1\nvar str = 2", want: lex.Position{Line: 2, Column: 5}},
+		{name: "destructure", input: "var str = 1\nvar [a, str] = [1, 2]", want: lex.Position{Line: 2, Column: 9}},
+		{name: "function", input: "var int = 1\nfun int() {}", want: lex.Position{Line: 2, Column: 5}},
+		{name: "class", input: "var float = 1\nclass float {}", want: lex.Position{Line: 2, Column: 7}},
+		{name: "struct", input: "var decimal = 1\nstruct decimal { x }", want: lex.Position{Line: 2, Column: 8}},
+	}
+	for _, tt := range tests {
+		for _, engine := range []Engine{EngineTree, EngineVM} {
+			t.Run(tt.name+"_"+string(engine), func(t *testing.T) {
+				_, err := evaluate(engine, tt.input)
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), "cannot redeclare symbol")
+				assert.Equal(t, tt.want, innermostError(err.(PositionError)).Begin())
+			})
+		}
+	}
+}
+
+func TestEvaluator_UnknownNestedPatternType(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "list", input: "match ([1, [2]]) { [x, [y: Foo]] => x, _ => 0 }", want: "unknown type: Foo"},
+		{name: "map", input: "match ({\"k\": 1}) { {\"k\": [z: int, w: Bar]} => z, _ => 0 }", want: "unknown type: Bar"},
+		{name: "variable", input: "var Baz = 1\nmatch ([1]) { [x: Baz] => x, _ => 0 }", want: "unknown type: Baz"},
+	}
+	for _, tt := range tests {
+		for _, engine := range []Engine{EngineTree, EngineVM} {
+			t.Run(tt.name+"_"+string(engine), func(t *testing.T) {
+				_, err := evaluate(engine, tt.input)
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), tt.want)
+			})
+		}
+	}
+}
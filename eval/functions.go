@@ -2,16 +2,22 @@ package eval
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math"
+	"math/big"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/compile"
+	"github.com/shreerangdixit/yeti/lex"
 )
 
 var natives = []*NativeFunction{
 	// Time
-	NewNativeFunction("sleep", 1, false, sleepHandler),
+	NewFallibleFunction("sleep", 1, false, sleepHandler),
 	NewNativeFunction("time", 0, false, timeHandler),
 	// Math
 	NewNativeFunction("abs", 1, false, absHandler),
@@ -19,14 +25,28 @@ var natives = []*NativeFunction{
 	NewNativeFunction("min", 2, false, minHandler),
 	NewNativeFunction("avg", 1, false, avgHandler),
 	NewNativeFunction("sqrt", 1, false, sqrtHandler),
+	NewNativeFunction("int", 1, false, intHandler),
+	NewNativeFunction("float", 1, false, floatHandler),
+	NewNativeFunction("bigint", 1, false, bigintHandler),
+	NewNativeFunction("decimal", 1, false, decimalHandler),
 	// Collections
 	NewNativeFunction("len", 1, false, lenHandler),
 	NewNativeFunction("append", 2, false, appendHandler),
+	NewNativeFunction("delete", 2, false, deleteHandler),
+	NewNativeFunction("range", 0, true, rangeHandler),
+	NewNativeFunction("tuple", 0, true, tupleHandler),
+	NewNativeFunction("union", 2, false, unionHandler),
+	NewNativeFunction("intersection", 2, false, intersectionHandler),
+	NewNativeFunction("difference", 2, false, differenceHandler),
+	NewNativeFunction("is_subset", 2, false, isSubsetHandler),
 	// IO
 	NewNativeFunction("print", 0, true, printHandler),
 	NewNativeFunction("println", 0, true, printlnHandler),
+	NewNativeFunction("read_file", 1, false, readFileHandler),
 	// Misc
 	NewNativeFunction("type", 1, false, typeHandler),
+	NewNativeFunction("str", 1, false, strHandler),
+	NewNativeFunction("error", 0, true, errorHandler),
 	NewNativeFunction("zen", 0, false, zenHandler),
 	// OS
 	NewNativeFunction("exit", 1, false, exitHandler),
@@ -62,27 +82,63 @@ func (f *UserFunction) String() string   { return "<fun-" + f.Name() + ">" }
 func (f *UserFunction) Arity() int       { return len(f.node.Parameters) }
 func (f *UserFunction) Variadic() bool   { return false }
 
+// Bind returns a copy of the function whose closure is enclosed by an environment holding "this"
+func (f *UserFunction) Bind(this Object) Callable {
+	env := NewEnvironment().WithEnclosing(f.closure)
+	env.Define(0, this)
+	return NewUserFunction(f.node, env)
+}
+
 func (f *UserFunction) Call(e *Evaluator, args []Object) (Object, error) {
 	// New environment for function call
 	env := NewEnvironment().WithEnclosing(f.closure)
 	for i := range args {
 		// Bind function arguments to values
-		err := env.Declare(f.node.Parameters[i].Token.Literal, args[i])
-		if err != nil {
-			return NIL, err
-		}
+		env.Define(f.node.Parameters[i].Binding.Slot, args[i])
 	}
 
-	val, err := e.evalBlockNodeWithEnv(f.node.Body, env)
-	if err != nil {
-		switch err := err.(type) {
-		case ReturnError:
-			return err.Value, nil
-		default:
-			return NIL, err
+	return e.withDeferred(func() (Object, error) {
+		val, err := e.evalBlockNodeWithEnv(f.node.Body, env)
+		if err != nil {
+			switch err := err.(type) {
+			case ReturnError:
+				return err.Value, nil
+			default:
+				return NIL, err
+			}
 		}
+		return val, err
+	})
+}
+
+// ------------------------------------
+// Compiled function
+// ------------------------------------
+
+type Closure struct {
+	fn    *compile.Function
+	scope *vmScope
+}
+
+func NewClosure(fn *compile.Function, scope *vmScope) *Closure {
+	return &Closure{
+		fn:    fn,
+		scope: scope,
 	}
-	return val, err
+}
+
+func (f *Closure) Type() ObjectType                                 { return TypeFunc }
+func (f *Closure) Name() string                                     { return f.fn.Name }
+func (f *Closure) String() string                                   { return "<fun-" + f.Name() + ">" }
+func (f *Closure) Arity() int                                       { return f.fn.Arity }
+func (f *Closure) Variadic() bool                                   { return false }
+func (f *Closure) Call(e *Evaluator, args []Object) (Object, error) { return e.vm.call(f, args) }
+
+// Bind returns a copy of the closure whose scope is enclosed by a scope holding "this"
+func (f *Closure) Bind(this Object) Callable {
+	scope := newVMScope(1, f.scope)
+	scope.slots[0] = this
+	return NewClosure(f.fn, scope)
 }
 
 // ------------------------------------
@@ -107,6 +163,19 @@ func NewNativeFunction(name string, arity int, variadic bool, handler NativeFunc
 	}
 }
 
+// NewFallibleFunction creates a native which returns a (value, error) tuple instead of failing
+// The error is nil on success, otherwise the value is nil and the error is caught as an error object
+func NewFallibleFunction(name string, arity int, variadic bool, handler NativeFunctionHandler) *NativeFunction {
+	return NewNativeFunction(name, arity, variadic, func(e *Evaluator, args []Object) (Object, error) {
+		val, err := handler(e, args)
+		if err != nil {
+			caught, _ := Caught(err)
+			return NewTuple([]Object{NIL, caught}), nil
+		}
+		return NewTuple([]Object{val, NIL}), nil
+	})
+}
+
 func (f *NativeFunction) Type() ObjectType                                 { return TypeFunc }
 func (f *NativeFunction) Name() string                                     { return f.name }
 func (f *NativeFunction) String() string                                   { return "<native-" + f.name + ">" }
@@ -119,54 +188,59 @@ func (f *NativeFunction) Call(e *Evaluator, args []Object) (Object, error) { ret
 // ------------------------------------
 
 func sleepHandler(e *Evaluator, args []Object) (Object, error) {
-	arg, ok := args[0].(Number)
+	ms, ok := toFloat(args[0])
 	if !ok {
 		return NIL, fmt.Errorf("sleep() expects a number")
+	} else if ms < 0 {
+		return NIL, fmt.Errorf("sleep() expects a non-negative duration")
 	}
 
-	time.Sleep(time.Duration(arg.Value) * time.Millisecond)
+	time.Sleep(time.Duration(ms) * time.Millisecond)
 	return NIL, nil
 }
 
 func timeHandler(e *Evaluator, args []Object) (Object, error) {
 	ms := time.Now().UnixNano() / int64(time.Millisecond)
-	return NewNumber(float64(ms)), nil
+	return NewInt(ms), nil
 }
 
 func absHandler(e *Evaluator, args []Object) (Object, error) {
-	arg, ok := args[0].(Number)
-	if !ok {
-		return NIL, fmt.Errorf("abs() expects a number")
+	switch arg := args[0].(type) {
+	case Int:
+		if arg.Value < 0 {
+			return arg.Negate()
+		}
+		return arg, nil
+	case Number:
+		return NewNumber(math.Abs(arg.Value)), nil
+	case BigInt:
+		return NewBigInt(new(big.Int).Abs(arg.Value)), nil
+	case Decimal:
+		return NewDecimal(new(big.Rat).Abs(arg.Value), arg.Scale), nil
 	}
-	return NewNumber(math.Abs(arg.Value)), nil
+	return NIL, fmt.Errorf("abs() expects a number")
 }
 
 func maxHandler(e *Evaluator, args []Object) (Object, error) {
-	arg1, ok := args[0].(Number)
-	if !ok {
+	if !isNumber(args[0]) || !isNumber(args[1]) {
 		return NIL, fmt.Errorf("max() expects a number")
 	}
 
-	arg2, ok := args[1].(Number)
-	if !ok {
-		return NIL, fmt.Errorf("max() expects a number")
+	if lt, err := LessThan(args[0], args[1]); err != nil || lt.Value {
+		return args[1], err
 	}
-
-	return NewNumber(math.Max(arg1.Value, arg2.Value)), nil
+	return args[0], nil
 }
 
 func minHandler(e *Evaluator, args []Object) (Object, error) {
-	arg1, ok := args[0].(Number)
-	if !ok {
+	if !isNumber(args[0]) || !isNumber(args[1]) {
 		return NIL, fmt.Errorf("min() expects a number")
 	}
 
-	arg2, ok := args[1].(Number)
-	if !ok {
-		return NIL, fmt.Errorf("min() expects a number")
+	if gt, err := GreaterThan(args[0], args[1]); err != nil || gt.Value {
+		return args[1], err
 	}
-
-	return NewNumber(math.Min(arg1.Value, arg2.Value)), nil
+	return args[0], nil
 }
 
 func avgHandler(e *Evaluator, args []Object) (Object, error) {
@@ -175,37 +249,162 @@ func avgHandler(e *Evaluator, args []Object) (Object, error) {
 		return NIL, fmt.Errorf("avg() expects a sequence")
 	}
 
-	sum := NewNumber(0)
-	for _, arg := range seq.Elements() {
-		if num, ok := arg.(Number); ok {
-			s, _ := sum.Add(num)
-			sum = s.(Number)
+	elements, err := Materialize(seq)
+	if err != nil {
+		return NIL, err
+	}
+
+	sum := 0.0
+	for _, arg := range elements {
+		if num, ok := toFloat(arg); ok {
+			sum += num
 		} else {
 			return NIL, fmt.Errorf("avg() expects numbers")
 		}
 	}
 
-	return sum.Divide(seq.Size())
+	return NewNumber(sum).Divide(seq.Size().Float())
 }
 
 func sqrtHandler(e *Evaluator, args []Object) (Object, error) {
-	if num, ok := args[0].(Number); ok {
-		return NewNumber(math.Sqrt(num.Value)), nil
+	if num, ok := toFloat(args[0]); ok {
+		return NewNumber(math.Sqrt(num)), nil
 	}
 	return NIL, fmt.Errorf("sqrt() expects a number")
 }
 
+func intHandler(e *Evaluator, args []Object) (Object, error) {
+	switch arg := args[0].(type) {
+	case Int:
+		return arg, nil
+	case Number:
+		if math.IsNaN(arg.Value) || arg.Value < math.MinInt64 || arg.Value >= math.MaxInt64 {
+			return NIL, fmt.Errorf("cannot convert %s to int", arg)
+		}
+		return NewInt(int64(arg.Value)), nil
+	case BigInt:
+		if val, ok := arg.Int(); ok {
+			return val, nil
+		}
+		return NIL, fmt.Errorf("cannot convert %s to int", arg)
+	case Decimal:
+		if val, ok := arg.Int(); ok {
+			return val, nil
+		}
+		return NIL, fmt.Errorf("cannot convert %s to int", arg)
+	case String:
+		val, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 0, 64)
+		if err != nil {
+			return NIL, fmt.Errorf("cannot convert \"%s\" to int", arg)
+		}
+		return NewInt(val), nil
+	case Bool:
+		if arg.Value {
+			return NewInt(1), nil
+		}
+		return NewInt(0), nil
+	}
+	return NIL, fmt.Errorf("int() expects a number, string or bool")
+}
+
+func floatHandler(e *Evaluator, args []Object) (Object, error) {
+	switch arg := args[0].(type) {
+	case Int:
+		return arg.Float(), nil
+	case Number:
+		return arg, nil
+	case BigInt:
+		return arg.Float(), nil
+	case Decimal:
+		return arg.Float(), nil
+	case String:
+		val, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return NIL, fmt.Errorf("cannot convert \"%s\" to float", arg)
+		}
+		return NewNumber(val), nil
+	case Bool:
+		if arg.Value {
+			return NewNumber(1), nil
+		}
+		return NewNumber(0), nil
+	}
+	return NIL, fmt.Errorf("float() expects a number, string or bool")
+}
+
+func bigintHandler(e *Evaluator, args []Object) (Object, error) {
+	switch arg := args[0].(type) {
+	case Int:
+		return arg.BigInt(), nil
+	case BigInt:
+		return arg, nil
+	case Number:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return NIL, fmt.Errorf("cannot convert %s to bigint", arg)
+		}
+		val, _ := big.NewFloat(arg.Value).Int(nil)
+		return NewBigInt(val), nil
+	case Decimal:
+		return arg.BigInt(), nil
+	case String:
+		val, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 0)
+		if !ok {
+			return NIL, fmt.Errorf("cannot convert \"%s\" to bigint", arg)
+		}
+		return NewBigInt(val), nil
+	}
+	return NIL, fmt.Errorf("bigint() expects a number or string")
+}
+
+// decimalHandler converts floats using their shortest representation so that decimal(0.1) is 0.1
+func decimalHandler(e *Evaluator, args []Object) (Object, error) {
+	switch arg := args[0].(type) {
+	case Int:
+		return arg.Decimal(), nil
+	case BigInt:
+		return arg.Decimal(), nil
+	case Decimal:
+		return arg, nil
+	case Number:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return NIL, fmt.Errorf("cannot convert %s to decimal", arg)
+		}
+		return parseDecimal(strconv.FormatFloat(arg.Value, 'f', -1, 64))
+	case String:
+		return parseDecimal(strings.TrimSpace(arg.Value))
+	}
+	return NIL, fmt.Errorf("decimal() expects a number or string")
+}
+
+func parseDecimal(s string) (Object, error) {
+	val, scale, err := lex.ParseDecimal(s)
+	if err != nil {
+		return NIL, fmt.Errorf("cannot convert \"%s\" to decimal", s)
+	}
+	return NewDecimal(val, scale), nil
+}
+
+func strHandler(e *Evaluator, args []Object) (Object, error) {
+	s, err := ToString(args[0])
+	if err != nil {
+		return NIL, err
+	}
+	return NewString(s), nil
+}
+
 func typeHandler(e *Evaluator, args []Object) (Object, error) {
 	arg := args[0]
 	return NewType(arg.Type()), nil
 }
 
 func lenHandler(e *Evaluator, args []Object) (Object, error) {
-	arg, ok := args[0].(Sequence)
-	if !ok {
-		return NIL, fmt.Errorf("len() expects a sequence")
+	switch arg := args[0].(type) {
+	case Sequence:
+		return arg.Size(), nil
+	case Lengther:
+		return arg.Len()
 	}
-	return arg.Size(), nil
+	return NIL, fmt.Errorf("len() expects a sequence")
 }
 
 func appendHandler(e *Evaluator, args []Object) (Object, error) {
@@ -213,7 +412,7 @@ func appendHandler(e *Evaluator, args []Object) (Object, error) {
 	if !ok {
 		return NIL, fmt.Errorf("append() expectes a sequence")
 	}
-	if list, ok := args[1].(List); ok {
+	if list, ok := args[1].(*List); ok {
 		retval := seq
 		var err error
 		for _, elem := range list.Elements() {
@@ -228,19 +427,199 @@ func appendHandler(e *Evaluator, args []Object) (Object, error) {
 	}
 }
 
+// deleteHandler removes a key from a map, returning whether the key was present
+func deleteHandler(e *Evaluator, args []Object) (Object, error) {
+	m, ok := args[0].(*Map)
+	if !ok {
+		return NIL, fmt.Errorf("delete() expects a map")
+	}
+	key, ok := AsHasher(args[1])
+	if !ok {
+		return NIL, fmt.Errorf("key type '%s' is not hashable", args[1].Type())
+	}
+	deleted, err := m.Delete(key)
+	return NewBool(deleted), err
+}
+
+// rangeHandler accepts range(stop), range(start, stop) and range(start, stop, step)
+func rangeHandler(e *Evaluator, args []Object) (Object, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return NIL, fmt.Errorf("range() expects 1 to 3 arguments, %d provided", len(args))
+	}
+
+	bounds := make([]int64, 0, 3)
+	for _, arg := range args {
+		i, ok := arg.(Int)
+		if !ok {
+			return NIL, fmt.Errorf("range() expects ints, got %s", arg.Type())
+		}
+		bounds = append(bounds, i.Value)
+	}
+
+	switch len(bounds) {
+	case 1:
+		return NewRange(0, bounds[0], 1), nil
+	case 2:
+		return newCheckedRange(bounds[0], bounds[1], 1)
+	}
+
+	if bounds[2] == 0 {
+		return NIL, fmt.Errorf("range() step cannot be zero")
+	}
+	return newCheckedRange(bounds[0], bounds[1], bounds[2])
+}
+
+// newCheckedRange refuses ranges whose length does not fit in an int
+func newCheckedRange(start int64, stop int64, step int64) (Object, error) {
+	r := NewRange(start, stop, step)
+	if r.count() > math.MaxInt64 {
+		return NIL, fmt.Errorf("%s has too many elements", r)
+	}
+	return r, nil
+}
+
+// tupleHandler accepts tuple() and tuple(sequence), maps produce a tuple of their keys
+func tupleHandler(e *Evaluator, args []Object) (Object, error) {
+	if len(args) > 1 {
+		return NIL, fmt.Errorf("tuple() expects at most 1 argument, %d provided", len(args))
+	} else if len(args) == 0 {
+		return NewTuple([]Object{}), nil
+	}
+
+	seq, ok := args[0].(Sequence)
+	if !ok {
+		return NIL, fmt.Errorf("tuple() expects a sequence")
+	} else if r, ok := seq.(Range); ok {
+		elements, err := Materialize(r)
+		if err != nil {
+			return NIL, err
+		}
+		return NewTuple(elements), nil
+	}
+
+	values := make([]Object, 0, seq.Size().Value)
+	it := seq.Iterator()
+	for {
+		vars, ok := it.NextVariables(1)
+		if !ok {
+			break
+		}
+		values = append(values, vars[0])
+	}
+	return NewTuple(values), nil
+}
+
+func unionHandler(e *Evaluator, args []Object) (Object, error) {
+	left, right, err := setArgs("union", args)
+	if err != nil {
+		return NIL, err
+	}
+	set, err := left.Union(right)
+	if err != nil {
+		return NIL, err
+	}
+	return set, nil
+}
+
+func intersectionHandler(e *Evaluator, args []Object) (Object, error) {
+	left, right, err := setArgs("intersection", args)
+	if err != nil {
+		return NIL, err
+	}
+	set, err := left.Intersection(right)
+	if err != nil {
+		return NIL, err
+	}
+	return set, nil
+}
+
+func differenceHandler(e *Evaluator, args []Object) (Object, error) {
+	left, right, err := setArgs("difference", args)
+	if err != nil {
+		return NIL, err
+	}
+	set, err := left.Difference(right)
+	if err != nil {
+		return NIL, err
+	}
+	return set, nil
+}
+
+func isSubsetHandler(e *Evaluator, args []Object) (Object, error) {
+	left, right, err := setArgs("is_subset", args)
+	if err != nil {
+		return NIL, err
+	}
+	subset, err := left.IsSubset(right)
+	return NewBool(subset), err
+}
+
+// setArgs checks the arguments of the set algebra functions
+func setArgs(name string, args []Object) (*Set, *Set, error) {
+	left, ok := args[0].(*Set)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s() expects sets, got %s", name, args[0].Type())
+	}
+	right, ok := args[1].(*Set)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s() expects sets, got %s", name, args[1].Type())
+	}
+	return left, right, nil
+}
+
 func printHandler(e *Evaluator, args []Object) (Object, error) {
 	for _, obj := range args {
-		fmt.Print(obj)
+		s, err := ToString(obj)
+		if err != nil {
+			return NIL, err
+		}
+		fmt.Print(s)
 	}
 	return NIL, nil
 }
 
 func printlnHandler(e *Evaluator, args []Object) (Object, error) {
-	_, _ = printHandler(e, args)
+	if _, err := printHandler(e, args); err != nil {
+		return NIL, err
+	}
 	fmt.Println()
 	return NIL, nil
 }
 
+func readFileHandler(e *Evaluator, args []Object) (Object, error) {
+	path, ok := args[0].(String)
+	if !ok {
+		return NIL, fmt.Errorf("read_file() expects a string")
+	}
+
+	data, err := ioutil.ReadFile(path.Value)
+	if err != nil {
+		return NIL, NewKindedError(KindIO, fmt.Errorf("cannot read file \"%s\"", path.Value))
+	}
+	return NewString(string(data)), nil
+}
+
+// errorHandler accepts error(message) and error(message, kind), errors are of kind "error" by default
+func errorHandler(e *Evaluator, args []Object) (Object, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return NIL, fmt.Errorf("error() expects 1 or 2 arguments, %d provided", len(args))
+	}
+
+	strs := make([]string, 0, 2)
+	for _, arg := range args {
+		s, ok := arg.(String)
+		if !ok {
+			return NIL, fmt.Errorf("error() expects strings, got %s", arg.Type())
+		}
+		strs = append(strs, s.Value)
+	}
+
+	if len(strs) == 1 {
+		return NewError(strs[0], KindError), nil
+	}
+	return NewError(strs[0], strs[1]), nil
+}
+
 func zenHandler(e *Evaluator, args []Object) (Object, error) {
 	fmt.Println(`
 				----------------
@@ -260,7 +639,7 @@ func zenHandler(e *Evaluator, args []Object) (Object, error) {
 
 func exitHandler(e *Evaluator, args []Object) (Object, error) {
 	arg0 := args[0]
-	if code, ok := arg0.(Number); ok {
+	if code, ok := arg0.(Int); ok {
 		os.Exit(int(code.Value))
 		return NIL, nil
 	} else {
@@ -10,6 +10,7 @@ import (
 
 type Importer struct {
 	importSet map[FileModule]struct{}
+	loaded    map[FileModule]ast.Node
 	latest    *FileModule
 	eval      *Evaluator
 }
@@ -17,6 +18,7 @@ type Importer struct {
 func NewImporter(eval *Evaluator) *Importer {
 	return &Importer{
 		importSet: map[FileModule]struct{}{},
+		loaded:    map[FileModule]ast.Node{},
 		latest:    nil,
 		eval:      eval,
 	}
@@ -28,30 +30,56 @@ func (i *Importer) Import(m *FileModule) error {
 		return nil
 	}
 
-	cmds, err := m.Data()
+	root, err := i.Load(m)
 	if err != nil {
 		return err
 	}
 
-	root, err := ast.New(lex.New(string(cmds))).RootNode()
+	i.latest = m
+	i.importSet[*m] = struct{}{}
+	_, err = i.eval.Evaluate(root)
 	if err != nil {
-		if formatter, ok := NewErrorFormatter(err, m); ok {
+		if formatter, ok := NewErrorFormatter(err, i.latest); ok {
 			fmt.Fprintf(os.Stderr, "%s", formatter.Format())
 			os.Exit(1)
 		}
 		return err
 	}
 
-	i.latest = m
-	i.importSet[*m] = struct{}{}
-	_, err = i.eval.Evaluate(root)
+	return nil
+}
+
+// Load parses and resolves a module without evaluating it
+// Modules are loaded once, a module that is still being loaded (an import cycle) is ignored
+func (i *Importer) Load(m *FileModule) (ast.Node, error) {
+	if root, ok := i.loaded[*m]; ok {
+		return root, nil
+	}
+	i.loaded[*m] = nil
+
+	cmds, err := m.Data()
 	if err != nil {
-		if formatter, ok := NewErrorFormatter(err, i.latest); ok {
+		return nil, err
+	}
+
+	root, err := ast.New(lex.New(string(cmds))).RootNode()
+	if err == nil {
+		err = i.eval.resolver.Fork().Resolve(root)
+	}
+
+	if err != nil {
+		if formatter, ok := NewErrorFormatter(err, m); ok {
 			fmt.Fprintf(os.Stderr, "%s", formatter.Format())
 			os.Exit(1)
 		}
-		return err
+		return nil, err
 	}
 
-	return nil
+	i.loaded[*m] = root
+	return root, nil
+}
+
+func (i *Importer) resolveImport(path string) error {
+	_, err := i.Load(NewFileModule(path))
+	return err
 }
@@ -0,0 +1,256 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// methods holds the methods of the built-in types, keyed by type and method name
+// Method handlers receive the object the method was called on as their first argument,
+// the arity of a method doesn't include it
+var methods = map[ObjectType]map[string]*NativeFunction{
+	TypeString: methodTable(
+		NewNativeFunction("upper", 0, false, stringUpperMethod),
+		NewNativeFunction("lower", 0, false, stringLowerMethod),
+		NewNativeFunction("trim", 0, false, stringTrimMethod),
+		NewNativeFunction("split", 1, false, stringSplitMethod),
+		NewNativeFunction("replace", 2, false, stringReplaceMethod),
+		NewNativeFunction("starts_with", 1, false, stringStartsWithMethod),
+		NewNativeFunction("ends_with", 1, false, stringEndsWithMethod),
+		NewNativeFunction("find", 1, false, stringFindMethod),
+	),
+	TypeList: methodTable(
+		NewNativeFunction("push", 1, false, listPushMethod),
+		NewNativeFunction("pop", 0, false, listPopMethod),
+		NewNativeFunction("join", 1, false, listJoinMethod),
+		NewNativeFunction("find", 1, false, listFindMethod),
+	),
+	TypeMap: methodTable(
+		NewNativeFunction("keys", 0, false, mapKeysMethod),
+		NewNativeFunction("values", 0, false, mapValuesMethod),
+		NewNativeFunction("items", 0, false, mapItemsMethod),
+		NewNativeFunction("get", 2, false, mapGetMethod),
+		NewNativeFunction("delete", 1, false, mapDeleteMethod),
+	),
+}
+
+func methodTable(fns ...*NativeFunction) map[string]*NativeFunction {
+	table := make(map[string]*NativeFunction, len(fns))
+	for _, fn := range fns {
+		table[fn.Name()] = fn
+	}
+	return table
+}
+
+// GetAttr looks up an attribute of an object, methods are returned bound to the object
+func GetAttr(o Object, name string) (Object, error) {
+	if getter, ok := o.(AttrGetter); ok {
+		return getter.GetAttr(name)
+	} else if method, ok := methods[o.Type()][name]; ok {
+		return NewBoundMethod(o, method), nil
+	}
+	return NIL, fmt.Errorf("%s has no attribute '%s'", o.Type(), name)
+}
+
+// SetAttr assigns an attribute of an object
+func SetAttr(o Object, name string, value Object) error {
+	if setter, ok := o.(AttrSetter); ok {
+		return setter.SetAttr(name, value)
+	}
+	return fmt.Errorf("%s does not support attribute assignment", o.Type())
+}
+
+// ------------------------------------
+// Bound method
+// ------------------------------------
+
+// BoundMethod is a method of a built-in type along with the object it was looked up on
+// Implements the following interfaces
+// Object
+// Callable
+type BoundMethod struct {
+	receiver Object
+	method   *NativeFunction
+}
+
+func NewBoundMethod(receiver Object, method *NativeFunction) *BoundMethod {
+	return &BoundMethod{receiver: receiver, method: method}
+}
+
+func (f *BoundMethod) Type() ObjectType { return TypeFunc }
+func (f *BoundMethod) Name() string     { return f.method.Name() }
+func (f *BoundMethod) Arity() int       { return f.method.Arity() }
+func (f *BoundMethod) Variadic() bool   { return f.method.Variadic() }
+
+func (f *BoundMethod) String() string {
+	return fmt.Sprintf("<method-%s.%s>", f.receiver.Type(), f.method.Name())
+}
+
+func (f *BoundMethod) Call(e *Evaluator, args []Object) (Object, error) {
+	return f.method.Call(e, append([]Object{f.receiver}, args...))
+}
+
+// ------------------------------------
+// String methods
+// ------------------------------------
+
+func stringUpperMethod(e *Evaluator, args []Object) (Object, error) {
+	return NewString(strings.ToUpper(args[0].(String).Value)), nil
+}
+
+func stringLowerMethod(e *Evaluator, args []Object) (Object, error) {
+	return NewString(strings.ToLower(args[0].(String).Value)), nil
+}
+
+func stringTrimMethod(e *Evaluator, args []Object) (Object, error) {
+	return NewString(strings.TrimSpace(args[0].(String).Value)), nil
+}
+
+func stringSplitMethod(e *Evaluator, args []Object) (Object, error) {
+	sep, ok := args[1].(String)
+	if !ok {
+		return NIL, fmt.Errorf("split() expects a string separator")
+	}
+
+	parts := strings.Split(args[0].(String).Value, sep.Value)
+	values := make([]Object, 0, len(parts))
+	for _, part := range parts {
+		values = append(values, NewString(part))
+	}
+	return NewList(values), nil
+}
+
+func stringReplaceMethod(e *Evaluator, args []Object) (Object, error) {
+	old, ok := args[1].(String)
+	if !ok {
+		return NIL, fmt.Errorf("replace() expects strings")
+	}
+	replacement, ok := args[2].(String)
+	if !ok {
+		return NIL, fmt.Errorf("replace() expects strings")
+	}
+	return NewString(strings.ReplaceAll(args[0].(String).Value, old.Value, replacement.Value)), nil
+}
+
+func stringStartsWithMethod(e *Evaluator, args []Object) (Object, error) {
+	prefix, ok := args[1].(String)
+	if !ok {
+		return NIL, fmt.Errorf("starts_with() expects a string")
+	}
+	return NewBool(strings.HasPrefix(args[0].(String).Value, prefix.Value)), nil
+}
+
+func stringEndsWithMethod(e *Evaluator, args []Object) (Object, error) {
+	suffix, ok := args[1].(String)
+	if !ok {
+		return NIL, fmt.Errorf("ends_with() expects a string")
+	}
+	return NewBool(strings.HasSuffix(args[0].(String).Value, suffix.Value)), nil
+}
+
+// stringFindMethod returns the character index of the first occurrence of a substring, or -1
+func stringFindMethod(e *Evaluator, args []Object) (Object, error) {
+	sub, ok := args[1].(String)
+	if !ok {
+		return NIL, fmt.Errorf("find() expects a string")
+	}
+
+	s := args[0].(String).Value
+	idx := strings.Index(s, sub.Value)
+	if idx < 0 {
+		return NewInt(-1), nil
+	}
+	return NewInt(int64(len([]rune(s[:idx])))), nil
+}
+
+// ------------------------------------
+// List methods
+// ------------------------------------
+
+// listPushMethod appends to a list in place, unlike append() which returns a new list
+func listPushMethod(e *Evaluator, args []Object) (Object, error) {
+	list := args[0].(*List)
+	list.Values = append(list.Values, args[1])
+	return NIL, nil
+}
+
+func listPopMethod(e *Evaluator, args []Object) (Object, error) {
+	list := args[0].(*List)
+	if len(list.Values) == 0 {
+		return NIL, fmt.Errorf("pop() from an empty list")
+	}
+
+	last := list.Values[len(list.Values)-1]
+	list.Values = list.Values[:len(list.Values)-1]
+	return last, nil
+}
+
+func listJoinMethod(e *Evaluator, args []Object) (Object, error) {
+	sep, ok := args[1].(String)
+	if !ok {
+		return NIL, fmt.Errorf("join() expects a string separator")
+	}
+
+	strs, err := formatAll(args[0].(*List).Values, ToString)
+	if err != nil {
+		return NIL, err
+	}
+	return NewString(strings.Join(strs, sep.Value)), nil
+}
+
+// listFindMethod returns the index of the first element equal to the argument, or -1
+func listFindMethod(e *Evaluator, args []Object) (Object, error) {
+	for i, value := range args[0].(*List).Values {
+		if eq, err := EqualTo(value, args[1]); err != nil {
+			return NIL, err
+		} else if eq.Value {
+			return NewInt(int64(i)), nil
+		}
+	}
+	return NewInt(-1), nil
+}
+
+// ------------------------------------
+// Map methods
+// ------------------------------------
+
+func mapKeysMethod(e *Evaluator, args []Object) (Object, error) {
+	pairs := args[0].(*Map).Pairs()
+	keys := make([]Object, 0, len(pairs))
+	for _, kvp := range pairs {
+		keys = append(keys, kvp.Key)
+	}
+	return NewList(keys), nil
+}
+
+func mapValuesMethod(e *Evaluator, args []Object) (Object, error) {
+	return NewList(args[0].(*Map).Elements()), nil
+}
+
+// mapItemsMethod returns the key-value pairs of a map as a list of (key, value) tuples
+func mapItemsMethod(e *Evaluator, args []Object) (Object, error) {
+	pairs := args[0].(*Map).Pairs()
+	items := make([]Object, 0, len(pairs))
+	for _, kvp := range pairs {
+		items = append(items, NewTuple([]Object{kvp.Key, kvp.Value}))
+	}
+	return NewList(items), nil
+}
+
+// mapGetMethod returns the value of a key, or the given default when the key is missing
+func mapGetMethod(e *Evaluator, args []Object) (Object, error) {
+	m := args[0].(*Map)
+	found, err := m.Contains(args[1])
+	if err != nil {
+		return NIL, err
+	} else if !found.Value {
+		return args[2], nil
+	}
+
+	key, _ := AsHasher(args[1])
+	return m.Map(key)
+}
+
+func mapDeleteMethod(e *Evaluator, args []Object) (Object, error) {
+	return deleteHandler(e, args)
+}
@@ -2,21 +2,44 @@ package eval
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
 )
 
 type ObjectType string
 
 const (
-	TypeNumber ObjectType = "number"
-	TypeBool   ObjectType = "bool"
-	TypeString ObjectType = "string"
-	TypeFunc   ObjectType = "function"
-	TypeNil    ObjectType = "null"
-	TypeType   ObjectType = "type"
-	TypeList   ObjectType = "list"
-	TypeMap    ObjectType = "map"
+	TypeNumber  ObjectType = "number"
+	TypeInt     ObjectType = "int"
+	TypeBigInt  ObjectType = "bigint"
+	TypeDecimal ObjectType = "decimal"
+	TypeBool    ObjectType = "bool"
+	TypeString  ObjectType = "string"
+	TypeFunc    ObjectType = "function"
+	TypeNil     ObjectType = "null"
+	TypeType    ObjectType = "type"
+	TypeList    ObjectType = "list"
+	TypeTuple   ObjectType = "tuple"
+	TypeMap     ObjectType = "map"
+	TypeSet     ObjectType = "set"
+	TypeRange   ObjectType = "range"
+	TypeIter    ObjectType = "iterator"
+	TypeStruct  ObjectType = "struct"
+	TypeClass   ObjectType = "class"
+	TypeError   ObjectType = "error"
 )
 
+// TypeNames returns the names of the built-in types which type patterns can match
+func TypeNames() []string {
+	return []string{
+		string(TypeNumber), string(TypeInt), string(TypeBigInt), string(TypeDecimal), string(TypeBool),
+		string(TypeString), string(TypeFunc), string(TypeNil), string(TypeType), string(TypeList),
+		string(TypeTuple), string(TypeMap), string(TypeSet), string(TypeRange), string(TypeIter),
+		string(TypeStruct), string(TypeClass), string(TypeError),
+	}
+}
+
 // ------------------------------------
 // Type interfaces
 // ------------------------------------
@@ -34,16 +57,38 @@ type Callable interface {
 	Call(*Evaluator, []Object) (Object, error)
 }
 
+// Method is a function declared in a class, binding it to an instance makes "this" refer to the instance
+type Method interface {
+	Callable
+	Bind(this Object) Callable
+}
+
 type Sequence interface {
 	Object
-	Size() Number
+	Size() Int
 	Elements() []Object
 	Append(Object) (Sequence, error)
+	Iterator() *Iterator
 }
 
 type Hasher interface {
 	Object
-	Hash() uint32
+	Hash() (uint32, error)
+}
+
+type AttrGetter interface {
+	Object
+	GetAttr(name string) (Object, error)
+}
+
+type AttrSetter interface {
+	Object
+	SetAttr(name string, value Object) error
+}
+
+type Container interface {
+	Object
+	Contains(Object) (Bool, error)
 }
 
 type Mapper interface {
@@ -51,9 +96,36 @@ type Mapper interface {
 	Map(Hasher) (Object, error)
 }
 
+// Subscripter is implemented by objects which accept indices of any type
+type Subscripter interface {
+	Object
+	Subscript(Object) (Object, error)
+}
+
+// Lengther is implemented by objects which have a length without being sequences
+type Lengther interface {
+	Object
+	Len() (Int, error)
+}
+
 type Indexer interface {
 	Object
-	Index(Number) (Object, error)
+	Index(Int) (Object, error)
+}
+
+type Slicer interface {
+	Sequence
+	Slice(start int, stop int, step int) Object
+}
+
+type IndexSetter interface {
+	Indexer
+	SetIndex(Int, Object) error
+}
+
+type MapSetter interface {
+	Mapper
+	Set(Hasher, Object) error
 }
 
 type Truthifier interface {
@@ -98,85 +170,127 @@ type Divider interface {
 
 type LessThanComparator interface {
 	Object
-	LessThan(Object) Bool
+	LessThan(Object) (Bool, error)
 }
 
 type GreaterThanComparator interface {
 	Object
-	GreaterThan(Object) Bool
+	GreaterThan(Object) (Bool, error)
 }
 
 type EqualToComparator interface {
 	Object
-	EqualTo(Object) Bool
+	EqualTo(Object) (Bool, error)
 }
 
 // ------------------------------------
 // Type functions
 // ------------------------------------
 
-func IsTruthy(o Object) bool {
-	if truthy, ok := o.(Truthifier); ok {
-		return truthy.Truthy().Value
+// IsTruthy reports whether an object counts as true, instances decide with __bool__
+func IsTruthy(o Object) (bool, error) {
+	if instance, ok := o.(*Instance); ok {
+		truthy, err := instance.truthy()
+		return truthy.Value, err
+	} else if truthy, ok := o.(Truthifier); ok {
+		return truthy.Truthy().Value, nil
 	}
-	return false
+	return false, nil
+}
+
+// ToString returns the string representation of an object for print() and str()
+// Unlike String() it reports errors raised by __str__, including those of instances held by collections
+func ToString(o Object) (string, error) {
+	switch o := o.(type) {
+	case *Instance:
+		return o.str()
+	case *List:
+		return o.format(ToString)
+	case Tuple:
+		return o.format(ToString)
+	case *Map:
+		return o.format(ToString)
+	case *Set:
+		return o.format(ToString)
+	case *StructInstance:
+		return o.format(ToString)
+	}
+	return o.String(), nil
+}
+
+// Interpolate concatenates the string representation of the segments of an interpolated string
+func Interpolate(segments []Object) (String, error) {
+	var sb strings.Builder
+	for _, segment := range segments {
+		s, err := ToString(segment)
+		if err != nil {
+			return NewString(""), err
+		}
+		sb.WriteString(s)
+	}
+	return NewString(sb.String()), nil
 }
 
 func Add(left Object, right Object) (Object, error) {
+	left, right = promote(left, right)
 	if err := checkTypeCompat(left, right); err != nil {
 		return NIL, err
 	}
 
 	if adder, ok := left.(Adder); ok {
-		return adder.Add(right.(Adder))
+		return adder.Add(right)
 	}
 
 	return NIL, fmt.Errorf("Cannot add types %s", left.Type())
 }
 
 func Subtract(left Object, right Object) (Object, error) {
+	left, right = promote(left, right)
 	if err := checkTypeCompat(left, right); err != nil {
 		return NIL, err
 	}
 
 	if subtractor, ok := left.(Subtractor); ok {
-		return subtractor.Subtract(right.(Subtractor))
+		return subtractor.Subtract(right)
 	}
 
 	return NIL, fmt.Errorf("Cannot subtract type %s", left.Type())
 }
 
 func Divide(left Object, right Object) (Object, error) {
+	left, right = promote(left, right)
 	if err := checkTypeCompat(left, right); err != nil {
 		return NIL, err
 	}
 
 	if divider, ok := left.(Divider); ok {
-		return divider.Divide(right.(Divider))
+		return divider.Divide(right)
 	}
 
 	return NIL, fmt.Errorf("Cannot divide type %s", left.Type())
 }
 
 func Multiply(left Object, right Object) (Object, error) {
+	left, right = promote(left, right)
 	if err := checkTypeCompat(left, right); err != nil {
 		return NIL, err
 	}
 
 	if multiplier, ok := left.(Multiplier); ok {
-		return multiplier.Multiply(right.(Multiplier))
+		return multiplier.Multiply(right)
 	}
 
 	return NIL, fmt.Errorf("Cannot multiply type %s", left.Type())
 }
 
 func Modulo(left Object, right Object) (Object, error) {
+	left, right = promote(left, right)
 	if err := checkTypeCompat(left, right); err != nil {
 		return NIL, err
 	}
 
 	if modulator, ok := left.(Modulator); ok {
-		return modulator.Modulo(right.(Modulator))
+		return modulator.Modulo(right)
 	}
 
 	return NIL, fmt.Errorf("Cannot multiply type %s", left.Type())
@@ -196,64 +310,116 @@ func Not(o Object) (Object, error) {
 	return NIL, fmt.Errorf("Cannot not type %s", o.Type())
 }
 
-func EqualTo(left Object, right Object) Bool {
+// And reports whether both operands are truthy, it backs the && operator which evaluates both of them
+func And(left Object, right Object) (Object, error) {
+	l, err := IsTruthy(left)
+	if err != nil || !l {
+		return FALSE, err
+	}
+	r, err := IsTruthy(right)
+	return NewBool(r), err
+}
+
+func EqualTo(left Object, right Object) (Bool, error) {
+	if eq, ok := compareExact(left, right, func(cmp int) bool { return cmp == 0 }); ok {
+		return eq, nil
+	}
+
+	left, right = promote(left, right)
 	if err := checkTypeCompat(left, right); err != nil {
-		return FALSE
+		return FALSE, nil
 	}
 
 	if eqto, ok := left.(EqualToComparator); ok {
-		return eqto.EqualTo(right.(EqualToComparator))
+		return eqto.EqualTo(right)
 	}
 
-	return FALSE
+	return FALSE, nil
 }
 
-func NotEqualTo(left Object, right Object) Bool {
-	return NewBool(!EqualTo(left, right).Value)
+// In reports whether the left operand is an element of the right operand
+func In(left Object, right Object) (Object, error) {
+	if container, ok := right.(Container); ok {
+		return container.Contains(left)
+	}
+	return NIL, fmt.Errorf("'in' is not supported on %s", right.Type())
 }
 
-func LessThan(left Object, right Object) Bool {
+func NotIn(left Object, right Object) (Object, error) {
+	found, err := In(left, right)
+	if err != nil {
+		return NIL, err
+	}
+	return Not(found)
+}
+
+func NotEqualTo(left Object, right Object) (Bool, error) {
+	eq, err := EqualTo(left, right)
+	return NewBool(!eq.Value), err
+}
+
+func LessThan(left Object, right Object) (Bool, error) {
+	if lt, ok := compareExact(left, right, func(cmp int) bool { return cmp < 0 }); ok {
+		return lt, nil
+	}
+
+	left, right = promote(left, right)
 	if err := checkTypeCompat(left, right); err != nil {
-		return FALSE
+		return FALSE, nil
 	}
 
 	if lt, ok := left.(LessThanComparator); ok {
-		return lt.LessThan(right.(LessThanComparator))
+		return lt.LessThan(right)
 	}
 
-	return FALSE
+	return FALSE, nil
 }
 
-func LessThanEq(left Object, right Object) Bool {
-	return NewBool(LessThan(left, right).Value || EqualTo(left, right).Value)
+func LessThanEq(left Object, right Object) (Bool, error) {
+	return orEqualTo(LessThan, left, right)
 }
 
-func GreaterThan(left Object, right Object) Bool {
+func GreaterThan(left Object, right Object) (Bool, error) {
+	if gt, ok := compareExact(left, right, func(cmp int) bool { return cmp > 0 }); ok {
+		return gt, nil
+	}
+
+	left, right = promote(left, right)
 	if err := checkTypeCompat(left, right); err != nil {
-		return FALSE
+		return FALSE, nil
 	}
 
 	if gt, ok := left.(GreaterThanComparator); ok {
-		return gt.GreaterThan(right.(GreaterThanComparator))
+		return gt.GreaterThan(right)
 	}
 
-	return FALSE
+	return FALSE, nil
 }
 
-func GreaterThanEq(left Object, right Object) Bool {
-	return NewBool(GreaterThan(left, right).Value || EqualTo(left, right).Value)
+func GreaterThanEq(left Object, right Object) (Bool, error) {
+	return orEqualTo(GreaterThan, left, right)
+}
+
+// orEqualTo combines a strict comparison with EqualTo, equality is only checked when the comparison is false
+func orEqualTo(cmp func(Object, Object) (Bool, error), left Object, right Object) (Bool, error) {
+	if result, err := cmp(left, right); err != nil || result.Value {
+		return result, err
+	}
+	return EqualTo(left, right)
 }
 
 func ItemAtIndex(o Object, idx Object) (Object, error) {
-	if idxr, ok := o.(Indexer); ok {
-		i, ok := idx.(Number)
+	if subscripter, ok := o.(Subscripter); ok {
+		return subscripter.Subscript(idx)
+	} else if idxr, ok := o.(Indexer); ok {
+		i, ok := idx.(Int)
 		if !ok {
-			return NIL, fmt.Errorf("index must be a number, was %s", idx.Type())
+			return NIL, fmt.Errorf("index must be an int, was %s", idx.Type())
 		}
 
 		return idxr.Index(i)
 	} else if mapper, ok := o.(Mapper); ok {
-		key, ok := idx.(Hasher)
+		key, ok := AsHasher(idx)
 		if !ok {
 			return NIL, fmt.Errorf("key must be hashable, was %s", idx.Type())
 		}
@@ -265,11 +431,329 @@ func ItemAtIndex(o Object, idx Object) (Object, error) {
 	}
 }
 
+// IsOfType checks if an object's type has the given name, instances are also of the types of their superclasses
+func IsOfType(o Object, name string) bool {
+	if instance, ok := o.(*Instance); ok {
+		for class := instance.Class; class != nil; class = class.superclass {
+			if class.name == name {
+				return true
+			}
+		}
+		return false
+	}
+	return string(o.Type()) == name
+}
+
+// Materialize returns the elements of a sequence, ranges too large to hold in memory are an error
+func Materialize(seq Sequence) ([]Object, error) {
+	if r, ok := seq.(Range); ok && r.count() > maxRangeElements {
+		return nil, fmt.Errorf("%s is too large to materialize", r)
+	}
+	return seq.Elements(), nil
+}
+
+// Unpack returns the elements of a sequence which must have exactly n elements
+func Unpack(o Object, n int) ([]Object, error) {
+	seq, ok := o.(Sequence)
+	if !ok {
+		return nil, fmt.Errorf("cannot unpack %s into %d values", o.Type(), n)
+	}
+
+	elements, err := Materialize(seq)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != n {
+		return nil, fmt.Errorf("expected %d values to unpack, got %d", n, len(elements))
+	}
+	return elements, nil
+}
+
+// UnpackRest returns the first n elements of a sequence which must have at least n elements
+// followed by a list of the remaining elements
+func UnpackRest(o Object, n int) ([]Object, error) {
+	seq, ok := o.(Sequence)
+	if !ok {
+		return nil, fmt.Errorf("cannot unpack %s into %d values", o.Type(), n)
+	}
+
+	elements, err := Materialize(seq)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) < n {
+		return nil, fmt.Errorf("expected at least %d values to unpack, got %d", n, len(elements))
+	}
+
+	rest := make([]Object, len(elements)-n)
+	copy(rest, elements[n:])
+
+	values := make([]Object, 0, n+1)
+	values = append(values, elements[:n]...)
+	return append(values, NewList(rest)), nil
+}
+
+// UnpackKey returns the value of a key of a map, other objects are unpacked by attribute
+func UnpackKey(o Object, key string) (Object, error) {
+	m, ok := o.(*Map)
+	if !ok {
+		return GetAttr(o, key)
+	}
+
+	if _, kvp, _, _ := m.lookup(NewString(key)); kvp != nil {
+		return kvp.Value, nil
+	}
+	return NIL, fmt.Errorf("missing key \"%s\" to unpack", key)
+}
+
+// SliceOf returns the items of a list or a string between start (inclusive) and stop (exclusive)
+// taking every step'th item, bounds which are nil take their default value
+//
+// Slicing never fails due to out of range bounds:
+// - Negative bounds count from the end of the sequence
+// - Bounds beyond either end of the sequence are clamped to the sequence
+// - A negative step walks the sequence backwards, start then defaults to the last item
+func SliceOf(o Object, start Object, stop Object, step Object) (Object, error) {
+	slicer, ok := o.(Slicer)
+	if !ok {
+		return NIL, fmt.Errorf("%s does not support slicing", o.Type())
+	}
+
+	stepVal := 1
+	if step != NIL {
+		i, ok := step.(Int)
+		if !ok {
+			return NIL, fmt.Errorf("slice step must be an int, was %s", step.Type())
+		}
+		if i.Value == 0 {
+			return NIL, fmt.Errorf("slice step cannot be zero")
+		}
+		stepVal = int(i.Value)
+	}
+
+	length := int(slicer.Size().Value)
+	startVal, err := sliceBound(start, length, stepVal, 0, length-1)
+	if err != nil {
+		return NIL, err
+	}
+
+	stopVal, err := sliceBound(stop, length, stepVal, length, -1)
+	if err != nil {
+		return NIL, err
+	}
+
+	return slicer.Slice(startVal, stopVal, stepVal), nil
+}
+
+// sliceBound normalizes a slice bound, forward and backward are the defaults for positive and negative steps
+func sliceBound(bound Object, length int, step int, forward int, backward int) (int, error) {
+	if bound == NIL {
+		if step > 0 {
+			return forward, nil
+		}
+		return backward, nil
+	}
+
+	i, ok := bound.(Int)
+	if !ok {
+		return 0, fmt.Errorf("slice bounds must be ints, was %s", bound.Type())
+	}
+
+	idx := int(i.Value)
+	if idx < 0 {
+		idx += length
+		if idx < 0 {
+			if step > 0 {
+				return 0, nil
+			}
+			return -1, nil
+		}
+	} else if idx >= length {
+		if step > 0 {
+			return length, nil
+		}
+		return length - 1, nil
+	}
+	return idx, nil
+}
+
+// sliceIndices returns the indices selected by normalized slice bounds
+// The number of indices is computed up front so that huge steps can't overflow the index
+func sliceIndices(start int, stop int, step int) []int {
+	count := 0
+	if step > 0 && start < stop {
+		count = (stop-start-1)/step + 1
+	} else if step < 0 && start > stop {
+		count = (start-stop-1)/-step + 1 // -step only overflows for the smallest int, which still divides to 0
+	}
+
+	indices := make([]int, count)
+	for k := range indices {
+		indices[k] = start + k*step
+	}
+	return indices
+}
+
+// normalizeIndex resolves negative indices from the end of a sequence
+// Indices outside [-length, length) are out of range
+func normalizeIndex(n Int, length int) (int, bool) {
+	idx := int(n.Value)
+	if idx < 0 {
+		idx += length
+	}
+	return idx, idx >= 0 && idx < length
+}
+
+// SetItemAtIndex replaces the item at an index of a list or the value of a key in a map
+// Collections are shared by reference, so the change is visible through every variable referring to the collection
+func SetItemAtIndex(o Object, idx Object, value Object) error {
+	if setter, ok := o.(IndexSetter); ok {
+		i, ok := idx.(Int)
+		if !ok {
+			return fmt.Errorf("index must be an int, was %s", idx.Type())
+		}
+
+		return setter.SetIndex(i, value)
+	} else if setter, ok := o.(MapSetter); ok {
+		key, ok := AsHasher(idx)
+		if !ok {
+			return fmt.Errorf("key must be hashable, was %s", idx.Type())
+		}
+
+		return setter.Set(key, value)
+	} else {
+		return fmt.Errorf("%s does not support index assignment", o.Type())
+	}
+}
+
 // ------------------------------------
 // Helpers
 // ------------------------------------
 
+// AsHasher returns the object as a Hasher if it can be hashed
+// Tuples are only hashable when all of their elements are, and instances when their class defines __hash__
+func AsHasher(o Object) (Hasher, bool) {
+	switch o := o.(type) {
+	case Tuple:
+		for _, elem := range o.Values {
+			if _, ok := AsHasher(elem); !ok {
+				return nil, false
+			}
+		}
+	case *Instance:
+		if _, ok := o.Class.findMethod("__hash__"); !ok {
+			return nil, false
+		}
+	}
+	hasher, ok := o.(Hasher)
+	return hasher, ok
+}
+
+func isNumber(o Object) bool {
+	_, ok := toFloat(o)
+	return ok
+}
+
+// toFloat converts any number to a float64
+func toFloat(o Object) (float64, bool) {
+	switch o := o.(type) {
+	case Number:
+		return o.Value, true
+	case Int:
+		return float64(o.Value), true
+	case BigInt:
+		return o.Float().Value, true
+	case Decimal:
+		return o.Float().Value, true
+	}
+	return 0, false
+}
+
+// compareExact compares an int or bigint with a float without converting the integer to a float,
+// which could round it and make distinct numbers equal. It fails if the operands aren't an integer
+// and a float. NaN isn't ordered, so every comparison with it is false.
+func compareExact(left Object, right Object, test func(cmp int) bool) (Bool, bool) {
+	if !(isInteger(left) && isFloat(right)) && !(isFloat(left) && isInteger(right)) {
+		return FALSE, false
+	}
+	l, r := exactFloat(left), exactFloat(right)
+	if l == nil || r == nil {
+		return FALSE, true
+	}
+	return NewBool(test(l.Cmp(r))), true
+}
+
+// exactFloat converts an integer or a float to a big.Float without rounding, it returns nil for NaN
+func exactFloat(o Object) *big.Float {
+	switch o := o.(type) {
+	case Int:
+		return new(big.Float).SetInt64(o.Value)
+	case BigInt:
+		return new(big.Float).SetInt(o.Value)
+	case Number:
+		if math.IsNaN(o.Value) {
+			return nil
+		}
+		return new(big.Float).SetFloat64(o.Value)
+	}
+	return nil
+}
+
+func isInteger(o Object) bool {
+	switch o.(type) {
+	case Int, BigInt:
+		return true
+	}
+	return false
+}
+
+func isFloat(o Object) bool {
+	_, ok := o.(Number)
+	return ok
+}
+
+// promote converts the operands of a mixed number operation to the wider of their types
+func promote(left Object, right Object) (Object, Object) {
+	if widened, ok := widen(left, right.Type()); ok {
+		return widened, right
+	}
+	if widened, ok := widen(right, left.Type()); ok {
+		return left, widened
+	}
+	return left, right
+}
+
+// widen converts an integer to a wider number type
+// Ints widen to bigints, and both widen to decimals and floats. Decimals and floats don't
+// mix since converting either to the other loses precision.
+func widen(o Object, to ObjectType) (Object, bool) {
+	switch o := o.(type) {
+	case Int:
+		switch to {
+		case TypeBigInt:
+			return o.BigInt(), true
+		case TypeDecimal:
+			return o.Decimal(), true
+		case TypeNumber:
+			return o.Float(), true
+		}
+	case BigInt:
+		switch to {
+		case TypeDecimal:
+			return o.Decimal(), true
+		case TypeNumber:
+			return o.Float(), true
+		}
+	}
+	return o, false
+}
+
+// checkTypeCompat checks that the operands of an operator are of the same type
+// Instances are exempt since their protocol methods decide which operands they accept
 func checkTypeCompat(left Object, right Object) error {
+	if _, ok := left.(*Instance); ok {
+		return nil
+	}
 	if left.Type() != right.Type() {
 		return fmt.Errorf("incompatible types %s and %s", left.Type(), right.Type())
 	}
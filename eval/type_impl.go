@@ -1,15 +1,34 @@
 package eval
 
 import (
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
 	"math"
+	"math/big"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// hashNumber hashes integral floats like the equivalent int or bigint since they compare equal
 func hashNumber(n Number) uint32 {
+	if i, ok := n.Int(); ok {
+		return hashInt(i)
+	}
+	if math.Trunc(n.Value) == n.Value && !math.IsInf(n.Value, 0) {
+		i, _ := new(big.Float).SetFloat64(n.Value).Int(nil)
+		hash, _ := NewBigInt(i).Hash()
+		return hash
+	}
+	h := fnv.New32a()
+	_ = binary.Write(h, binary.BigEndian, math.Float64bits(n.Value))
+	return h.Sum32()
+}
+
+func hashInt(i Int) uint32 {
 	h := fnv.New32a()
-	h.Write([]byte(fmt.Sprintf("%f", n.Value)))
+	_ = binary.Write(h, binary.BigEndian, i.Value)
 	return h.Sum32()
 }
 
@@ -19,6 +38,50 @@ func hashString(s String) uint32 {
 	return h.Sum32()
 }
 
+func hashBool(b Bool) uint32 {
+	h := fnv.New32a()
+	_ = binary.Write(h, binary.BigEndian, b.Value)
+	return h.Sum32()
+}
+
+// hashTuple combines the hashes of the elements, it expects every element to be hashable
+func hashTuple(t Tuple) (uint32, error) {
+	h := fnv.New32a()
+	for _, elem := range t.Values {
+		hash, err := elem.(Hasher).Hash()
+		if err != nil {
+			return 0, err
+		}
+		_ = binary.Write(h, binary.BigEndian, hash)
+	}
+	return h.Sum32(), nil
+}
+
+// plainString converts the elements of collections for String(), which can't fail
+func plainString(o Object) (string, error) {
+	return o.String(), nil
+}
+
+// formatAll converts objects to strings with str, stopping at the first error
+func formatAll(objs []Object, str func(Object) (string, error)) ([]string, error) {
+	strs := make([]string, 0, len(objs))
+	for _, o := range objs {
+		s, err := str(o)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // IEEE 754 floating point number type
 // Implements the following interfaces
 // Object
@@ -35,20 +98,27 @@ func hashString(s String) uint32 {
 // Hasher
 type Number struct{ Value float64 }
 
-func NewNumber(value float64) Number           { return Number{Value: value} }
-func (f Number) Type() ObjectType              { return TypeNumber }
-func (f Number) Truthy() Bool                  { return NewBool(f.Value != 0) }
-func (f Number) Negate() (Object, error)       { return f.Multiply(NewNumber(-1)) }
-func (f Number) LessThan(other Object) Bool    { return NewBool(f.Value < other.(Number).Value) }
-func (f Number) GreaterThan(other Object) Bool { return NewBool(f.Value > other.(Number).Value) }
-func (f Number) EqualTo(other Object) Bool     { return NewBool(f.Value == other.(Number).Value) }
-func (f Number) Hash() uint32                  { return hashNumber(f) }
+func NewNumber(value float64) Number     { return Number{Value: value} }
+func (f Number) Type() ObjectType        { return TypeNumber }
+func (f Number) Truthy() Bool            { return NewBool(f.Value != 0) }
+func (f Number) Negate() (Object, error) { return f.Multiply(NewNumber(-1)) }
+func (f Number) LessThan(other Object) (Bool, error) {
+	return NewBool(f.Value < other.(Number).Value), nil
+}
+func (f Number) GreaterThan(other Object) (Bool, error) {
+	return NewBool(f.Value > other.(Number).Value), nil
+}
+func (f Number) EqualTo(other Object) (Bool, error) {
+	return NewBool(f.Value == other.(Number).Value), nil
+}
+func (f Number) Hash() (uint32, error) { return hashNumber(f), nil }
 
+// String prints integral floats which fit in an int without an exponent or decimal point
 func (f Number) String() string {
-	if math.Trunc(f.Value) == f.Value {
-		return fmt.Sprintf("%d", int64(f.Value))
+	if i, ok := f.Int(); ok {
+		return i.String()
 	}
-	return fmt.Sprintf("%v", f.Value)
+	return strconv.FormatFloat(f.Value, 'g', -1, 64)
 }
 
 func (f Number) Add(other Object) (Object, error) {
@@ -65,13 +135,347 @@ func (f Number) Multiply(other Object) (Object, error) {
 
 func (f Number) Divide(other Object) (Object, error) {
 	if other.(Number).Value == 0 {
-		return nil, fmt.Errorf("Divide by zero error")
+		return nil, errDivideByZero
 	}
 	return NewNumber(f.Value / other.(Number).Value), nil
 }
 
 func (f Number) Modulo(other Object) (Object, error) {
-	return NewNumber(float64(int(f.Value) % int(other.(Number).Value))), nil
+	if other.(Number).Value == 0 {
+		return nil, errModuloByZero
+	}
+	return NewNumber(math.Mod(f.Value, other.(Number).Value)), nil
+}
+
+// Int converts an integral float to an int, it fails if the float has a fractional part or is out of range
+func (f Number) Int() (Int, bool) {
+	if math.Trunc(f.Value) != f.Value || f.Value < math.MinInt64 || f.Value >= math.MaxInt64 {
+		return NewInt(0), false
+	}
+	return NewInt(int64(f.Value)), true
+}
+
+// 64-bit signed integer type
+// Arithmetic is exact and fails on overflow, mixed int and float operations produce floats
+// but ints and floats are compared exactly
+// Implements the following interfaces
+// Object
+// Truthifier
+// Negator
+// LessThanComparator
+// GreaterThanComparator
+// EqualToComparator
+// Adder
+// Subtractor
+// Multiplier
+// Divider
+// Modulator
+// Hasher
+type Int struct{ Value int64 }
+
+func NewInt(value int64) Int                      { return Int{Value: value} }
+func (f Int) Type() ObjectType                    { return TypeInt }
+func (f Int) String() string                      { return strconv.FormatInt(f.Value, 10) }
+func (f Int) Truthy() Bool                        { return NewBool(f.Value != 0) }
+func (f Int) LessThan(other Object) (Bool, error) { return NewBool(f.Value < other.(Int).Value), nil }
+func (f Int) GreaterThan(other Object) (Bool, error) {
+	return NewBool(f.Value > other.(Int).Value), nil
+}
+func (f Int) EqualTo(other Object) (Bool, error) { return NewBool(f.Value == other.(Int).Value), nil }
+func (f Int) Hash() (uint32, error)              { return hashInt(f), nil }
+func (f Int) Float() Number                      { return NewNumber(float64(f.Value)) }
+
+func (f Int) Negate() (Object, error) {
+	if f.Value == math.MinInt64 {
+		return nil, fmt.Errorf("integer overflow")
+	}
+	return NewInt(-f.Value), nil
+}
+
+func (f Int) Add(other Object) (Object, error) {
+	a, b := f.Value, other.(Int).Value
+	sum := a + b
+	if (a > 0 && b > 0 && sum < 0) || (a < 0 && b < 0 && sum >= 0) {
+		return nil, fmt.Errorf("integer overflow")
+	}
+	return NewInt(sum), nil
+}
+
+func (f Int) Subtract(other Object) (Object, error) {
+	a, b := f.Value, other.(Int).Value
+	diff := a - b
+	if (a >= 0 && b < 0 && diff < 0) || (a < 0 && b > 0 && diff >= 0) {
+		return nil, fmt.Errorf("integer overflow")
+	}
+	return NewInt(diff), nil
+}
+
+func (f Int) Multiply(other Object) (Object, error) {
+	a, b := f.Value, other.(Int).Value
+	if a == 0 || b == 0 {
+		return NewInt(0), nil
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return nil, fmt.Errorf("integer overflow")
+	}
+	return NewInt(product), nil
+}
+
+// Divide truncates towards zero
+func (f Int) Divide(other Object) (Object, error) {
+	a, b := f.Value, other.(Int).Value
+	if b == 0 {
+		return nil, errDivideByZero
+	}
+	if a == math.MinInt64 && b == -1 {
+		return nil, fmt.Errorf("integer overflow")
+	}
+	return NewInt(a / b), nil
+}
+
+// Modulo has the sign of the dividend so that a == (a / b) * b + a % b
+func (f Int) Modulo(other Object) (Object, error) {
+	a, b := f.Value, other.(Int).Value
+	if b == 0 {
+		return nil, errModuloByZero
+	}
+	if b == -1 {
+		return NewInt(0), nil
+	}
+	return NewInt(a % b), nil
+}
+
+func (f Int) BigInt() BigInt   { return NewBigInt(big.NewInt(f.Value)) }
+func (f Int) Decimal() Decimal { return NewDecimal(new(big.Rat).SetInt64(f.Value), 0) }
+
+// Arbitrary-precision integer type
+// Values are immutable, every operation allocates a new big.Int
+// Implements the following interfaces
+// Object
+// Truthifier
+// Negator
+// LessThanComparator
+// GreaterThanComparator
+// EqualToComparator
+// Adder
+// Subtractor
+// Multiplier
+// Divider
+// Modulator
+// Hasher
+type BigInt struct{ Value *big.Int }
+
+func NewBigInt(value *big.Int) BigInt                   { return BigInt{Value: value} }
+func (f BigInt) Type() ObjectType                       { return TypeBigInt }
+func (f BigInt) String() string                         { return f.Value.String() }
+func (f BigInt) Truthy() Bool                           { return NewBool(f.Value.Sign() != 0) }
+func (f BigInt) Negate() (Object, error)                { return NewBigInt(new(big.Int).Neg(f.Value)), nil }
+func (f BigInt) LessThan(other Object) (Bool, error)    { return NewBool(f.compare(other) < 0), nil }
+func (f BigInt) GreaterThan(other Object) (Bool, error) { return NewBool(f.compare(other) > 0), nil }
+func (f BigInt) EqualTo(other Object) (Bool, error)     { return NewBool(f.compare(other) == 0), nil }
+func (f BigInt) compare(other Object) int               { return f.Value.Cmp(other.(BigInt).Value) }
+func (f BigInt) Decimal() Decimal                       { return NewDecimal(new(big.Rat).SetInt(f.Value), 0) }
+
+func (f BigInt) Float() Number {
+	val, _ := new(big.Float).SetInt(f.Value).Float64()
+	return NewNumber(val)
+}
+
+// Hash hashes bigints that fit in 64 bits like the equivalent int since they compare equal
+func (f BigInt) Hash() (uint32, error) {
+	if f.Value.IsInt64() {
+		return hashInt(NewInt(f.Value.Int64())), nil
+	}
+	return hashString(NewString(f.Value.String())), nil
+}
+
+func (f BigInt) Add(other Object) (Object, error) {
+	return NewBigInt(new(big.Int).Add(f.Value, other.(BigInt).Value)), nil
+}
+
+func (f BigInt) Subtract(other Object) (Object, error) {
+	return NewBigInt(new(big.Int).Sub(f.Value, other.(BigInt).Value)), nil
+}
+
+func (f BigInt) Multiply(other Object) (Object, error) {
+	return NewBigInt(new(big.Int).Mul(f.Value, other.(BigInt).Value)), nil
+}
+
+// Divide truncates towards zero like int division
+func (f BigInt) Divide(other Object) (Object, error) {
+	if other.(BigInt).Value.Sign() == 0 {
+		return nil, errDivideByZero
+	}
+	return NewBigInt(new(big.Int).Quo(f.Value, other.(BigInt).Value)), nil
+}
+
+func (f BigInt) Modulo(other Object) (Object, error) {
+	if other.(BigInt).Value.Sign() == 0 {
+		return nil, errModuloByZero
+	}
+	return NewBigInt(new(big.Int).Rem(f.Value, other.(BigInt).Value)), nil
+}
+
+// Int converts a bigint to an int, it fails if the bigint doesn't fit in 64 bits
+func (f BigInt) Int() (Int, bool) {
+	if !f.Value.IsInt64() {
+		return NewInt(0), false
+	}
+	return NewInt(f.Value.Int64()), true
+}
+
+// Exact decimal type
+// The value is a rational number with at most scale digits after the decimal point, which is the
+// number of digits it is printed with, so printed values are the values compared.
+// Results of addition and subtraction keep the larger scale of their operands, multiplication adds
+// the scales and division uses enough digits to hold the exact result. Results of multiplication and
+// division which need more than decimalScaleLimit digits, or more than their operands if they have
+// more, are rounded to that many digits, with ties rounded to the even digit.
+// Implements the following interfaces
+// Object
+// Truthifier
+// Negator
+// LessThanComparator
+// GreaterThanComparator
+// EqualToComparator
+// Adder
+// Subtractor
+// Multiplier
+// Divider
+// Hasher
+type Decimal struct {
+	Value *big.Rat
+	Scale int
+}
+
+const decimalScaleLimit = 20
+
+func NewDecimal(value *big.Rat, scale int) Decimal       { return Decimal{Value: value, Scale: scale} }
+func (f Decimal) Type() ObjectType                       { return TypeDecimal }
+func (f Decimal) String() string                         { return f.Value.FloatString(f.Scale) }
+func (f Decimal) Truthy() Bool                           { return NewBool(f.Value.Sign() != 0) }
+func (f Decimal) LessThan(other Object) (Bool, error)    { return NewBool(f.compare(other) < 0), nil }
+func (f Decimal) GreaterThan(other Object) (Bool, error) { return NewBool(f.compare(other) > 0), nil }
+func (f Decimal) EqualTo(other Object) (Bool, error)     { return NewBool(f.compare(other) == 0), nil }
+
+func (f Decimal) compare(other Object) int {
+	return f.Value.Cmp(other.(Decimal).Value)
+}
+
+func (f Decimal) Negate() (Object, error) {
+	return NewDecimal(new(big.Rat).Neg(f.Value), f.Scale), nil
+}
+
+func (f Decimal) Float() Number {
+	val, _ := f.Value.Float64()
+	return NewNumber(val)
+}
+
+// Hash hashes integral decimals like the equivalent int since they compare equal
+func (f Decimal) Hash() (uint32, error) {
+	if f.Value.IsInt() {
+		return NewBigInt(f.Value.Num()).Hash()
+	}
+	return hashString(NewString(f.Value.String())), nil
+}
+
+func (f Decimal) Add(other Object) (Object, error) {
+	o := other.(Decimal)
+	return NewDecimal(new(big.Rat).Add(f.Value, o.Value), maxInt(f.Scale, o.Scale)), nil
+}
+
+func (f Decimal) Subtract(other Object) (Object, error) {
+	o := other.(Decimal)
+	return NewDecimal(new(big.Rat).Sub(f.Value, o.Value), maxInt(f.Scale, o.Scale)), nil
+}
+
+func (f Decimal) Multiply(other Object) (Object, error) {
+	o := other.(Decimal)
+	product := new(big.Rat).Mul(f.Value, o.Value)
+
+	scale := f.Scale + o.Scale
+	if limit := f.scaleLimit(o); scale > limit {
+		return NewDecimal(roundHalfEven(product, limit), limit), nil
+	}
+	return NewDecimal(product, scale), nil
+}
+
+func (f Decimal) Divide(other Object) (Object, error) {
+	o := other.(Decimal)
+	if o.Value.Sign() == 0 {
+		return nil, errDivideByZero
+	}
+
+	quotient := new(big.Rat).Quo(f.Value, o.Value)
+	limit := f.scaleLimit(o)
+	scale, ok := terminatingScale(quotient)
+	if !ok || scale > limit {
+		scale = limit
+	}
+	scale = maxInt(scale, maxInt(f.Scale, o.Scale))
+	return NewDecimal(roundHalfEven(quotient, scale), scale), nil
+}
+
+// scaleLimit is the largest scale of the result of a multiplication or division
+func (f Decimal) scaleLimit(other Decimal) int {
+	return maxInt(decimalScaleLimit, maxInt(f.Scale, other.Scale))
+}
+
+// Int truncates a decimal to an int, it fails if the integer part doesn't fit in 64 bits
+func (f Decimal) Int() (Int, bool) {
+	return NewBigInt(f.truncate()).Int()
+}
+
+func (f Decimal) BigInt() BigInt {
+	return NewBigInt(f.truncate())
+}
+
+func (f Decimal) truncate() *big.Int {
+	return new(big.Int).Quo(f.Value.Num(), f.Value.Denom())
+}
+
+// roundHalfEven rounds a rational to scale digits after the decimal point, ties are rounded to the even digit
+func roundHalfEven(r *big.Rat, scale int) *big.Rat {
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow))
+	if scaled.IsInt() {
+		return r
+	}
+
+	// The quotient is truncated towards zero, the remainder has the sign of the value
+	quo, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	half := new(big.Int).Lsh(new(big.Int).Abs(rem), 1).Cmp(scaled.Denom())
+	if half > 0 || (half == 0 && quo.Bit(0) == 1) {
+		quo.Add(quo, big.NewInt(int64(scaled.Sign())))
+	}
+	return new(big.Rat).SetFrac(quo, pow)
+}
+
+// terminatingScale returns the number of digits after the decimal point needed to print a rational exactly
+// It fails if the decimal expansion of the rational doesn't terminate
+func terminatingScale(r *big.Rat) (int, bool) {
+	denom := new(big.Int).Set(r.Denom())
+	twos, fives := 0, 0
+	for denom.Bit(0) == 0 {
+		denom.Rsh(denom, 1)
+		twos++
+	}
+
+	five, mod := big.NewInt(5), new(big.Int)
+	for {
+		quo, rem := new(big.Int).QuoRem(denom, five, mod)
+		if rem.Sign() != 0 {
+			break
+		}
+		denom = quo
+		fives++
+	}
+
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	return maxInt(twos, fives), true
 }
 
 // Boolean type
@@ -80,22 +484,25 @@ func (f Number) Modulo(other Object) (Object, error) {
 // Truthifier
 // EqualToComparator
 // Notter
+// Hasher
 type Bool struct{ Value bool }
 
 var TRUE = NewBool(true)
 var FALSE = NewBool(false)
 
-func NewBool(value bool) Bool            { return Bool{Value: value} }
-func (f Bool) Type() ObjectType          { return TypeBool }
-func (f Bool) String() string            { return fmt.Sprintf("%v", f.Value) }
-func (f Bool) EqualTo(other Object) Bool { return NewBool(f.Value == other.(Bool).Value) }
-func (f Bool) Truthy() Bool              { return NewBool(f.Value) }
-func (f Bool) Not() (Object, error)      { return NewBool(!f.Value), nil }
+func NewBool(value bool) Bool                     { return Bool{Value: value} }
+func (f Bool) Type() ObjectType                   { return TypeBool }
+func (f Bool) String() string                     { return fmt.Sprintf("%v", f.Value) }
+func (f Bool) EqualTo(other Object) (Bool, error) { return NewBool(f.Value == other.(Bool).Value), nil }
+func (f Bool) Truthy() Bool                       { return NewBool(f.Value) }
+func (f Bool) Not() (Object, error)               { return NewBool(!f.Value), nil }
+func (f Bool) Hash() (uint32, error)              { return hashBool(f), nil }
 
 // String type
+// Sizes, indices and slices count Unicode characters (runes) rather than bytes
 // Implements the following interfaces
 // Object
-// Sequence
+// Sequence/Slicer
 // Indexer
 // Truthifier
 // Adder
@@ -103,28 +510,54 @@ func (f Bool) Not() (Object, error)      { return NewBool(!f.Value), nil }
 // GreaterThanComparator
 // EqualToComparator
 // Hasher
+// Container
 type String struct{ Value string }
 
-func NewString(value string) String            { return String{Value: value} }
-func (f String) Type() ObjectType              { return TypeString }
-func (f String) String() string                { return f.Value }
-func (f String) Truthy() Bool                  { return NewBool(f.Size().Value > 0) }
-func (f String) LessThan(other Object) Bool    { return NewBool(f.Value < other.(String).Value) }
-func (f String) GreaterThan(other Object) Bool { return NewBool(f.Value > other.(String).Value) }
-func (f String) EqualTo(other Object) Bool     { return NewBool(f.Value == other.(String).Value) }
-func (f String) Size() Number                  { return NewNumber(float64(len(f.Value))) }
-func (f String) Hash() uint32                  { return hashString(f) }
+func NewString(value string) String { return String{Value: value} }
+func (f String) Type() ObjectType   { return TypeString }
+func (f String) String() string     { return f.Value }
+func (f String) Truthy() Bool       { return NewBool(f.Size().Value > 0) }
+func (f String) LessThan(other Object) (Bool, error) {
+	return NewBool(f.Value < other.(String).Value), nil
+}
+func (f String) GreaterThan(other Object) (Bool, error) {
+	return NewBool(f.Value > other.(String).Value), nil
+}
+func (f String) EqualTo(other Object) (Bool, error) {
+	return NewBool(f.Value == other.(String).Value), nil
+}
+func (f String) Size() Int             { return NewInt(int64(utf8.RuneCountInString(f.Value))) }
+func (f String) Hash() (uint32, error) { return hashString(f), nil }
+
+// Contains reports whether elem is a substring
+func (f String) Contains(elem Object) (Bool, error) {
+	sub, ok := elem.(String)
+	if !ok {
+		return FALSE, fmt.Errorf("'in <string>' requires a string, was %s", elem.Type())
+	}
+	return NewBool(strings.Contains(f.Value, sub.Value)), nil
+}
 
 func (f String) Add(other Object) (Object, error) {
 	return NewString(f.Value + other.(String).Value), nil
 }
 
-func (f String) Index(n Number) (Object, error) {
-	idx := int(n.Value)
-	if idx >= len(f.Value) {
-		return nil, fmt.Errorf("string index out of range")
+func (f String) Index(n Int) (Object, error) {
+	runes := []rune(f.Value)
+	idx, ok := normalizeIndex(n, len(runes))
+	if !ok {
+		return nil, indexOutOfRange(TypeString)
+	}
+	return NewString(string(runes[idx])), nil
+}
+
+func (f String) Slice(start int, stop int, step int) Object {
+	runes := []rune(f.Value)
+	var sb strings.Builder
+	for _, i := range sliceIndices(start, stop, step) {
+		sb.WriteRune(runes[i])
 	}
-	return NewString(string(f.Value[idx])), nil
+	return NewString(sb.String())
 }
 
 func (f String) Append(o Object) (Sequence, error) {
@@ -135,8 +568,21 @@ func (f String) Append(o Object) (Sequence, error) {
 	return f, fmt.Errorf("cannot append %s to %s", o.Type(), f.Type())
 }
 
+// Iterator iterates over the runes of the string
+func (f String) Iterator() *Iterator {
+	runes := []rune(f.Value)
+	i := 0
+	return NewIterator(func() (Object, Object, bool) {
+		if i >= len(runes) {
+			return nil, nil, false
+		}
+		i++
+		return NewInt(int64(i - 1)), NewString(string(runes[i-1])), true
+	})
+}
+
 func (f String) Elements() []Object {
-	elems := make([]Object, 0, 500)
+	elems := make([]Object, 0, len(f.Value))
 	for _, i := range f.Value {
 		elems = append(elems, NewString(string(i)))
 	}
@@ -150,186 +596,1086 @@ func (f String) Elements() []Object {
 // EqualToComparator
 type Type struct{ Value ObjectType }
 
-func NewType(value ObjectType) Type      { return Type{Value: value} }
-func (f Type) Type() ObjectType          { return TypeType }
-func (f Type) String() string            { return string(f.Value) }
-func (f Type) Truthy() Bool              { return TRUE }
-func (f Type) EqualTo(other Object) Bool { return NewBool(f.Value == other.(Type).Value) }
+func NewType(value ObjectType) Type               { return Type{Value: value} }
+func (f Type) Type() ObjectType                   { return TypeType }
+func (f Type) String() string                     { return string(f.Value) }
+func (f Type) Truthy() Bool                       { return TRUE }
+func (f Type) EqualTo(other Object) (Bool, error) { return NewBool(f.Value == other.(Type).Value), nil }
 
 // Heterogenous list type
+// Lists are shared by reference, index assignments and push() are visible through every reference
+// to a list while append() and + always return a new list
 // Implements the following interfaces
 // Object
-// Sequence
-// Indexer
+// Sequence/Slicer
+// Indexer/IndexSetter
 // Truthifier
 // Adder
 // EqualToComparator
+// Container
 type List struct{ Values []Object }
 
-func NewList(values []Object) List { return List{Values: values} }
-func (f List) Type() ObjectType    { return TypeList }
-func (f List) String() string      { return fmt.Sprintf("%v", f.Values) }
-func (f List) Size() Number        { return NewNumber(float64(len(f.Values))) }
-func (f List) Truthy() Bool        { return NewBool(f.Size().Value > 0) }
+func NewList(values []Object) *List { return &List{Values: values} }
+func (f *List) Type() ObjectType    { return TypeList }
+func (f *List) Size() Int           { return NewInt(int64(len(f.Values))) }
+func (f *List) Truthy() Bool        { return NewBool(f.Size().Value > 0) }
+
+func (f *List) String() string {
+	s, _ := f.format(plainString)
+	return s
+}
+
+// format joins the string representations of the elements produced by str
+func (f *List) format(str func(Object) (string, error)) (string, error) {
+	strs, err := formatAll(f.Values, str)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[%s]", strings.Join(strs, " ")), nil
+}
 
-func (f List) Add(other Object) (Object, error) {
-	l, ok := other.(List)
+func (f *List) Add(other Object) (Object, error) {
+	l, ok := other.(*List)
 	if !ok {
 		return nil, fmt.Errorf("cannot concatenate list with %s", other.Type())
 	}
 
-	return NewList(append(f.Values, l.Values...)), nil
+	return NewList(append(f.Values[:len(f.Values):len(f.Values)], l.Values...)), nil
 }
 
-func (f List) Index(n Number) (Object, error) {
-	idx := int(n.Value)
-	if idx >= len(f.Values) {
-		return nil, fmt.Errorf("list index out of range")
+func (f *List) Index(n Int) (Object, error) {
+	idx, ok := normalizeIndex(n, len(f.Values))
+	if !ok {
+		return nil, indexOutOfRange(TypeList)
 	}
 	return f.Values[idx], nil
 }
 
-func (f List) Append(o Object) (Sequence, error) {
-	f.Values = append(f.Values, o)
-	return f, nil
+func (f *List) SetIndex(n Int, value Object) error {
+	idx, ok := normalizeIndex(n, len(f.Values))
+	if !ok {
+		return indexOutOfRange(TypeList)
+	}
+	f.Values[idx] = value
+	return nil
+}
+
+// Slice returns a new list which doesn't share its items with this list
+func (f *List) Slice(start int, stop int, step int) Object {
+	indices := sliceIndices(start, stop, step)
+	values := make([]Object, 0, len(indices))
+	for _, i := range indices {
+		values = append(values, f.Values[i])
+	}
+	return NewList(values)
+}
+
+// Append returns a new list, limiting the capacity forces a copy so that the new list doesn't share
+// its backing array with this list
+func (f *List) Append(o Object) (Sequence, error) {
+	return NewList(append(f.Values[:len(f.Values):len(f.Values)], o)), nil
 }
 
-func (f List) Elements() []Object {
+func (f *List) Elements() []Object {
 	return f.Values
 }
 
-func (f List) EqualTo(other Object) Bool {
-	if l, ok := other.(List); ok {
+// Contains reports whether an element is equal to elem
+func (f *List) Contains(elem Object) (Bool, error) {
+	for _, value := range f.Values {
+		if eq, err := EqualTo(value, elem); err != nil || eq.Value {
+			return eq, err
+		}
+	}
+	return FALSE, nil
+}
+
+func (f *List) Iterator() *Iterator {
+	values := f.Values
+	i := 0
+	return NewIterator(func() (Object, Object, bool) {
+		if i >= len(values) {
+			return nil, nil, false
+		}
+		i++
+		return NewInt(int64(i - 1)), values[i-1], true
+	})
+}
+
+func (f *List) EqualTo(other Object) (Bool, error) {
+	if l, ok := other.(*List); ok {
 		if l.Size() != f.Size() {
-			return FALSE
+			return FALSE, nil
 		}
 
 		for i := 0; i < int(f.Size().Value); i++ {
 			elem1, ok := f.Values[i].(EqualToComparator)
 			if !ok {
-				return FALSE
+				return FALSE, nil
 			}
 
 			elem2, ok := l.Values[i].(EqualToComparator)
 			if !ok {
-				return FALSE
+				return FALSE, nil
 			}
 
-			if !EqualTo(elem1, elem2).Value {
-				return FALSE
+			if eq, err := EqualTo(elem1, elem2); err != nil || !eq.Value {
+				return FALSE, err
 			}
 		}
-		return TRUE
+		return TRUE, nil
+	}
+	return FALSE, nil
+}
+
+// Immutable heterogenous tuple type
+// Tuples compare structurally and can be used as map keys when all of their elements are hashable
+// Implements the following interfaces
+// Object
+// Sequence/Slicer
+// Indexer
+// Truthifier
+// Adder
+// EqualToComparator
+// Hasher
+// Container
+type Tuple struct{ Values []Object }
+
+func NewTuple(values []Object) Tuple  { return Tuple{Values: values} }
+func (f Tuple) Type() ObjectType      { return TypeTuple }
+func (f Tuple) Size() Int             { return NewInt(int64(len(f.Values))) }
+func (f Tuple) Truthy() Bool          { return NewBool(f.Size().Value > 0) }
+func (f Tuple) Hash() (uint32, error) { return hashTuple(f) }
+
+func (f Tuple) String() string {
+	s, _ := f.format(plainString)
+	return s
+}
+
+// format joins the string representations of the elements produced by str
+func (f Tuple) format(str func(Object) (string, error)) (string, error) {
+	elems, err := formatAll(f.Values, str)
+	if err != nil {
+		return "", err
+	} else if len(elems) == 1 {
+		return fmt.Sprintf("(%s,)", elems[0]), nil
+	}
+	return fmt.Sprintf("(%s)", strings.Join(elems, ", ")), nil
+}
+
+func (f Tuple) Add(other Object) (Object, error) {
+	t, ok := other.(Tuple)
+	if !ok {
+		return nil, fmt.Errorf("cannot concatenate tuple with %s", other.Type())
+	}
+
+	return NewTuple(append(f.Values[:len(f.Values):len(f.Values)], t.Values...)), nil
+}
+
+func (f Tuple) Index(n Int) (Object, error) {
+	idx, ok := normalizeIndex(n, len(f.Values))
+	if !ok {
+		return nil, indexOutOfRange(TypeTuple)
+	}
+	return f.Values[idx], nil
+}
+
+func (f Tuple) Slice(start int, stop int, step int) Object {
+	indices := sliceIndices(start, stop, step)
+	values := make([]Object, 0, len(indices))
+	for _, i := range indices {
+		values = append(values, f.Values[i])
+	}
+	return NewTuple(values)
+}
+
+// Append returns a new tuple, tuples are never modified in place
+func (f Tuple) Append(o Object) (Sequence, error) {
+	f.Values = append(f.Values[:len(f.Values):len(f.Values)], o)
+	return f, nil
+}
+
+func (f Tuple) Elements() []Object {
+	return f.Values
+}
+
+func (f Tuple) Contains(elem Object) (Bool, error) {
+	return NewList(f.Values).Contains(elem)
+}
+
+func (f Tuple) Iterator() *Iterator {
+	return NewList(f.Values).Iterator()
+}
+
+func (f Tuple) EqualTo(other Object) (Bool, error) {
+	if t, ok := other.(Tuple); ok {
+		return NewList(f.Values).EqualTo(NewList(t.Values))
 	}
-	return FALSE
+	return FALSE, nil
 }
 
 // Key-value map type
+// Maps are shared by reference, assigning a key is visible through every reference to a map
+// while append() always returns a new map
 // Implements the following interfaces
 // Object
-// Mapper/Sequence
+// Mapper/MapSetter/Sequence
 // Truthifier
 // EqualToComparator
+// Container
 type Map struct {
-	Mappings      map[uint32]Object
-	KeyValuePairs []MapKeyValuePair
+	// Keys with colliding hashes share a bucket and are told apart with EqualTo
+	buckets map[uint32][]*MapKeyValuePair
+	// Pairs in insertion order, deleting a pair leaves a nil tombstone until the pairs are compacted
+	pairs []*MapKeyValuePair
+	// Number of pairs which aren't tombstones
+	size int
 }
 
 type MapKeyValuePair struct {
 	Key   Object
 	Value Object
+	// Position in the pairs of the map so that deleting doesn't have to search for it
+	index int
 }
 
-func NewMap() Map {
-	return Map{
-		Mappings:      make(map[uint32]Object),
-		KeyValuePairs: make([]MapKeyValuePair, 0, 255),
+func NewMap() *Map {
+	return &Map{
+		buckets: make(map[uint32][]*MapKeyValuePair),
+		pairs:   make([]*MapKeyValuePair, 0, 8),
 	}
 }
 
-func (f Map) Add(key Object, value Object) (Map, error) {
-	if hasher, ok := key.(Hasher); ok {
-		f.Mappings[hasher.Hash()] = value
-		f.KeyValuePairs = append(f.KeyValuePairs, MapKeyValuePair{Key: key, Value: value})
-		return f, nil
+// Add sets the value of a key, the key must be hashable
+func (f *Map) Add(key Object, value Object) error {
+	if hasher, ok := AsHasher(key); ok {
+		return f.Set(hasher, value)
 	}
-	return f, fmt.Errorf("key type '%s' is not hashable", key.Type())
+	return fmt.Errorf("key type '%s' is not hashable", key.Type())
 }
 
-func (f Map) Type() ObjectType { return TypeMap }
-func (f Map) Size() Number     { return NewNumber(float64(len(f.Mappings))) }
-func (f Map) Truthy() Bool     { return NewBool(f.Size().Value > 0) }
+// lookup returns the hash of key, the pair holding key and its position in the key's bucket
+func (f *Map) lookup(key Hasher) (uint32, *MapKeyValuePair, int, error) {
+	hash, err := key.Hash()
+	if err != nil {
+		return 0, nil, -1, err
+	}
 
-func (f Map) String() string {
-	kvps := make([]string, 0, len(f.KeyValuePairs))
-	for _, kvp := range f.KeyValuePairs {
-		kvps = append(kvps, fmt.Sprintf("%s:%s", kvp.Key, kvp.Value))
+	for i, kvp := range f.buckets[hash] {
+		if eq, err := EqualTo(kvp.Key, key); err != nil {
+			return hash, nil, -1, err
+		} else if eq.Value {
+			return hash, kvp, i, nil
+		}
 	}
-	return fmt.Sprintf("{%s}", strings.Join(kvps, ", "))
+	return hash, nil, -1, nil
 }
 
-func (f Map) Elements() []Object {
-	values := make([]Object, 0, len(f.KeyValuePairs))
-	for _, kvp := range f.KeyValuePairs {
-		values = append(values, kvp.Value)
+// Set inserts a key or replaces the value of an existing key in place, keeping the insertion order
+func (f *Map) Set(key Hasher, value Object) error {
+	hash, kvp, _, err := f.lookup(key)
+	if err != nil {
+		return err
+	} else if kvp != nil {
+		kvp.Value = value
+		return nil
 	}
-	return values
+	kvp = &MapKeyValuePair{Key: key, Value: value, index: len(f.pairs)}
+	f.buckets[hash] = append(f.buckets[hash], kvp)
+	f.pairs = append(f.pairs, kvp)
+	f.size++
+	return nil
 }
 
-func (f Map) Append(o Object) (Sequence, error) {
-	var err error
-	if m, ok := o.(Map); ok {
-		for _, kvp := range m.KeyValuePairs {
-			f, err = f.Add(kvp.Key, kvp.Value)
-			if err != nil {
-				return f, err
-			}
-		}
-		return f, nil
+// Delete removes a key, it reports whether the key was present
+func (f *Map) Delete(key Hasher) (bool, error) {
+	hash, kvp, i, err := f.lookup(key)
+	if kvp == nil {
+		return false, err
 	}
-	return f, fmt.Errorf("cannot append %s to %s", o.Type(), f.Type())
-}
 
-func (f Map) Map(key Hasher) (Object, error) {
-	if v, ok := f.Mappings[key.Hash()]; ok {
-		return v, nil
+	bucket := f.buckets[hash]
+	if len(bucket) == 1 {
+		delete(f.buckets, hash)
 	} else {
-		return NIL, nil
+		f.buckets[hash] = append(bucket[:i:i], bucket[i+1:]...)
 	}
-}
 
-func (f Map) EqualTo(other Object) Bool {
-	if m, ok := other.(Map); ok {
-		if m.Size() != f.Size() {
-			return FALSE
+	f.pairs[kvp.index] = nil
+	f.size--
+	// Compacting once tombstones outnumber the pairs keeps deleting amortized constant time
+	if len(f.pairs)-f.size > f.size {
+		f.pairs = f.Pairs()
+		for i, p := range f.pairs {
+			p.index = i
 		}
+	}
+	return true, nil
+}
 
-		for i := 0; i < int(f.Size().Value); i++ {
-			key1, ok := f.KeyValuePairs[i].Key.(EqualToComparator)
-			if !ok {
-				return FALSE
+// Pairs returns a copy of the key-value pairs in insertion order which later changes to the map don't affect
+func (f *Map) Pairs() []*MapKeyValuePair {
+	pairs := make([]*MapKeyValuePair, 0, f.size)
+	for _, kvp := range f.pairs {
+		if kvp != nil {
+			pairs = append(pairs, kvp)
+		}
+	}
+	return pairs
+}
+
+func (f *Map) Type() ObjectType { return TypeMap }
+func (f *Map) Size() Int        { return NewInt(int64(f.size)) }
+func (f *Map) Truthy() Bool     { return NewBool(f.Size().Value > 0) }
+
+func (f *Map) String() string {
+	s, _ := f.format(plainString)
+	return s
+}
+
+// format joins the string representations of the pairs produced by str
+func (f *Map) format(str func(Object) (string, error)) (string, error) {
+	kvps := make([]string, 0, f.size)
+	for _, kvp := range f.Pairs() {
+		pair, err := formatAll([]Object{kvp.Key, kvp.Value}, str)
+		if err != nil {
+			return "", err
+		}
+		kvps = append(kvps, fmt.Sprintf("%s:%s", pair[0], pair[1]))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(kvps, ", ")), nil
+}
+
+func (f *Map) Elements() []Object {
+	values := make([]Object, 0, f.size)
+	for _, kvp := range f.Pairs() {
+		values = append(values, kvp.Value)
+	}
+	return values
+}
+
+// Iterator iterates over key-value pairs in insertion order
+// Loops with a single variable iterate over the keys
+func (f *Map) Iterator() *Iterator {
+	kvps := f.Pairs()
+	i := 0
+	it := NewIterator(func() (Object, Object, bool) {
+		if i >= len(kvps) {
+			return nil, nil, false
+		}
+		i++
+		return kvps[i-1].Key, kvps[i-1].Value, true
+	})
+	it.keyed = true
+	return it
+}
+
+// Append returns a new map holding the keys of both maps
+func (f *Map) Append(o Object) (Sequence, error) {
+	if m, ok := o.(*Map); ok {
+		merged := NewMap()
+		for _, kvps := range [][]*MapKeyValuePair{f.Pairs(), m.Pairs()} {
+			for _, kvp := range kvps {
+				if err := merged.Add(kvp.Key, kvp.Value); err != nil {
+					return f, err
+				}
 			}
-			value1, ok := f.KeyValuePairs[i].Value.(EqualToComparator)
-			if !ok {
-				return FALSE
+		}
+		return merged, nil
+	}
+	return f, fmt.Errorf("cannot append %s to %s", o.Type(), f.Type())
+}
+
+// Contains reports whether key is a key of the map
+func (f *Map) Contains(key Object) (Bool, error) {
+	hasher, ok := AsHasher(key)
+	if !ok {
+		return FALSE, fmt.Errorf("key must be hashable, was %s", key.Type())
+	}
+	_, kvp, _, err := f.lookup(hasher)
+	return NewBool(kvp != nil), err
+}
+
+func (f *Map) Map(key Hasher) (Object, error) {
+	_, kvp, _, err := f.lookup(key)
+	if kvp != nil {
+		return kvp.Value, nil
+	}
+	return NIL, err
+}
+
+// EqualTo compares maps regardless of the order their keys were inserted in
+func (f *Map) EqualTo(other Object) (Bool, error) {
+	if m, ok := other.(*Map); ok {
+		if m.size != f.size {
+			return FALSE, nil
+		}
+
+		for _, kvp := range f.Pairs() {
+			_, otherKvp, _, err := m.lookup(kvp.Key.(Hasher))
+			if otherKvp == nil {
+				return FALSE, err
 			}
 
-			key2, ok := m.KeyValuePairs[i].Key.(EqualToComparator)
+			value1, ok := kvp.Value.(EqualToComparator)
 			if !ok {
-				return FALSE
+				return FALSE, nil
 			}
-			value2, ok := m.KeyValuePairs[i].Value.(EqualToComparator)
+			value2, ok := otherKvp.Value.(EqualToComparator)
 			if !ok {
-				return FALSE
+				return FALSE, nil
 			}
-
-			if !EqualTo(key1, key2).Value || !EqualTo(value1, value2).Value {
-				return FALSE
+			if eq, err := EqualTo(value1, value2); err != nil || !eq.Value {
+				return FALSE, err
 			}
 		}
-		return TRUE
+		return TRUE, nil
+	}
+	return FALSE, nil
+}
+
+// Set type
+// Elements are kept in insertion order and identified by their hash and EqualTo like map keys
+// Sets are shared by reference, while append() and the set algebra functions always return a new set
+// Implements the following interfaces
+// Object
+// Sequence
+// Truthifier
+// EqualToComparator
+// Container
+type Set struct {
+	elements *Map
+}
+
+func NewSet() *Set { return &Set{elements: NewMap()} }
+
+// Add inserts an element, the element must be hashable
+func (f *Set) Add(elem Object) error {
+	hasher, ok := AsHasher(elem)
+	if !ok {
+		return fmt.Errorf("set element type '%s' is not hashable", elem.Type())
+	}
+	return f.elements.Set(hasher, TRUE)
+}
+
+// Contains reports whether elem is in the set
+func (f *Set) Contains(elem Object) (Bool, error) {
+	hasher, ok := AsHasher(elem)
+	if !ok {
+		return FALSE, fmt.Errorf("set element type '%s' is not hashable", elem.Type())
+	}
+	_, kvp, _, err := f.elements.lookup(hasher)
+	return NewBool(kvp != nil), err
+}
+
+func (f *Set) Type() ObjectType { return TypeSet }
+func (f *Set) Size() Int        { return f.elements.Size() }
+func (f *Set) Truthy() Bool     { return f.elements.Truthy() }
+
+func (f *Set) String() string {
+	s, _ := f.format(plainString)
+	return s
+}
+
+// format joins the string representations of the elements produced by str
+func (f *Set) format(str func(Object) (string, error)) (string, error) {
+	elems, err := formatAll(f.Elements(), str)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("#{%s}", strings.Join(elems, ", ")), nil
+}
+
+func (f *Set) Elements() []Object {
+	elems := make([]Object, 0, f.elements.size)
+	for _, kvp := range f.elements.Pairs() {
+		elems = append(elems, kvp.Key)
+	}
+	return elems
+}
+
+// Iterator iterates over the elements in insertion order along with their position
+func (f *Set) Iterator() *Iterator {
+	return NewList(f.Elements()).Iterator()
+}
+
+// Append returns a new set holding the elements of this set and o
+func (f *Set) Append(o Object) (Sequence, error) {
+	set, err := f.copy()
+	if err != nil {
+		return f, err
+	}
+	if err := set.Add(o); err != nil {
+		return f, err
+	}
+	return set, nil
+}
+
+func (f *Set) copy() (*Set, error) {
+	set := NewSet()
+	for _, kvp := range f.elements.Pairs() {
+		if err := set.elements.Set(kvp.Key.(Hasher), TRUE); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// Union returns a new set holding the elements of both sets
+func (f *Set) Union(other *Set) (*Set, error) {
+	set, err := f.copy()
+	if err != nil {
+		return nil, err
+	}
+	for _, kvp := range other.elements.Pairs() {
+		if err := set.elements.Set(kvp.Key.(Hasher), TRUE); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// Intersection returns a new set holding the elements found in both sets
+func (f *Set) Intersection(other *Set) (*Set, error) {
+	return f.filter(other, true)
+}
+
+// Difference returns a new set holding the elements of this set not found in other
+func (f *Set) Difference(other *Set) (*Set, error) {
+	return f.filter(other, false)
+}
+
+func (f *Set) filter(other *Set, found bool) (*Set, error) {
+	set := NewSet()
+	for _, kvp := range f.elements.Pairs() {
+		_, match, _, err := other.elements.lookup(kvp.Key.(Hasher))
+		if err != nil {
+			return nil, err
+		} else if (match != nil) != found {
+			continue
+		}
+		if err := set.elements.Set(kvp.Key.(Hasher), TRUE); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// IsSubset reports whether every element of this set is in other
+func (f *Set) IsSubset(other *Set) (bool, error) {
+	diff, err := f.Difference(other)
+	if err != nil {
+		return false, err
+	}
+	return diff.elements.size == 0, nil
+}
+
+// EqualTo compares sets regardless of the order their elements were inserted in
+func (f *Set) EqualTo(other Object) (Bool, error) {
+	if s, ok := other.(*Set); ok {
+		if f.Size() != s.Size() {
+			return FALSE, nil
+		}
+		subset, err := f.IsSubset(s)
+		return NewBool(subset), err
 	}
-	return FALSE
+	return FALSE, nil
+}
+
+// Integer range type
+// Ranges are lazy, their elements are only produced while iterating
+// Implements the following interfaces
+// Object
+// Sequence
+// Indexer
+// Truthifier
+// Ranges with more elements than fit in an int cannot be created and
+// ranges with more than maxRangeElements cannot be materialized
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+// maxRangeElements caps how many elements a range may materialize at once
+const maxRangeElements = 1 << 26
+
+func NewRange(start int64, stop int64, step int64) Range {
+	return Range{Start: start, Stop: stop, Step: step}
+}
+
+func (f Range) Type() ObjectType { return TypeRange }
+func (f Range) Truthy() Bool     { return NewBool(f.Size().Value > 0) }
+
+func (f Range) String() string {
+	return fmt.Sprintf("range(%d, %d, %d)", f.Start, f.Stop, f.Step)
+}
+
+func (f Range) Size() Int {
+	return NewInt(int64(f.count()))
+}
+
+// count returns the number of elements using unsigned arithmetic so wide bounds do not overflow
+func (f Range) count() uint64 {
+	if f.Step > 0 && f.Start < f.Stop {
+		return (uint64(f.Stop)-uint64(f.Start)-1)/uint64(f.Step) + 1
+	} else if f.Step < 0 && f.Start > f.Stop {
+		return (uint64(f.Start)-uint64(f.Stop)-1)/-uint64(f.Step) + 1
+	}
+	return 0
+}
+
+func (f Range) Index(n Int) (Object, error) {
+	idx, ok := normalizeIndex(n, int(f.Size().Value))
+	if !ok {
+		return nil, indexOutOfRange(TypeRange)
+	}
+	return NewInt(f.Start + int64(idx)*f.Step), nil
+}
+
+func (f Range) Append(o Object) (Sequence, error) {
+	return f, fmt.Errorf("cannot append %s to %s", o.Type(), f.Type())
+}
+
+func (f Range) Elements() []Object {
+	elems := make([]Object, 0, f.Size().Value)
+	it := f.Iterator()
+	for _, v, ok := it.Next(); ok; _, v, ok = it.Next() {
+		elems = append(elems, v)
+	}
+	return elems
+}
+
+func (f Range) Iterator() *Iterator {
+	var i int64
+	size := f.Size().Value
+	return NewIterator(func() (Object, Object, bool) {
+		if i >= size {
+			return nil, nil, false
+		}
+		i++
+		return NewInt(i - 1), NewInt(f.Start + (i-1)*f.Step), true
+	})
+}
+
+// Iterator type
+// Steps through the items of a sequence for for-in loops
+// Implements the following interfaces
+// Object
+type Iterator struct {
+	next  func() (Object, Object, bool)
+	keyed bool
+}
+
+func NewIterator(next func() (Object, Object, bool)) *Iterator {
+	return &Iterator{next: next}
+}
+
+func (f *Iterator) Type() ObjectType { return TypeIter }
+func (f *Iterator) String() string   { return "<iterator>" }
+
+// Next returns the index or key of the next item along with its value
+// It returns false once the sequence is exhausted
+func (f *Iterator) Next() (Object, Object, bool) {
+	return f.next()
+}
+
+// NextVariables returns the values bound to the variables of a for-in loop for the next item
+// Loops with two variables get the index or key and the value, loops with one variable get the
+// value, or the key when iterating over a map
+func (f *Iterator) NextVariables(count int) ([]Object, bool) {
+	key, value, ok := f.next()
+	if !ok {
+		return nil, false
+	}
+
+	if count == 2 {
+		return []Object{key, value}, true
+	} else if f.keyed {
+		return []Object{key}, true
+	}
+	return []Object{value}, true
+}
+
+// Struct declaration type
+// Calling a struct with a value for each field constructs an instance of it
+// Implements the following interfaces
+// Object
+// Callable
+type StructType struct {
+	name   string
+	fields []string
+	index  map[string]int
+}
+
+func NewStructType(name string, fields []string) *StructType {
+	index := make(map[string]int, len(fields))
+	for i, field := range fields {
+		index[field] = i
+	}
+	return &StructType{name: name, fields: fields, index: index}
+}
+
+func (f *StructType) Type() ObjectType { return TypeStruct }
+func (f *StructType) Name() string     { return f.name }
+func (f *StructType) String() string   { return fmt.Sprintf("<struct %s>", f.name) }
+func (f *StructType) Arity() int       { return len(f.fields) }
+func (f *StructType) Variadic() bool   { return false }
+
+func (f *StructType) Call(e *Evaluator, args []Object) (Object, error) {
+	values := make([]Object, len(args))
+	copy(values, args)
+	return &StructInstance{Struct: f, Values: values}, nil
+}
+
+// Struct instance type
+// Instances are shared by reference, their type is the name of their struct
+// Implements the following interfaces
+// Object
+// AttrGetter/AttrSetter
+// Truthifier
+// EqualToComparator
+type StructInstance struct {
+	Struct *StructType
+	Values []Object
+}
+
+func (f *StructInstance) Type() ObjectType { return ObjectType(f.Struct.name) }
+func (f *StructInstance) Truthy() Bool     { return TRUE }
+
+func (f *StructInstance) String() string {
+	s, _ := f.format(plainString)
+	return s
+}
+
+// format joins the names of the fields with the string representations of their values produced by str
+func (f *StructInstance) format(str func(Object) (string, error)) (string, error) {
+	values, err := formatAll(f.Values, str)
+	if err != nil {
+		return "", err
+	}
+	fields := make([]string, 0, len(f.Values))
+	for i, value := range values {
+		fields = append(fields, fmt.Sprintf("%s: %s", f.Struct.fields[i], value))
+	}
+	return fmt.Sprintf("%s{%s}", f.Struct.name, strings.Join(fields, ", ")), nil
+}
+
+func (f *StructInstance) GetAttr(name string) (Object, error) {
+	if i, ok := f.Struct.index[name]; ok {
+		return f.Values[i], nil
+	}
+	return NIL, fmt.Errorf("%s has no field '%s'", f.Struct.name, name)
+}
+
+func (f *StructInstance) SetAttr(name string, value Object) error {
+	if i, ok := f.Struct.index[name]; ok {
+		f.Values[i] = value
+		return nil
+	}
+	return fmt.Errorf("%s has no field '%s'", f.Struct.name, name)
+}
+
+// EqualTo compares the fields of instances of the same struct
+func (f *StructInstance) EqualTo(other Object) (Bool, error) {
+	if s, ok := other.(*StructInstance); ok && s.Struct == f.Struct {
+		return NewList(f.Values).EqualTo(NewList(s.Values))
+	}
+	return FALSE, nil
+}
+
+// Class type
+// Calling a class creates an instance and calls its init method with the arguments
+// Implements the following interfaces
+// Object
+// Callable
+type Class struct {
+	name       string
+	superclass *Class
+	methods    map[string]Method
+}
+
+// NewClass creates a class, superclass is either NIL or the class to inherit from
+func NewClass(name string, superclass Object, methods map[string]Method) (*Class, error) {
+	class := &Class{name: name, methods: methods}
+	if superclass == NIL {
+		return class, nil
+	}
+
+	parent, ok := superclass.(*Class)
+	if !ok {
+		return nil, fmt.Errorf("superclass must be a class, got %s", superclass.Type())
+	}
+	class.superclass = parent
+	return class, nil
+}
+
+func (f *Class) Type() ObjectType { return TypeClass }
+func (f *Class) Name() string     { return f.name }
+func (f *Class) String() string   { return fmt.Sprintf("<class %s>", f.name) }
+func (f *Class) Variadic() bool   { return false }
+
+func (f *Class) Arity() int {
+	if init, ok := f.findMethod("init"); ok {
+		return init.Arity()
+	}
+	return 0
+}
+
+func (f *Class) Call(e *Evaluator, args []Object) (Object, error) {
+	instance := &Instance{Class: f, fields: make(map[string]Object), evaluator: e}
+	if init, ok := f.findMethod("init"); ok {
+		if _, err := init.Bind(instance).Call(e, args); err != nil {
+			return NIL, err
+		}
+	}
+	return instance, nil
+}
+
+// Bound looks up a method of the class or its superclasses and binds it to an instance
+func (f *Class) Bound(this Object, name string) (Object, error) {
+	if method, ok := f.findMethod(name); ok {
+		return method.Bind(this), nil
+	}
+	return NIL, fmt.Errorf("undefined method '%s' on %s", name, f.name)
+}
+
+func (f *Class) findMethod(name string) (Method, bool) {
+	for class := f; class != nil; class = class.superclass {
+		if method, ok := class.methods[name]; ok {
+			return method, true
+		}
+	}
+	return nil, false
+}
+
+// Class instance type
+// Instances are shared by reference, their type is the name of their class
+// Fields are created by assigning to them, methods are looked up after fields
+//
+// Classes overload operators and built-in functions by defining protocol methods such as
+// __add__, __lt__, __eq__, __index__, __len__, __str__, __bool__ and __hash__. Without them
+// instances are equal only to themselves, are always truthy and can't be used as map keys.
+// Truthiness and string conversion go through IsTruthy and ToString so that errors raised by
+// __bool__ and __str__ reach the program.
+// Implements the following interfaces
+// Object
+// AttrGetter/AttrSetter
+// EqualToComparator
+// LessThanComparator/GreaterThanComparator
+// Adder/Subtractor/Multiplier/Divider/Modulator
+// Negator/Notter
+// Subscripter
+// Lengther
+// Hasher
+type Instance struct {
+	Class     *Class
+	fields    map[string]Object
+	evaluator *Evaluator
+}
+
+func (f *Instance) Type() ObjectType { return ObjectType(f.Class.name) }
+
+// String is used where a description of the instance can't fail, such as error messages
+func (f *Instance) String() string {
+	if s, err := f.str(); err == nil {
+		return s
+	}
+	return fmt.Sprintf("<%s instance>", f.Class.name)
+}
+
+// str converts the instance with __str__
+func (f *Instance) str() (string, error) {
+	val, ok, err := f.protocol("__str__")
+	if !ok {
+		return fmt.Sprintf("<%s instance>", f.Class.name), nil
+	} else if err != nil {
+		return "", err
+	}
+	return ToString(val)
+}
+
+// truthy asks __bool__ whether the instance is truthy
+func (f *Instance) truthy() (Bool, error) {
+	val, ok, err := f.protocol("__bool__")
+	if !ok || err != nil {
+		return TRUE, err
+	}
+	truthy, err := IsTruthy(val)
+	return NewBool(truthy), err
+}
+
+func (f *Instance) GetAttr(name string) (Object, error) {
+	if value, ok := f.fields[name]; ok {
+		return value, nil
+	} else if method, ok := f.Class.findMethod(name); ok {
+		return method.Bind(f), nil
+	}
+	return NIL, fmt.Errorf("undefined property '%s' on %s", name, f.Class.name)
+}
+
+func (f *Instance) SetAttr(name string, value Object) error {
+	f.fields[name] = value
+	return nil
+}
+
+// EqualTo compares instances by identity unless the class defines __eq__
+func (f *Instance) EqualTo(other Object) (Bool, error) {
+	if _, ok := f.Class.findMethod("__eq__"); ok {
+		return f.comparison("__eq__", other)
+	}
+	return NewBool(f == other), nil
+}
+
+func (f *Instance) LessThan(other Object) (Bool, error) {
+	return f.comparison("__lt__", other)
+}
+
+// GreaterThan uses __gt__, or __lt__ with the operands swapped when both are instances
+func (f *Instance) GreaterThan(other Object) (Bool, error) {
+	if _, ok := f.Class.findMethod("__gt__"); ok {
+		return f.comparison("__gt__", other)
+	} else if o, ok := other.(*Instance); ok {
+		return o.LessThan(f)
+	}
+	return FALSE, nil
+}
+
+func (f *Instance) Add(other Object) (Object, error) {
+	return f.operator("__add__", "add", other)
+}
+
+func (f *Instance) Subtract(other Object) (Object, error) {
+	return f.operator("__sub__", "subtract", other)
+}
+
+func (f *Instance) Multiply(other Object) (Object, error) {
+	return f.operator("__mul__", "multiply", other)
+}
+
+func (f *Instance) Divide(other Object) (Object, error) {
+	return f.operator("__div__", "divide", other)
+}
+
+func (f *Instance) Modulo(other Object) (Object, error) {
+	return f.operator("__mod__", "modulo", other)
+}
+
+func (f *Instance) Negate() (Object, error) {
+	if val, ok, err := f.protocol("__neg__"); ok {
+		return val, err
+	}
+	return NIL, fmt.Errorf("Cannot negate type %s", f.Type())
+}
+
+func (f *Instance) Not() (Object, error) {
+	truthy, err := f.truthy()
+	return NewBool(!truthy.Value), err
+}
+
+func (f *Instance) Subscript(index Object) (Object, error) {
+	if val, ok, err := f.protocol("__index__", index); ok {
+		return val, err
+	}
+	return NIL, fmt.Errorf("%s is not indexable", f.Type())
+}
+
+func (f *Instance) Len() (Int, error) {
+	val, ok, err := f.protocol("__len__")
+	if !ok {
+		return NewInt(0), fmt.Errorf("%s has no length", f.Type())
+	} else if err != nil {
+		return NewInt(0), err
+	}
+
+	n, ok := val.(Int)
+	if !ok {
+		return NewInt(0), fmt.Errorf("__len__() should return an int, returned %s", val.Type())
+	}
+	return n, nil
+}
+
+// Hash hashes the value returned by __hash__, instances of classes without it aren't hashable (see AsHasher)
+func (f *Instance) Hash() (uint32, error) {
+	val, _, err := f.protocol("__hash__")
+	if err != nil {
+		return 0, err
+	}
+	hasher, ok := AsHasher(val)
+	if !ok {
+		return 0, fmt.Errorf("__hash__() of %s should return a hashable value, returned %s", f.Class.name, val.Type())
+	}
+	return hasher.Hash()
+}
+
+// comparison calls the protocol method of a comparison operator, a missing method compares false
+func (f *Instance) comparison(name string, other Object) (Bool, error) {
+	val, ok, err := f.protocol(name, other)
+	if !ok || err != nil {
+		return FALSE, err
+	}
+	truthy, err := IsTruthy(val)
+	return NewBool(truthy), err
+}
+
+// operator calls the protocol method of a binary operator
+func (f *Instance) operator(name string, verb string, other Object) (Object, error) {
+	if val, ok, err := f.protocol(name, other); ok {
+		return val, err
+	}
+	return NIL, fmt.Errorf("Cannot %s type %s", verb, f.Type())
+}
+
+// protocol calls a protocol method of the instance, ok is false when its class doesn't define the method
+func (f *Instance) protocol(name string, args ...Object) (val Object, ok bool, err error) {
+	method, ok := f.Class.findMethod(name)
+	if !ok {
+		return NIL, false, nil
+	}
+
+	if method.Arity() != len(args) {
+		return NIL, true, fmt.Errorf("%s() of %s should take %d arguments", name, f.Class.name, len(args))
+	}
+	val, err = method.Bind(f).Call(f.evaluator, args)
+	return val, true, err
+}
+
+// Error type
+// Errors are caught from failed operations, or created with error() and thrown
+// Line and Column are zero until the error is thrown
+// Implements the following interfaces
+// Object
+// AttrGetter
+// Truthifier
+// EqualToComparator
+type Error struct {
+	Message string
+	Kind    string
+	Line    int
+	Column  int
+}
+
+func NewError(message string, kind string) *Error {
+	return &Error{Message: message, Kind: kind}
+}
+
+func (f *Error) Type() ObjectType                   { return TypeError }
+func (f *Error) String() string                     { return f.Message }
+func (f *Error) Truthy() Bool                       { return TRUE }
+func (f *Error) EqualTo(other Object) (Bool, error) { return NewBool(f == other), nil }
+
+func (f *Error) GetAttr(name string) (Object, error) {
+	switch name {
+	case "message":
+		return NewString(f.Message), nil
+	case "kind":
+		return NewString(f.Kind), nil
+	case "line":
+		return NewInt(int64(f.Line)), nil
+	case "column":
+		return NewInt(int64(f.Column)), nil
+	}
+	return NIL, fmt.Errorf("error has no attribute '%s'", name)
 }
 
 // Nil type
@@ -337,12 +1683,14 @@ func (f Map) EqualTo(other Object) Bool {
 // Object
 // Truthifier
 // EqualToComparator
+// Hasher
 type Nil struct{}
 
 var NIL = NewNil()
 
-func NewNil() Nil                       { return Nil{} }
-func (f Nil) Type() ObjectType          { return TypeNil }
-func (f Nil) String() string            { return "nil" }
-func (f Nil) Truthy() Bool              { return FALSE }
-func (f Nil) EqualTo(other Object) Bool { return TRUE }
+func NewNil() Nil                                { return Nil{} }
+func (f Nil) Type() ObjectType                   { return TypeNil }
+func (f Nil) String() string                     { return "nil" }
+func (f Nil) Truthy() Bool                       { return FALSE }
+func (f Nil) EqualTo(other Object) (Bool, error) { return TRUE, nil }
+func (f Nil) Hash() (uint32, error)              { return 0, nil }
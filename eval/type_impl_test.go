@@ -0,0 +1,220 @@
+package eval
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collidingKey hashes every key to the same bucket
+type collidingKey struct{ name string }
+
+func (k collidingKey) Type() ObjectType      { return TypeString }
+func (k collidingKey) String() string        { return k.name }
+func (k collidingKey) Hash() (uint32, error) { return 42, nil }
+
+func (k collidingKey) EqualTo(other Object) (Bool, error) {
+	if o, ok := other.(collidingKey); ok {
+		return NewBool(k.name == o.name), nil
+	}
+	return FALSE, nil
+}
+
+func TestMap_Collisions(t *testing.T) {
+	a := collidingKey{"a"}
+	b := collidingKey{"b"}
+	c := collidingKey{"c"}
+
+	m := NewMap()
+	assert.Nil(t, m.Set(a, NewInt(1)))
+	assert.Nil(t, m.Set(b, NewInt(2)))
+	assert.Nil(t, m.Set(c, NewInt(3)))
+	assert.Nil(t, m.Set(a, NewInt(4)))
+	assert.Equal(t, NewInt(3), m.Size())
+
+	v, _ := m.Map(a)
+	assert.Equal(t, NewInt(4), v)
+	v, _ = m.Map(b)
+	assert.Equal(t, NewInt(2), v)
+
+	deleted, err := m.Delete(b)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	deleted, _ = m.Delete(b)
+	assert.False(t, deleted)
+	v, _ = m.Map(b)
+	assert.Equal(t, NIL, v)
+	v, _ = m.Map(c)
+	assert.Equal(t, NewInt(3), v)
+	assert.Equal(t, "{a:4, c:3}", m.String())
+}
+
+func TestMap_MixedNumberKeysAtFloatPrecision(t *testing.T) {
+	const twoTo53 = 1 << 53
+	m := NewMap()
+	assert.Nil(t, m.Set(NewNumber(twoTo53), NewString("float")))
+	assert.Nil(t, m.Set(NewNumber(1e19), NewString("huge")))
+
+	v, _ := m.Map(NewInt(twoTo53))
+	assert.Equal(t, NewString("float"), v)
+	v, _ = m.Map(NewBigInt(big.NewInt(twoTo53)))
+	assert.Equal(t, NewString("float"), v)
+	v, _ = m.Map(NewInt(twoTo53 + 1))
+	assert.Equal(t, NIL, v)
+
+	huge, _ := new(big.Int).SetString("10000000000000000000", 10)
+	v, _ = m.Map(NewBigInt(huge))
+	assert.Equal(t, NewString("huge"), v)
+	v, _ = m.Map(NewBigInt(new(big.Int).Add(huge, big.NewInt(1))))
+	assert.Equal(t, NIL, v)
+
+	assert.Nil(t, m.Set(NewInt(twoTo53+1), NewString("int")))
+	assert.Equal(t, NewInt(3), m.Size())
+	v, _ = m.Map(NewNumber(twoTo53))
+	assert.Equal(t, NewString("float"), v)
+}
+
+func TestEqualTo_IntegersAndFloatsExactly(t *testing.T) {
+	const twoTo53 = 1 << 53
+	tests := []struct {
+		left  Object
+		right Object
+		eq    bool
+		lt    bool
+	}{
+		{NewInt(twoTo53 + 1), NewNumber(twoTo53), false, false},
+		{NewNumber(twoTo53), NewInt(twoTo53 + 1), false, true},
+		{NewInt(twoTo53), NewNumber(twoTo53), true, false},
+		{NewInt(math.MaxInt64), NewNumber(math.MaxInt64), false, true},
+		{NewBigInt(big.NewInt(twoTo53 + 1)), NewNumber(twoTo53), false, false},
+		{NewInt(1), NewNumber(math.NaN()), false, false},
+		{NewInt(1), NewNumber(math.Inf(1)), false, true},
+	}
+	for _, tt := range tests {
+		eq, err := EqualTo(tt.left, tt.right)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.eq, eq.Value, "%s == %s", tt.left, tt.right)
+
+		lt, err := LessThan(tt.left, tt.right)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.lt, lt.Value, "%s < %s", tt.left, tt.right)
+
+		if tt.eq {
+			lh, _ := tt.left.(Hasher).Hash()
+			rh, _ := tt.right.(Hasher).Hash()
+			assert.Equal(t, lh, rh, "hash(%s) == hash(%s)", tt.left, tt.right)
+		}
+	}
+}
+
+func TestMap_DeleteKeepsInsertionOrder(t *testing.T) {
+	m := NewMap()
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, m.Set(NewInt(int64(i)), NewInt(int64(i))))
+	}
+	it := m.Iterator()
+
+	for i := 0; i < 100; i++ {
+		if i%3 != 0 {
+			deleted, err := m.Delete(NewInt(int64(i)))
+			assert.Nil(t, err)
+			assert.True(t, deleted)
+		}
+	}
+	assert.Nil(t, m.Set(NewInt(1), NewInt(1)))
+	assert.Equal(t, NewInt(35), m.Size())
+
+	keys := make([]Object, 0, 35)
+	for _, kvp := range m.Pairs() {
+		keys = append(keys, kvp.Key)
+	}
+	assert.Equal(t, NewInt(0), keys[0])
+	assert.Equal(t, NewInt(99), keys[33])
+	assert.Equal(t, NewInt(1), keys[34])
+	v, _ := m.Map(NewInt(42))
+	assert.Equal(t, NewInt(42), v)
+
+	n := 0
+	for _, _, ok := it.Next(); ok; _, _, ok = it.Next() {
+		n++
+	}
+	assert.Equal(t, 100, n)
+}
+
+func TestRange_SizeOfWideBounds(t *testing.T) {
+	assert.Equal(t, NewInt(math.MaxInt64), NewRange(0, math.MaxInt64, 1).Size())
+	assert.Equal(t, NewInt(math.MaxInt64), NewRange(math.MaxInt64, 0, -1).Size())
+	assert.Equal(t, NewInt(3), NewRange(math.MinInt64, math.MaxInt64, math.MaxInt64).Size())
+	assert.Equal(t, NewInt(2), NewRange(math.MaxInt64, math.MinInt64, math.MinInt64).Size())
+	assert.Equal(t, uint64(math.MaxUint64), NewRange(math.MinInt64, math.MaxInt64, 1).count())
+
+	_, err := Materialize(NewRange(0, math.MaxInt64, 1))
+	assert.NotNil(t, err)
+}
+
+func TestDecimal_RoundHalfEven(t *testing.T) {
+	tests := []struct {
+		value string
+		scale int
+		want  string
+	}{
+		{"0.125", 2, "0.12"},
+		{"0.135", 2, "0.14"},
+		{"-0.125", 2, "-0.12"},
+		{"-0.135", 2, "-0.14"},
+		{"0.1251", 2, "0.13"},
+		{"2/3", 3, "0.667"},
+		{"-2/3", 3, "-0.667"},
+		{"0.5", 0, "0"},
+		{"1.5", 0, "2"},
+		{"0.25", 4, "0.2500"},
+	}
+	for _, tt := range tests {
+		r, ok := new(big.Rat).SetString(tt.value)
+		assert.True(t, ok)
+		assert.Equal(t, tt.want, roundHalfEven(r, tt.scale).FloatString(tt.scale), tt.value)
+	}
+}
+
+func TestMap_EqualToIgnoresOrder(t *testing.T) {
+	m1 := NewMap()
+	m1.Add(NewString("x"), NewInt(1))
+	m1.Add(NewString("y"), NewInt(2))
+
+	m2 := NewMap()
+	m2.Add(NewString("y"), NewInt(2))
+	m2.Add(NewString("x"), NewInt(1))
+	eq, err := m1.EqualTo(m2)
+	assert.Nil(t, err)
+	assert.Equal(t, TRUE, eq)
+
+	m2.Set(NewString("x"), NewInt(3))
+	eq, _ = m1.EqualTo(m2)
+	assert.Equal(t, FALSE, eq)
+}
+
+func TestInstance_DefaultProtocols(t *testing.T) {
+	class, err := NewClass("P", NIL, map[string]Method{})
+	assert.Nil(t, err)
+
+	a, err := class.Call(NewEvaluator(), []Object{})
+	assert.Nil(t, err)
+	b, _ := class.Call(NewEvaluator(), []Object{})
+
+	_, ok := AsHasher(a)
+	assert.False(t, ok)
+	eq, _ := EqualTo(a, a)
+	assert.Equal(t, TRUE, eq)
+	eq, _ = EqualTo(a, b)
+	assert.Equal(t, FALSE, eq)
+	lt, _ := LessThan(a, b)
+	assert.Equal(t, FALSE, lt)
+	truthy, _ := IsTruthy(a)
+	assert.True(t, truthy)
+	assert.Equal(t, "<P instance>", a.String())
+
+	_, err = Add(a, NewInt(1))
+	assert.EqualError(t, err, "Cannot add type P")
+}
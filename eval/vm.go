@@ -0,0 +1,565 @@
+package eval
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/shreerangdixit/yeti/ast"
+	"github.com/shreerangdixit/yeti/compile"
+)
+
+// VM executes bytecode produced by the compile package
+//
+// Globals are shared with the host evaluator's global environment, so natives,
+// imports and REPL sessions behave the same on both engines. Locals live in
+// scopes that mirror the compiler's scopes and are accessed by (depth, slot).
+type VM struct {
+	host      *Evaluator
+	stack     []Object
+	frames    []*frame
+	handlers  []handler
+	constants map[*compile.Chunk][]Object
+}
+
+// frame is a running closure along with the calls it deferred, which are run when it
+// returns or when an error unwinds it
+type frame struct {
+	closure   *Closure
+	chunk     *compile.Chunk
+	constants []Object
+	ip        int
+	scope     *vmScope
+	base      int
+	deferred  []*Closure
+}
+
+// handler is an installed try block: errors raised while it is installed unwind
+// the frames, stack and scope to the state it was installed in and jump to target
+type handler struct {
+	frame   int
+	target  int
+	stack   int
+	scope   *vmScope
+	finally bool
+}
+
+// pendingError holds an error on the stack while a finally block runs before it is rethrown
+type pendingError struct {
+	err error
+}
+
+func (p pendingError) Type() ObjectType { return TypeError }
+func (p pendingError) String() string   { return p.err.Error() }
+
+type vmScope struct {
+	slots  []Object
+	parent *vmScope
+}
+
+func NewVM(host *Evaluator) *VM {
+	return &VM{
+		host:      host,
+		stack:     make([]Object, 0, 1024),
+		frames:    make([]*frame, 0, 64),
+		handlers:  make([]handler, 0, 16),
+		constants: make(map[*compile.Chunk][]Object),
+	}
+}
+
+// Run compiles and executes a program or a single expression
+func (vm *VM) Run(root ast.Node) (Object, error) {
+	fn, err := compile.New().Compile(root)
+	if err != nil {
+		return NIL, err
+	}
+
+	return vm.call(NewClosure(fn, nil), []Object{})
+}
+
+// call runs a closure to completion and returns its result
+func (vm *VM) call(c *Closure, args []Object) (Object, error) {
+	depth := len(vm.frames)
+	base := len(vm.stack)
+
+	vm.pushFrame(c, args)
+	val, err := vm.run(depth)
+	if err != nil {
+		err = vm.unwind(depth, err)
+		vm.stack = vm.stack[:base]
+	}
+	return val, err
+}
+
+// run executes instructions until the frame at the given depth returns
+func (vm *VM) run(depth int) (Object, error) {
+	for {
+		f := vm.frames[len(vm.frames)-1]
+		offset := f.ip
+		op := compile.Opcode(f.chunk.Code[offset])
+		f.ip += op.Size()
+
+		var err error
+		switch op {
+		case compile.OP_CONSTANT:
+			vm.push(f.constants[f.chunk.ReadUint16(offset+1)])
+		case compile.OP_NIL:
+			vm.push(NIL)
+		case compile.OP_TRUE:
+			vm.push(TRUE)
+		case compile.OP_FALSE:
+			vm.push(FALSE)
+		case compile.OP_POP:
+			vm.pop()
+		case compile.OP_DUP:
+			vm.push(vm.peek())
+		case compile.OP_DUP2:
+			vm.stack = append(vm.stack, vm.stack[len(vm.stack)-2:]...)
+		case compile.OP_DEFINE_GLOBAL:
+			err = vm.host.globalEnv.Declare(vm.name(f, offset), vm.pop())
+		case compile.OP_GET_GLOBAL:
+			var val Object
+			val, err = vm.host.globalEnv.Get(vm.name(f, offset))
+			vm.push(val)
+		case compile.OP_SET_GLOBAL:
+			err = vm.host.globalEnv.Assign(vm.name(f, offset), vm.pop())
+		case compile.OP_DEFINE_LOCAL:
+			f.scope.slots[f.chunk.ReadUint16(offset+1)] = vm.pop()
+		case compile.OP_GET_LOCAL:
+			s := f.scope.ancestor(f.chunk.ReadUint16(offset + 1))
+			vm.push(s.slots[f.chunk.ReadUint16(offset+3)])
+		case compile.OP_SET_LOCAL:
+			s := f.scope.ancestor(f.chunk.ReadUint16(offset + 1))
+			s.slots[f.chunk.ReadUint16(offset+3)] = vm.pop()
+		case compile.OP_PUSH_SCOPE:
+			f.scope = newVMScope(f.chunk.ReadUint16(offset+1), f.scope)
+		case compile.OP_POP_SCOPE:
+			f.scope = f.scope.parent
+		case compile.OP_ADD:
+			err = vm.binaryOp(Add)
+		case compile.OP_SUBTRACT:
+			err = vm.binaryOp(Subtract)
+		case compile.OP_MULTIPLY:
+			err = vm.binaryOp(Multiply)
+		case compile.OP_DIVIDE:
+			err = vm.binaryOp(Divide)
+		case compile.OP_MODULO:
+			err = vm.binaryOp(Modulo)
+		case compile.OP_NEGATE:
+			var val Object
+			val, err = Negate(vm.pop())
+			vm.push(val)
+		case compile.OP_NOT:
+			var val Object
+			val, err = Not(vm.pop())
+			vm.push(val)
+		case compile.OP_TRUTHY:
+			var truthy bool
+			truthy, err = IsTruthy(vm.pop())
+			vm.push(NewBool(truthy))
+		case compile.OP_AND:
+			err = vm.binaryOp(And)
+		case compile.OP_EQ:
+			err = vm.comparison(EqualTo)
+		case compile.OP_NEQ:
+			err = vm.comparison(NotEqualTo)
+		case compile.OP_LT:
+			err = vm.comparison(LessThan)
+		case compile.OP_LTE:
+			err = vm.comparison(LessThanEq)
+		case compile.OP_GT:
+			err = vm.comparison(GreaterThan)
+		case compile.OP_GTE:
+			err = vm.comparison(GreaterThanEq)
+		case compile.OP_IN:
+			err = vm.binaryOp(In)
+		case compile.OP_JUMP:
+			f.ip += f.chunk.ReadUint16(offset + 1)
+		case compile.OP_JUMP_IF_FALSE:
+			var truthy bool
+			if truthy, err = IsTruthy(vm.pop()); err == nil && !truthy {
+				f.ip += f.chunk.ReadUint16(offset + 1)
+			}
+		case compile.OP_LOOP:
+			f.ip -= f.chunk.ReadUint16(offset + 1)
+		case compile.OP_ITER:
+			val := vm.pop()
+			if seq, ok := val.(Sequence); ok {
+				vm.push(seq.Iterator())
+			} else {
+				err = fmt.Errorf("%s is not iterable", val.Type())
+			}
+		case compile.OP_ITER_NEXT:
+			values, ok := vm.peek().(*Iterator).NextVariables(int(f.chunk.Code[offset+3]))
+			if !ok {
+				f.ip += f.chunk.ReadUint16(offset + 1)
+			}
+			for _, val := range values {
+				vm.push(val)
+			}
+		case compile.OP_CALL:
+			err = vm.callValue(int(f.chunk.Code[offset+1]))
+		case compile.OP_RETURN:
+			if err = vm.runDeferred(f); err != nil {
+				break
+			}
+			val := vm.pop()
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.stack = vm.stack[:f.base]
+			if len(vm.frames) == depth {
+				return val, nil
+			}
+			vm.push(val)
+		case compile.OP_CLOSURE:
+			fn := f.chunk.Constants[f.chunk.ReadUint16(offset+1)].(*compile.Function)
+			vm.push(NewClosure(fn, f.scope))
+		case compile.OP_DEFER:
+			f.deferred = append(f.deferred, vm.pop().(*Closure))
+		case compile.OP_LIST:
+			n := f.chunk.ReadUint16(offset + 1)
+			elements := make([]Object, n)
+			copy(elements, vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(NewList(elements))
+		case compile.OP_TUPLE:
+			n := f.chunk.ReadUint16(offset + 1)
+			elements := make([]Object, n)
+			copy(elements, vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(NewTuple(elements))
+		case compile.OP_UNPACK, compile.OP_UNPACK_REST:
+			unpack := Unpack
+			if op == compile.OP_UNPACK_REST {
+				unpack = UnpackRest
+			}
+			var values []Object
+			values, err = unpack(vm.pop(), f.chunk.ReadUint16(offset+1))
+			for i := len(values) - 1; i >= 0; i-- {
+				vm.push(values[i])
+			}
+		case compile.OP_GET_KEY:
+			var val Object
+			val, err = UnpackKey(vm.pop(), vm.name(f, offset))
+			vm.push(val)
+		case compile.OP_INTERPOLATE:
+			n := f.chunk.ReadUint16(offset + 1)
+			var val String
+			val, err = Interpolate(vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(val)
+		case compile.OP_MAP:
+			err = vm.buildMap(f.chunk.ReadUint16(offset + 1))
+		case compile.OP_SET:
+			err = vm.buildSet(f.chunk.ReadUint16(offset + 1))
+		case compile.OP_INDEX:
+			idx, seq := vm.pop(), vm.pop()
+			var val Object
+			val, err = ItemAtIndex(seq, idx)
+			vm.push(val)
+		case compile.OP_SLICE:
+			step, stop, start, seq := vm.pop(), vm.pop(), vm.pop(), vm.pop()
+			var val Object
+			val, err = SliceOf(seq, start, stop, step)
+			vm.push(val)
+		case compile.OP_GET_ATTR:
+			var val Object
+			val, err = GetAttr(vm.pop(), vm.name(f, offset))
+			vm.push(val)
+		case compile.OP_SET_ATTR:
+			value, obj := vm.pop(), vm.pop()
+			err = SetAttr(obj, vm.name(f, offset), value)
+		case compile.OP_STRUCT:
+			def := f.chunk.Constants[f.chunk.ReadUint16(offset+1)].(compile.StructConstant)
+			vm.push(NewStructType(def.Name, def.Fields))
+		case compile.OP_CLASS:
+			err = vm.buildClass(vm.name(f, offset), f.chunk.ReadUint16(offset+3))
+		case compile.OP_GET_SUPER:
+			this, superclass := vm.pop(), vm.pop()
+			var val Object
+			val, err = superclass.(*Class).Bound(this, vm.name(f, offset))
+			vm.push(val)
+		case compile.OP_SET_INDEX:
+			value, idx, seq := vm.pop(), vm.pop(), vm.pop()
+			err = SetItemAtIndex(seq, idx, value)
+		case compile.OP_ASSERT:
+			var truthy bool
+			if truthy, err = IsTruthy(vm.pop()); err == nil && !truthy {
+				err = NewAssertError(f.chunk.Node(offset).(ast.AssertStmtNode).Exp)
+			}
+		case compile.OP_IMPORT:
+			err = vm.host.Importer.Import(NewFileModule(vm.name(f, offset)))
+		case compile.OP_TRY, compile.OP_TRY_FINALLY:
+			vm.handlers = append(vm.handlers, handler{
+				frame:   len(vm.frames) - 1,
+				target:  f.ip + f.chunk.ReadUint16(offset+1),
+				stack:   len(vm.stack),
+				scope:   f.scope,
+				finally: op == compile.OP_TRY_FINALLY,
+			})
+		case compile.OP_END_TRY:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case compile.OP_THROW:
+			err = NewThrowError(vm.pop())
+		case compile.OP_MATCH:
+			arm := f.chunk.Node(offset).(ast.MatchArmNode)
+			var captures []Object
+			var ok bool
+			captures, ok, err = vm.host.match(arm.Pattern, vm.peek(), nil)
+			copy(f.scope.slots, captures)
+			vm.push(NewBool(ok))
+		case compile.OP_NO_MATCH:
+			err = noMatch(vm.pop())
+		case compile.OP_RETHROW:
+			err = vm.pop().(pendingError).err
+		default:
+			err = fmt.Errorf("invalid opcode: %s", op)
+		}
+
+		if err != nil {
+			node := f.chunk.Node(offset)
+			if inner, ok := err.(EvaluateError); !ok {
+				err = NewEvaluateError(node, err)
+			} else if op != compile.OP_RETHROW {
+				err = NewEvaluateError(node, inner, WithInnerError(inner))
+			}
+			var handled bool
+			if handled, err = vm.handle(depth, err); !handled {
+				return NIL, err
+			}
+		}
+	}
+}
+
+// handle unwinds to the innermost handler installed by the frames of this run, if there is one
+// Catch handlers get the caught value, finally handlers get the error to rethrow once they're done.
+// The frames above the handler's frame are unwound first, so the error returned is the one raised
+// by their deferred calls, if any.
+func (vm *VM) handle(depth int, err error) (bool, error) {
+	if len(vm.handlers) == 0 {
+		return false, err
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	if h.frame < depth {
+		return false, err
+	}
+
+	err = vm.unwind(h.frame+1, err)
+	var val Object = pendingError{err}
+	if !h.finally {
+		caught, ok := Caught(err)
+		if !ok {
+			return false, err
+		}
+		val = caught
+	}
+
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.stack = vm.stack[:h.stack]
+
+	f := vm.frames[h.frame]
+	f.scope = h.scope
+	f.ip = h.target
+	vm.push(val)
+	return true, nil
+}
+
+// unwind pops the frames above depth, innermost first, after running the calls they deferred
+// An error raised by a deferred call replaces the error being unwound
+func (vm *VM) unwind(depth int, err error) error {
+	for len(vm.frames) > depth {
+		f := vm.frames[len(vm.frames)-1]
+		vm.stack = vm.stack[:f.base]
+		if deferredErr := vm.runDeferred(f); deferredErr != nil {
+			err = deferredErr
+		}
+		vm.frames = vm.frames[:len(vm.frames)-1]
+	}
+	return err
+}
+
+func (vm *VM) pushFrame(c *Closure, args []Object) {
+	scope := newVMScope(c.fn.ScopeSize, c.scope)
+	copy(scope.slots, args)
+
+	chunk := c.fn.Chunk
+	constants, ok := vm.constants[chunk]
+	if !ok {
+		constants = loadConstants(chunk)
+		vm.constants[chunk] = constants
+	}
+
+	vm.frames = append(vm.frames, &frame{
+		closure:   c,
+		chunk:     chunk,
+		constants: constants,
+		scope:     scope,
+		base:      len(vm.stack),
+	})
+}
+
+// callValue calls the callee below the top argc values of the stack
+// Closures are run on the current dispatch loop, other callables are invoked directly
+func (vm *VM) callValue(argc int) error {
+	calleeIdx := len(vm.stack) - argc - 1
+	callee := vm.stack[calleeIdx]
+
+	callable, ok := callee.(Callable)
+	if !ok { // If the callee itself isn't callable, check if it's value is callable
+		calleeValue, err := vm.host.globalEnv.Get(callee.String())
+		if err != nil {
+			return fmt.Errorf("%s is not callable", callee.Type())
+		}
+
+		callable, ok = calleeValue.(Callable)
+		if !ok {
+			return fmt.Errorf("%s is not callable", calleeValue.Type())
+		}
+	}
+
+	if !callable.Variadic() && callable.Arity() != argc {
+		return fmt.Errorf("incorrect number of arguments to %s - %d expected %d provided", callable, callable.Arity(), argc)
+	}
+
+	args := make([]Object, argc)
+	copy(args, vm.stack[calleeIdx+1:])
+	vm.stack = vm.stack[:calleeIdx]
+
+	if closure, ok := callable.(*Closure); ok {
+		vm.pushFrame(closure, args)
+		return nil
+	}
+
+	val, err := callable.Call(vm.host, args)
+	if err != nil {
+		return err
+	}
+	vm.push(val)
+	return nil
+}
+
+// runDeferred runs the calls deferred by a frame in order, an error stops the remaining calls
+func (vm *VM) runDeferred(f *frame) error {
+	deferred := f.deferred
+	f.deferred = nil
+	for _, c := range deferred {
+		if _, err := vm.call(c, []Object{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildMap pops the keys and values only once the map is built, since hashing
+// an instance calls its __hash__ method which uses the stack above them
+func (vm *VM) buildMap(n int) error {
+	pairs := vm.stack[len(vm.stack)-2*n:]
+
+	m := NewMap()
+	for i := 0; i < len(pairs); i += 2 {
+		if err := m.Add(pairs[i], pairs[i+1]); err != nil {
+			return err
+		}
+	}
+	vm.stack = vm.stack[:len(vm.stack)-2*n]
+	vm.push(m)
+	return nil
+}
+
+func (vm *VM) buildSet(n int) error {
+	elements := vm.stack[len(vm.stack)-n:]
+
+	set := NewSet()
+	for _, elem := range elements {
+		if err := set.Add(elem); err != nil {
+			return err
+		}
+	}
+	vm.stack = vm.stack[:len(vm.stack)-n]
+	vm.push(set)
+	return nil
+}
+
+// buildClass pops n method closures and the superclass below them, which is nil for classes without one
+func (vm *VM) buildClass(name string, n int) error {
+	methods := make(map[string]Method, n)
+	for _, method := range vm.stack[len(vm.stack)-n:] {
+		methods[method.(*Closure).Name()] = method.(*Closure)
+	}
+	vm.stack = vm.stack[:len(vm.stack)-n]
+
+	class, err := NewClass(name, vm.pop(), methods)
+	if err != nil {
+		return err
+	}
+	vm.push(class)
+	return nil
+}
+
+func (vm *VM) binaryOp(op func(Object, Object) (Object, error)) error {
+	right, left := vm.pop(), vm.pop()
+	val, err := op(left, right)
+	vm.push(val)
+	return err
+}
+
+func (vm *VM) comparison(op func(Object, Object) (Bool, error)) error {
+	right, left := vm.pop(), vm.pop()
+	val, err := op(left, right)
+	vm.push(val)
+	return err
+}
+
+func (vm *VM) name(f *frame, offset int) string {
+	return f.chunk.Constants[f.chunk.ReadUint16(offset+1)].(string)
+}
+
+func (vm *VM) push(o Object) {
+	vm.stack = append(vm.stack, o)
+}
+
+func (vm *VM) pop() Object {
+	o := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return o
+}
+
+func (vm *VM) peek() Object {
+	return vm.stack[len(vm.stack)-1]
+}
+
+func newVMScope(size int, parent *vmScope) *vmScope {
+	return &vmScope{
+		slots:  make([]Object, size),
+		parent: parent,
+	}
+}
+
+func (s *vmScope) ancestor(depth int) *vmScope {
+	for i := 0; i < depth; i++ {
+		s = s.parent
+	}
+	return s
+}
+
+// loadConstants converts a chunk's constant pool into objects
+// Function constants are only used by OP_CLOSURE and are left as nil
+func loadConstants(chunk *compile.Chunk) []Object {
+	constants := make([]Object, len(chunk.Constants))
+	for i, c := range chunk.Constants {
+		switch c := c.(type) {
+		case int64:
+			constants[i] = NewInt(c)
+		case *big.Int:
+			constants[i] = NewBigInt(c)
+		case compile.DecimalConstant:
+			constants[i] = NewDecimal(c.Value, c.Scale)
+		case float64:
+			constants[i] = NewNumber(c)
+		case string:
+			constants[i] = NewString(c)
+		default:
+			constants[i] = NIL
+		}
+	}
+	return constants
+}
@@ -9,10 +9,23 @@ var Keywords = map[string]TokenType{
 	"false":    TT_FALSE,
 	"return":   TT_RETURN,
 	"while":    TT_WHILE,
+	"do":       TT_DO,
+	"for":      TT_FOR,
+	"in":       TT_IN,
+	"not":      TT_NOT_KEYWORD,
 	"nil":      TT_NIL,
 	"break":    TT_BREAK,
 	"continue": TT_CONTINUE,
 	"defer":    TT_DEFER,
 	"assert":   TT_ASSERT,
 	"import":   TT_IMPORT,
+	"struct":   TT_STRUCT,
+	"class":    TT_CLASS,
+	"this":     TT_THIS,
+	"super":    TT_SUPER,
+	"throw":    TT_THROW,
+	"try":      TT_TRY,
+	"catch":    TT_CATCH,
+	"finally":  TT_FINALLY,
+	"match":    TT_MATCH,
 }
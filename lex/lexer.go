@@ -1,14 +1,28 @@
 package lex
 
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 type Lexer struct {
 	input      string
 	readPos    int
 	currentPos int
-	ch         byte
+	ch         rune
 	line       int
 	col        int
 	tokBegin   Position
 	tokEnd     Position
+	// Embedded expressions of interpolated strings that are being lexed, innermost last
+	interpolations []interpolation
+}
+
+type interpolation struct {
+	braces int      // Number of unclosed braces within the embedded expression
+	begin  Position // Position of the opening quote of the string
 }
 
 func New(input string) *Lexer {
@@ -32,6 +46,11 @@ func (l *Lexer) NextToken() Token {
 			l.advance()
 			literal := string(ch) + string(l.ch)
 			tok = newToken(TT_EQ, literal)
+		} else if l.peek() == '>' {
+			ch := l.ch
+			l.advance()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(TT_ARROW, literal)
 		} else {
 			tok = newToken(TT_ASSIGN, string(l.ch))
 		}
@@ -77,7 +96,7 @@ func (l *Lexer) NextToken() Token {
 			literal := string(ch) + string(l.ch)
 			tok = newToken(TT_LOGICAL_AND, literal)
 		} else {
-			tok = newToken(TT_ILLEGAL, string(l.ch))
+			tok = newIllegalToken(string(l.ch), "unexpected character")
 		}
 		l.tokenEnd()
 	case '|':
@@ -88,37 +107,84 @@ func (l *Lexer) NextToken() Token {
 			literal := string(ch) + string(l.ch)
 			tok = newToken(TT_LOGICAL_OR, literal)
 		} else {
-			tok = newToken(TT_ILLEGAL, string(l.ch))
+			tok = newIllegalToken(string(l.ch), "unexpected character")
 		}
 		l.tokenEnd()
 	case '+':
 		l.tokenBegin()
-		tok = newToken(TT_PLUS, string(l.ch))
+		if l.peek() == '=' {
+			ch := l.ch
+			l.advance()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(TT_PLUS_ASSIGN, literal)
+		} else {
+			tok = newToken(TT_PLUS, string(l.ch))
+		}
 		l.tokenEnd()
 	case '-':
 		l.tokenBegin()
-		tok = newToken(TT_MINUS, string(l.ch))
+		if l.peek() == '=' {
+			ch := l.ch
+			l.advance()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(TT_MINUS_ASSIGN, literal)
+		} else {
+			tok = newToken(TT_MINUS, string(l.ch))
+		}
 		l.tokenEnd()
 	case '/':
 		l.tokenBegin()
 		if l.peek() == '/' {
 			tok = l.readCommentToken()
+		} else if l.peek() == '=' {
+			ch := l.ch
+			l.advance()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(TT_DIVIDE_ASSIGN, literal)
 		} else {
 			tok = newToken(TT_DIVIDE, string(l.ch))
 		}
 		l.tokenEnd()
 	case '*':
 		l.tokenBegin()
-		tok = newToken(TT_MULTIPLY, string(l.ch))
+		if l.peek() == '=' {
+			ch := l.ch
+			l.advance()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(TT_MULTIPLY_ASSIGN, literal)
+		} else {
+			tok = newToken(TT_MULTIPLY, string(l.ch))
+		}
 		l.tokenEnd()
 	case '%':
 		l.tokenBegin()
-		tok = newToken(TT_MODULO, string(l.ch))
+		if l.peek() == '=' {
+			ch := l.ch
+			l.advance()
+			literal := string(ch) + string(l.ch)
+			tok = newToken(TT_MODULO_ASSIGN, literal)
+		} else {
+			tok = newToken(TT_MODULO, string(l.ch))
+		}
 		l.tokenEnd()
 	case ',':
 		l.tokenBegin()
 		tok = newToken(TT_COMMA, string(l.ch))
 		l.tokenEnd()
+	case '.':
+		l.tokenBegin()
+		if l.peek() == '.' {
+			l.advance()
+			if l.peek() == '.' {
+				l.advance()
+				tok = newToken(TT_ELLIPSIS, "...")
+			} else {
+				tok = newIllegalToken("..", "unexpected character")
+			}
+		} else {
+			tok = newToken(TT_DOT, string(l.ch))
+		}
+		l.tokenEnd()
 	case '(':
 		l.tokenBegin()
 		tok = newToken(TT_LPAREN, string(l.ch))
@@ -129,11 +195,37 @@ func (l *Lexer) NextToken() Token {
 		l.tokenEnd()
 	case '{':
 		l.tokenBegin()
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].braces++
+		}
 		tok = newToken(TT_LBRACE, string(l.ch))
 		l.tokenEnd()
 	case '}':
 		l.tokenBegin()
-		tok = newToken(TT_RBRACE, string(l.ch))
+		if n := len(l.interpolations); n > 0 && l.interpolations[n-1].braces == 0 {
+			begin := l.interpolations[n-1].begin
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readStringContents(begin, TT_STRING_END)
+		} else {
+			if n > 0 {
+				l.interpolations[n-1].braces--
+			}
+			tok = newToken(TT_RBRACE, string(l.ch))
+			l.tokenEnd()
+		}
+	case '#':
+		l.tokenBegin()
+		if l.peek() == '{' {
+			ch := l.ch
+			l.advance()
+			if n := len(l.interpolations); n > 0 {
+				l.interpolations[n-1].braces++
+			}
+			literal := string(ch) + string(l.ch)
+			tok = newToken(TT_HASH_LBRACE, literal)
+		} else {
+			tok = newIllegalToken(string(l.ch), "unexpected character")
+		}
 		l.tokenEnd()
 	case '[':
 		l.tokenBegin()
@@ -151,6 +243,10 @@ func (l *Lexer) NextToken() Token {
 		l.tokenBegin()
 		tok = newToken(TT_COLON, string(l.ch))
 		l.tokenEnd()
+	case ';':
+		l.tokenBegin()
+		tok = newToken(TT_SEMICOLON, string(l.ch))
+		l.tokenEnd()
 	case 0:
 		tok = newToken(TT_EOF, "0")
 	default:
@@ -163,12 +259,12 @@ func (l *Lexer) NextToken() Token {
 			tok = l.readIdentifierToken()
 			l.tokenEnd()
 		} else if l.ch == '"' {
-			l.tokenBegin()
 			tok = l.readStringToken()
-			l.tokenEnd()
+		} else if l.ch == '`' {
+			tok = l.readRawStringToken()
 		} else {
 			l.tokenBegin()
-			tok = newToken(TT_ILLEGAL, string(l.ch))
+			tok = newIllegalToken(string(l.ch), "unexpected character")
 			l.tokenEnd()
 		}
 	}
@@ -192,45 +288,60 @@ func (l *Lexer) tokenEnd() {
 	}
 }
 
+// advance moves to the next character, the input is decoded as UTF-8 and columns are counted in characters
 func (l *Lexer) advance() {
+	l.currentPos = l.readPos
 	if l.readPos >= len(l.input) {
 		l.ch = 0
+		l.readPos += 1
 	} else {
-		l.ch = l.input[l.readPos]
+		r, width := utf8.DecodeRuneInString(l.input[l.readPos:])
+		l.ch = r
+		l.readPos += width
 	}
-	l.currentPos = l.readPos
-	l.readPos += 1
 	l.col += 1
 }
 
+// rewind moves back by one character, it must be followed by advance() to reload the current character
 func (l *Lexer) rewind() {
-	l.currentPos -= 1
-	l.readPos -= 1
+	l.readPos = l.currentPos
+	if l.currentPos > len(l.input) {
+		l.currentPos -= 1
+	} else {
+		_, width := utf8.DecodeLastRuneInString(l.input[:l.currentPos])
+		l.currentPos -= width
+	}
 	l.col -= 1
 }
 
-func (l *Lexer) peek() byte {
+func (l *Lexer) peek() rune {
 	if l.readPos >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPos]
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPos:])
+	return r
 }
 
+// readNumberToken reads everything that looks like part of a number and then validates it
+// so that malformed numbers like 1.2.3 or 0x are reported as a whole
 func (l *Lexer) readNumberToken() Token {
 	defer l.rewind()
 	startPos := l.currentPos
-	decimalCount := 0
-	for isDigit(l.ch) || l.ch == '.' {
-		if l.ch == '.' {
-			decimalCount += 1
-		}
-		if decimalCount > 1 {
-			return newToken(TT_ILLEGAL, string(l.ch))
+	for {
+		if isLetter(l.ch) || isDigit(l.ch) || l.ch == '.' && isDigit(l.peek()) {
+			l.advance()
+		} else if (l.ch == '+' || l.ch == '-') && isExponentSign(l.input[startPos:l.currentPos]) {
+			l.advance()
+		} else {
+			break
 		}
-		l.advance()
 	}
-	numStr := l.input[startPos:l.currentPos]
-	return newToken(TT_NUMBER, numStr)
+
+	literal := l.input[startPos:l.currentPos]
+	if err := checkNumber(literal); err != "" {
+		return newIllegalToken(literal, err)
+	}
+	return newToken(TT_NUMBER, literal)
 }
 
 func (l *Lexer) readIdentifierToken() Token {
@@ -243,14 +354,132 @@ func (l *Lexer) readIdentifierToken() Token {
 	return newToken(LookupIdentifierType(value), value)
 }
 
+// readStringToken reads a double quoted string and decodes its escape sequences
+// Strings cannot span lines, use raw strings for multiline text
 func (l *Lexer) readStringToken() Token {
+	l.tokenBegin()
+	return l.readStringContents(l.tokBegin, TT_STRING)
+}
+
+// readStringContents reads the characters following the current one up to the closing quote,
+// which produces a token of the given type, or up to an embedded expression "${", which produces a TT_STRING_PART
+// The embedded expression is lexed as regular tokens until its closing brace resumes the string
+func (l *Lexer) readStringContents(begin Position, endType TokenType) Token {
+	segmentBegin := l.tokBegin
+
+	var sb strings.Builder
 	l.advance()
-	startPos := l.currentPos
 	for l.ch != '"' {
+		if l.ch == 0 || isNewline(l.ch) {
+			if l.ch != 0 {
+				l.rewind()
+			}
+			l.tokBegin = begin
+			l.tokEnd = begin
+			return newIllegalToken("\"", "unterminated string")
+		}
+
+		if l.ch == '$' && l.peek() == '{' {
+			l.advance()
+			l.interpolations = append(l.interpolations, interpolation{begin: begin})
+			l.tokBegin = segmentBegin
+			l.tokenEnd()
+			return newToken(TT_STRING_PART, sb.String())
+		}
+
+		if l.ch == '\\' {
+			l.tokenBegin()
+			escBegin := l.currentPos
+			decoded, ok := l.readEscapeSequence()
+			if !ok {
+				l.tokenEnd()
+				return newIllegalToken(l.input[escBegin:l.currentPos+1], "invalid escape sequence")
+			}
+			sb.WriteString(decoded)
+		} else {
+			sb.WriteRune(l.ch)
+		}
 		l.advance()
 	}
-	value := l.input[startPos:l.currentPos]
-	return newToken(TT_STRING, value)
+
+	l.tokBegin = segmentBegin
+	l.tokenEnd()
+	return newToken(endType, sb.String())
+}
+
+// readEscapeSequence decodes the escape sequence beginning at the current backslash
+// The lexer is left on the last character of the sequence
+func (l *Lexer) readEscapeSequence() (string, bool) {
+	l.advance()
+	switch l.ch {
+	case 'n':
+		return "\n", true
+	case 't':
+		return "\t", true
+	case 'r':
+		return "\r", true
+	case '0':
+		return "\x00", true
+	case '"':
+		return "\"", true
+	case '$':
+		return "$", true
+	case '\\':
+		return "\\", true
+	case 'u':
+		if l.peek() != '{' {
+			return "", false
+		}
+		l.advance()
+
+		digits := ""
+		for isHexDigit(l.peek()) && len(digits) < 6 {
+			l.advance()
+			digits += string(l.ch)
+		}
+
+		if len(digits) == 0 || l.peek() != '}' {
+			return "", false
+		}
+		l.advance()
+
+		codepoint, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(codepoint)) {
+			return "", false
+		}
+		return string(rune(codepoint)), true
+	default:
+		return "", false
+	}
+}
+
+// readRawStringToken reads a backtick quoted string verbatim, raw strings can span lines
+// Carriage returns are discarded so that files with CRLF line endings produce the same value
+func (l *Lexer) readRawStringToken() Token {
+	l.tokenBegin()
+	begin := l.tokBegin
+
+	var sb strings.Builder
+	l.advance()
+	for l.ch != '`' {
+		if l.ch == 0 {
+			l.tokBegin = begin
+			l.tokEnd = begin
+			return newIllegalToken("`", "unterminated raw string")
+		}
+
+		if l.ch == '\n' {
+			l.lineFeed()
+		}
+
+		if l.ch != '\r' {
+			sb.WriteRune(l.ch)
+		}
+		l.advance()
+	}
+
+	l.tokenEnd()
+	return newToken(TT_STRING, sb.String())
 }
 
 func (l *Lexer) readCommentToken() Token {
@@ -284,18 +513,31 @@ func newToken(tokenType TokenType, literal string) Token {
 	}
 }
 
-func isDigit(b byte) bool {
-	return b >= '0' && b <= '9'
+func newIllegalToken(literal string, err string) Token {
+	return Token{
+		Type:    TT_ILLEGAL,
+		Literal: literal,
+		Err:     err,
+	}
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isHexDigit(r rune) bool {
+	return isDigit(r) || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
 }
 
-func isLetter(b byte) bool {
-	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == '_'
+// isLetter accepts any Unicode letter so that identifiers aren't limited to ASCII
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
 }
 
-func isWhitespace(b byte) bool {
-	return b == ' ' || b == '\n' || b == '\r' || b == '\t'
+func isWhitespace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\r' || r == '\t'
 }
 
-func isNewline(b byte) bool {
-	return b == '\n' || b == '\r'
+func isNewline(r rune) bool {
+	return r == '\n' || r == '\r'
 }
@@ -61,12 +61,79 @@ func TestLexer_NextToken(t *testing.T) {
 			name:  "bad_floats",
 			input: ".123 1.23",
 			want: []Token{
-				{Type: TT_ILLEGAL, Literal: ".", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 1}},
+				{Type: TT_DOT, Literal: ".", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 1}},
 				{Type: TT_NUMBER, Literal: "123", BeginPosition: Position{Line: 1, Column: 2}, EndPosition: Position{Line: 1, Column: 4}},
 				{Type: TT_NUMBER, Literal: "1.23", BeginPosition: Position{Line: 1, Column: 6}, EndPosition: Position{Line: 1, Column: 9}},
 				{Type: TT_EOF, Literal: "0", BeginPosition: Position{Line: 1, Column: 6}, EndPosition: Position{Line: 1, Column: 9}},
 			},
 		},
+		{
+			name:  "number_literals",
+			input: "0xFF 0b1010 0o17 1e9 6.02e23 1_000_000 2.5E-3",
+			want: []Token{
+				{Type: TT_NUMBER, Literal: "0xFF", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 4}},
+				{Type: TT_NUMBER, Literal: "0b1010", BeginPosition: Position{Line: 1, Column: 6}, EndPosition: Position{Line: 1, Column: 11}},
+				{Type: TT_NUMBER, Literal: "0o17", BeginPosition: Position{Line: 1, Column: 13}, EndPosition: Position{Line: 1, Column: 16}},
+				{Type: TT_NUMBER, Literal: "1e9", BeginPosition: Position{Line: 1, Column: 18}, EndPosition: Position{Line: 1, Column: 20}},
+				{Type: TT_NUMBER, Literal: "6.02e23", BeginPosition: Position{Line: 1, Column: 22}, EndPosition: Position{Line: 1, Column: 28}},
+				{Type: TT_NUMBER, Literal: "1_000_000", BeginPosition: Position{Line: 1, Column: 30}, EndPosition: Position{Line: 1, Column: 38}},
+				{Type: TT_NUMBER, Literal: "2.5E-3", BeginPosition: Position{Line: 1, Column: 40}, EndPosition: Position{Line: 1, Column: 45}},
+			},
+		},
+		{
+			name:  "number_operators",
+			input: "0xe-1 2-1",
+			want: []Token{
+				{Type: TT_NUMBER, Literal: "0xe", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 3}},
+				{Type: TT_MINUS, Literal: "-", BeginPosition: Position{Line: 1, Column: 4}, EndPosition: Position{Line: 1, Column: 4}},
+				{Type: TT_NUMBER, Literal: "1", BeginPosition: Position{Line: 1, Column: 5}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_NUMBER, Literal: "2", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 7}},
+				{Type: TT_MINUS, Literal: "-", BeginPosition: Position{Line: 1, Column: 8}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_NUMBER, Literal: "1", BeginPosition: Position{Line: 1, Column: 9}, EndPosition: Position{Line: 1, Column: 9}},
+			},
+		},
+		{
+			name:  "number_suffixes",
+			input: "123n 0xFFn 1.10d 5d 0xd 1.5n",
+			want: []Token{
+				{Type: TT_NUMBER, Literal: "123n", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 4}},
+				{Type: TT_NUMBER, Literal: "0xFFn", BeginPosition: Position{Line: 1, Column: 6}, EndPosition: Position{Line: 1, Column: 10}},
+				{Type: TT_NUMBER, Literal: "1.10d", BeginPosition: Position{Line: 1, Column: 12}, EndPosition: Position{Line: 1, Column: 16}},
+				{Type: TT_NUMBER, Literal: "5d", BeginPosition: Position{Line: 1, Column: 18}, EndPosition: Position{Line: 1, Column: 19}},
+				{Type: TT_NUMBER, Literal: "0xd", BeginPosition: Position{Line: 1, Column: 21}, EndPosition: Position{Line: 1, Column: 23}},
+				{Type: TT_ILLEGAL, Literal: "1.5n", BeginPosition: Position{Line: 1, Column: 25}, EndPosition: Position{Line: 1, Column: 28}, Err: "bigint literal must be an integer"},
+			},
+		},
+		{
+			name:  "malformed_numbers",
+			input: "1.2.3 0x 0b102 1e 1__0 1_ 12ab 3é",
+			want: []Token{
+				{Type: TT_ILLEGAL, Literal: "1.2.3", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 5}, Err: "number has more than one decimal point"},
+				{Type: TT_ILLEGAL, Literal: "0x", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 8}, Err: "hexadecimal literal has no digits"},
+				{Type: TT_ILLEGAL, Literal: "0b102", BeginPosition: Position{Line: 1, Column: 10}, EndPosition: Position{Line: 1, Column: 14}, Err: "invalid digit '2' in binary literal"},
+				{Type: TT_ILLEGAL, Literal: "1e", BeginPosition: Position{Line: 1, Column: 16}, EndPosition: Position{Line: 1, Column: 17}, Err: "exponent has no digits"},
+				{Type: TT_ILLEGAL, Literal: "1__0", BeginPosition: Position{Line: 1, Column: 19}, EndPosition: Position{Line: 1, Column: 22}, Err: "'_' must separate successive digits"},
+				{Type: TT_ILLEGAL, Literal: "1_", BeginPosition: Position{Line: 1, Column: 24}, EndPosition: Position{Line: 1, Column: 25}, Err: "'_' must separate successive digits"},
+				{Type: TT_ILLEGAL, Literal: "12ab", BeginPosition: Position{Line: 1, Column: 27}, EndPosition: Position{Line: 1, Column: 30}, Err: "invalid character 'a' in number"},
+				{Type: TT_ILLEGAL, Literal: "3é", BeginPosition: Position{Line: 1, Column: 32}, EndPosition: Position{Line: 1, Column: 33}, Err: "invalid character 'é' in number"},
+			},
+		},
+		{
+			name:  "out_of_range_and_leading_zeros",
+			input: "010 09 0 0.5 9223372036854775808 0x8000000000000000 9223372036854775808n 007n 1e400 1e400d",
+			want: []Token{
+				{Type: TT_ILLEGAL, Literal: "010", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 3}, Err: "integer literal has a leading zero, use the 0o prefix for octal"},
+				{Type: TT_ILLEGAL, Literal: "09", BeginPosition: Position{Line: 1, Column: 5}, EndPosition: Position{Line: 1, Column: 6}, Err: "integer literal has a leading zero, use the 0o prefix for octal"},
+				{Type: TT_NUMBER, Literal: "0", BeginPosition: Position{Line: 1, Column: 8}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_NUMBER, Literal: "0.5", BeginPosition: Position{Line: 1, Column: 10}, EndPosition: Position{Line: 1, Column: 12}},
+				{Type: TT_ILLEGAL, Literal: "9223372036854775808", BeginPosition: Position{Line: 1, Column: 14}, EndPosition: Position{Line: 1, Column: 32}, Err: "integer literal is out of range"},
+				{Type: TT_ILLEGAL, Literal: "0x8000000000000000", BeginPosition: Position{Line: 1, Column: 34}, EndPosition: Position{Line: 1, Column: 51}, Err: "integer literal is out of range"},
+				{Type: TT_NUMBER, Literal: "9223372036854775808n", BeginPosition: Position{Line: 1, Column: 53}, EndPosition: Position{Line: 1, Column: 72}},
+				{Type: TT_ILLEGAL, Literal: "007n", BeginPosition: Position{Line: 1, Column: 74}, EndPosition: Position{Line: 1, Column: 77}, Err: "integer literal has a leading zero, use the 0o prefix for octal"},
+				{Type: TT_ILLEGAL, Literal: "1e400", BeginPosition: Position{Line: 1, Column: 79}, EndPosition: Position{Line: 1, Column: 83}, Err: "float literal is out of range"},
+				{Type: TT_NUMBER, Literal: "1e400d", BeginPosition: Position{Line: 1, Column: 85}, EndPosition: Position{Line: 1, Column: 90}},
+			},
+		},
 		{
 			name:  "identifiers",
 			input: "X Y Z aa bb cc_c d",
@@ -138,6 +205,177 @@ func TestLexer_NextToken(t *testing.T) {
 				{Type: TT_EOF, Literal: "0", BeginPosition: Position{Line: 1, Column: 13}, EndPosition: Position{Line: 1, Column: 21}},
 			},
 		},
+		{
+			name:  "string_escapes",
+			input: `"a\tb\n" "\"q\" \\" "\u{1F600}\u{e9}" x`,
+			want: []Token{
+				{Type: TT_STRING, Literal: "a\tb\n", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_STRING, Literal: "\"q\" \\", BeginPosition: Position{Line: 1, Column: 10}, EndPosition: Position{Line: 1, Column: 19}},
+				{Type: TT_STRING, Literal: "\U0001F600é", BeginPosition: Position{Line: 1, Column: 21}, EndPosition: Position{Line: 1, Column: 37}},
+				{Type: TT_IDENTIFIER, Literal: "x", BeginPosition: Position{Line: 1, Column: 39}, EndPosition: Position{Line: 1, Column: 39}},
+			},
+		},
+		{
+			name:  "invalid_escapes",
+			input: `"ab\q" "\u{110000}"`,
+			want: []Token{
+				{Type: TT_ILLEGAL, Literal: "\\q", BeginPosition: Position{Line: 1, Column: 4}, EndPosition: Position{Line: 1, Column: 5}, Err: "invalid escape sequence"},
+			},
+		},
+		{
+			name:  "unterminated_string",
+			input: "var s = \"abc\nx",
+			want: []Token{
+				{Type: TT_VAR, Literal: "var", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 3}},
+				{Type: TT_IDENTIFIER, Literal: "s", BeginPosition: Position{Line: 1, Column: 5}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_ASSIGN, Literal: "=", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 7}},
+				{Type: TT_ILLEGAL, Literal: "\"", BeginPosition: Position{Line: 1, Column: 9}, EndPosition: Position{Line: 1, Column: 9}, Err: "unterminated string"},
+				{Type: TT_IDENTIFIER, Literal: "x", BeginPosition: Position{Line: 2, Column: 1}, EndPosition: Position{Line: 2, Column: 1}},
+			},
+		},
+		{
+			name:  "raw_strings",
+			input: "`a\\n\n  b` x\n`",
+			want: []Token{
+				{Type: TT_STRING, Literal: "a\\n\n  b", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 2, Column: 4}},
+				{Type: TT_IDENTIFIER, Literal: "x", BeginPosition: Position{Line: 2, Column: 6}, EndPosition: Position{Line: 2, Column: 6}},
+				{Type: TT_ILLEGAL, Literal: "`", BeginPosition: Position{Line: 3, Column: 1}, EndPosition: Position{Line: 3, Column: 1}, Err: "unterminated raw string"},
+			},
+		},
+		{
+			name:  "interpolated_strings",
+			input: `"a ${x} b ${ {} } c" "${"${y}"}"`,
+			want: []Token{
+				{Type: TT_STRING_PART, Literal: "a ", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_IDENTIFIER, Literal: "x", BeginPosition: Position{Line: 1, Column: 6}, EndPosition: Position{Line: 1, Column: 6}},
+				{Type: TT_STRING_PART, Literal: " b ", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 12}},
+				{Type: TT_LBRACE, Literal: "{", BeginPosition: Position{Line: 1, Column: 14}, EndPosition: Position{Line: 1, Column: 14}},
+				{Type: TT_RBRACE, Literal: "}", BeginPosition: Position{Line: 1, Column: 15}, EndPosition: Position{Line: 1, Column: 15}},
+				{Type: TT_STRING_END, Literal: " c", BeginPosition: Position{Line: 1, Column: 17}, EndPosition: Position{Line: 1, Column: 20}},
+				{Type: TT_STRING_PART, Literal: "", BeginPosition: Position{Line: 1, Column: 22}, EndPosition: Position{Line: 1, Column: 24}},
+				{Type: TT_STRING_PART, Literal: "", BeginPosition: Position{Line: 1, Column: 25}, EndPosition: Position{Line: 1, Column: 27}},
+				{Type: TT_IDENTIFIER, Literal: "y", BeginPosition: Position{Line: 1, Column: 28}, EndPosition: Position{Line: 1, Column: 28}},
+				{Type: TT_STRING_END, Literal: "", BeginPosition: Position{Line: 1, Column: 29}, EndPosition: Position{Line: 1, Column: 30}},
+				{Type: TT_STRING_END, Literal: "", BeginPosition: Position{Line: 1, Column: 31}, EndPosition: Position{Line: 1, Column: 32}},
+			},
+		},
+		{
+			name:  "assignment_operators",
+			input: "+= -= *= /= %= / =",
+			want: []Token{
+				{Type: TT_PLUS_ASSIGN, Literal: "+=", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 2}},
+				{Type: TT_MINUS_ASSIGN, Literal: "-=", BeginPosition: Position{Line: 1, Column: 4}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_MULTIPLY_ASSIGN, Literal: "*=", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_DIVIDE_ASSIGN, Literal: "/=", BeginPosition: Position{Line: 1, Column: 10}, EndPosition: Position{Line: 1, Column: 11}},
+				{Type: TT_MODULO_ASSIGN, Literal: "%=", BeginPosition: Position{Line: 1, Column: 13}, EndPosition: Position{Line: 1, Column: 14}},
+				{Type: TT_DIVIDE, Literal: "/", BeginPosition: Position{Line: 1, Column: 16}, EndPosition: Position{Line: 1, Column: 16}},
+				{Type: TT_ASSIGN, Literal: "=", BeginPosition: Position{Line: 1, Column: 18}, EndPosition: Position{Line: 1, Column: 18}},
+			},
+		},
+		{
+			name:  "loop_keywords",
+			input: "for (;;) do {} while",
+			want: []Token{
+				{Type: TT_FOR, Literal: "for", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 3}},
+				{Type: TT_LPAREN, Literal: "(", BeginPosition: Position{Line: 1, Column: 5}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_SEMICOLON, Literal: ";", BeginPosition: Position{Line: 1, Column: 6}, EndPosition: Position{Line: 1, Column: 6}},
+				{Type: TT_SEMICOLON, Literal: ";", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 7}},
+				{Type: TT_RPAREN, Literal: ")", BeginPosition: Position{Line: 1, Column: 8}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_DO, Literal: "do", BeginPosition: Position{Line: 1, Column: 10}, EndPosition: Position{Line: 1, Column: 11}},
+				{Type: TT_LBRACE, Literal: "{", BeginPosition: Position{Line: 1, Column: 13}, EndPosition: Position{Line: 1, Column: 13}},
+				{Type: TT_RBRACE, Literal: "}", BeginPosition: Position{Line: 1, Column: 14}, EndPosition: Position{Line: 1, Column: 14}},
+				{Type: TT_WHILE, Literal: "while", BeginPosition: Position{Line: 1, Column: 16}, EndPosition: Position{Line: 1, Column: 20}},
+			},
+		},
+		{
+			name:  "membership_keywords",
+			input: "x not in y",
+			want: []Token{
+				{Type: TT_IDENTIFIER, Literal: "x", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 1}},
+				{Type: TT_NOT_KEYWORD, Literal: "not", BeginPosition: Position{Line: 1, Column: 3}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_IN, Literal: "in", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_IDENTIFIER, Literal: "y", BeginPosition: Position{Line: 1, Column: 10}, EndPosition: Position{Line: 1, Column: 10}},
+			},
+		},
+		{
+			name:  "class_keywords",
+			input: "class this super",
+			want: []Token{
+				{Type: TT_CLASS, Literal: "class", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_THIS, Literal: "this", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 10}},
+				{Type: TT_SUPER, Literal: "super", BeginPosition: Position{Line: 1, Column: 12}, EndPosition: Position{Line: 1, Column: 16}},
+			},
+		},
+		{
+			name:  "exception_keywords",
+			input: "throw try catch finally",
+			want: []Token{
+				{Type: TT_THROW, Literal: "throw", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_TRY, Literal: "try", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 9}},
+				{Type: TT_CATCH, Literal: "catch", BeginPosition: Position{Line: 1, Column: 11}, EndPosition: Position{Line: 1, Column: 15}},
+				{Type: TT_FINALLY, Literal: "finally", BeginPosition: Position{Line: 1, Column: 17}, EndPosition: Position{Line: 1, Column: 23}},
+			},
+		},
+		{
+			name:  "attributes",
+			input: "xs.push(1.5)",
+			want: []Token{
+				{Type: TT_IDENTIFIER, Literal: "xs", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 2}},
+				{Type: TT_DOT, Literal: ".", BeginPosition: Position{Line: 1, Column: 3}, EndPosition: Position{Line: 1, Column: 3}},
+				{Type: TT_IDENTIFIER, Literal: "push", BeginPosition: Position{Line: 1, Column: 4}, EndPosition: Position{Line: 1, Column: 7}},
+				{Type: TT_LPAREN, Literal: "(", BeginPosition: Position{Line: 1, Column: 8}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_NUMBER, Literal: "1.5", BeginPosition: Position{Line: 1, Column: 9}, EndPosition: Position{Line: 1, Column: 11}},
+				{Type: TT_RPAREN, Literal: ")", BeginPosition: Position{Line: 1, Column: 12}, EndPosition: Position{Line: 1, Column: 12}},
+			},
+		},
+		{
+			name:  "match_arm",
+			input: "match (x) { _ => 1 }",
+			want: []Token{
+				{Type: TT_MATCH, Literal: "match", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 5}},
+				{Type: TT_LPAREN, Literal: "(", BeginPosition: Position{Line: 1, Column: 7}, EndPosition: Position{Line: 1, Column: 7}},
+				{Type: TT_IDENTIFIER, Literal: "x", BeginPosition: Position{Line: 1, Column: 8}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_RPAREN, Literal: ")", BeginPosition: Position{Line: 1, Column: 9}, EndPosition: Position{Line: 1, Column: 9}},
+				{Type: TT_LBRACE, Literal: "{", BeginPosition: Position{Line: 1, Column: 11}, EndPosition: Position{Line: 1, Column: 11}},
+				{Type: TT_IDENTIFIER, Literal: "_", BeginPosition: Position{Line: 1, Column: 13}, EndPosition: Position{Line: 1, Column: 13}},
+				{Type: TT_ARROW, Literal: "=>", BeginPosition: Position{Line: 1, Column: 15}, EndPosition: Position{Line: 1, Column: 16}},
+				{Type: TT_NUMBER, Literal: "1", BeginPosition: Position{Line: 1, Column: 18}, EndPosition: Position{Line: 1, Column: 18}},
+				{Type: TT_RBRACE, Literal: "}", BeginPosition: Position{Line: 1, Column: 20}, EndPosition: Position{Line: 1, Column: 20}},
+			},
+		},
+		{
+			name:  "ellipsis",
+			input: "[a, ...rest]",
+			want: []Token{
+				{Type: TT_LBRACKET, Literal: "[", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 1}},
+				{Type: TT_IDENTIFIER, Literal: "a", BeginPosition: Position{Line: 1, Column: 2}, EndPosition: Position{Line: 1, Column: 2}},
+				{Type: TT_COMMA, Literal: ",", BeginPosition: Position{Line: 1, Column: 3}, EndPosition: Position{Line: 1, Column: 3}},
+				{Type: TT_ELLIPSIS, Literal: "...", BeginPosition: Position{Line: 1, Column: 5}, EndPosition: Position{Line: 1, Column: 7}},
+				{Type: TT_IDENTIFIER, Literal: "rest", BeginPosition: Position{Line: 1, Column: 8}, EndPosition: Position{Line: 1, Column: 11}},
+				{Type: TT_RBRACKET, Literal: "]", BeginPosition: Position{Line: 1, Column: 12}, EndPosition: Position{Line: 1, Column: 12}},
+			},
+		},
+		{
+			name:  "set_literal",
+			input: "#{1} #",
+			want: []Token{
+				{Type: TT_HASH_LBRACE, Literal: "#{", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 2}},
+				{Type: TT_NUMBER, Literal: "1", BeginPosition: Position{Line: 1, Column: 3}, EndPosition: Position{Line: 1, Column: 3}},
+				{Type: TT_RBRACE, Literal: "}", BeginPosition: Position{Line: 1, Column: 4}, EndPosition: Position{Line: 1, Column: 4}},
+				{Type: TT_ILLEGAL, Literal: "#", Err: "unexpected character", BeginPosition: Position{Line: 1, Column: 6}, EndPosition: Position{Line: 1, Column: 6}},
+			},
+		},
+		{
+			name:  "unicode",
+			input: "var café = \"日本\" ü",
+			want: []Token{
+				{Type: TT_VAR, Literal: "var", BeginPosition: Position{Line: 1, Column: 1}, EndPosition: Position{Line: 1, Column: 3}},
+				{Type: TT_IDENTIFIER, Literal: "café", BeginPosition: Position{Line: 1, Column: 5}, EndPosition: Position{Line: 1, Column: 8}},
+				{Type: TT_ASSIGN, Literal: "=", BeginPosition: Position{Line: 1, Column: 10}, EndPosition: Position{Line: 1, Column: 10}},
+				{Type: TT_STRING, Literal: "日本", BeginPosition: Position{Line: 1, Column: 12}, EndPosition: Position{Line: 1, Column: 15}},
+				{Type: TT_IDENTIFIER, Literal: "ü", BeginPosition: Position{Line: 1, Column: 17}, EndPosition: Position{Line: 1, Column: 17}},
+			},
+		},
 		{
 			name:  "comments",
 			input: "// my very very long comment",
@@ -157,3 +395,100 @@ func TestLexer_NextToken(t *testing.T) {
 		})
 	}
 }
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{input: "42", want: 42},
+		{input: "0xFF", want: 255},
+		{input: "0b1010", want: 10},
+		{input: "0o17", want: 15},
+		{input: "1_000_000", want: 1000000},
+		{input: "0xdead_beef", want: 0xdeadbeef},
+		{input: "9223372036854775807", want: 9223372036854775807},
+		{input: "0o10", want: 8},
+		{input: "0x7fff_ffff_ffff_ffff", want: 9223372036854775807},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, NUM_INT, NumberKindOf(tt.input))
+			got, err := ParseInt(tt.input)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := ParseInt("9223372036854775808")
+	assert.EqualError(t, err, "integer literal is out of range")
+}
+
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{input: "42.0", want: 42},
+		{input: "0.5", want: 0.5},
+		{input: "1e9", want: 1e9},
+		{input: "6.02e23", want: 6.02e23},
+		{input: "2.5E-3", want: 2.5e-3},
+		{input: "1_000.5", want: 1000.5},
+		{input: "1e-400", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, NUM_FLOAT, NumberKindOf(tt.input))
+			got, err := ParseFloat(tt.input)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := ParseFloat("1e400")
+	assert.EqualError(t, err, "float literal is out of range")
+}
+
+func TestNumberKindOf(t *testing.T) {
+	tests := []struct {
+		input string
+		want  NumberKind
+	}{
+		{input: "42", want: NUM_INT},
+		{input: "0xd", want: NUM_INT},
+		{input: "4.2", want: NUM_FLOAT},
+		{input: "1e3", want: NUM_FLOAT},
+		{input: "42n", want: NUM_BIGINT},
+		{input: "0x2An", want: NUM_BIGINT},
+		{input: "4.20d", want: NUM_DECIMAL},
+		{input: "42d", want: NUM_DECIMAL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.want, NumberKindOf(tt.input))
+		})
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		scale int
+	}{
+		{input: "1.10d", want: "11/10", scale: 2},
+		{input: "5d", want: "5", scale: 0},
+		{input: "1_000.5d", want: "2001/2", scale: 1},
+		{input: "1.25e1d", want: "25/2", scale: 1},
+		{input: "1.5e3d", want: "1500", scale: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, scale, err := ParseDecimal(tt.input)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got.RatString())
+			assert.Equal(t, tt.scale, scale)
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package lex
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Number literals
+//
+// number      -> ( integer "n"? ) | ( decimal "d"? )
+// integer     -> digits | hexadecimal | binary | octal
+// decimal     -> digits ( "." digits )? ( ( "e" | "E" ) ( "+" | "-" )? digits )?
+// hexadecimal -> "0" ( "x" | "X" ) hexDigits
+// binary      -> "0" ( "b" | "B" ) binaryDigits
+// octal       -> "0" ( "o" | "O" ) octalDigits
+//
+// Digits may be separated by a single "_" e.g. 1_000_000
+// The "n" suffix denotes an arbitrary-precision integer and the "d" suffix a decimal e.g. 123n, 1.10d
+
+// NumberKind is the type of value a number literal denotes
+type NumberKind int
+
+const (
+	NUM_INT NumberKind = iota
+	NUM_FLOAT
+	NUM_BIGINT
+	NUM_DECIMAL
+)
+
+// NumberKindOf returns the type of value the literal of a TT_NUMBER token denotes
+// Decimal literals without a suffix denote floats if they have a decimal point or an exponent
+func NumberKindOf(literal string) NumberKind {
+	base := numberBase(literal)
+	switch {
+	case strings.HasSuffix(literal, "n"):
+		return NUM_BIGINT
+	case base == 10 && strings.HasSuffix(literal, "d"):
+		return NUM_DECIMAL
+	case base != 10 || !strings.ContainsAny(literal, ".eE"):
+		return NUM_INT
+	}
+	return NUM_FLOAT
+}
+
+// ParseInt converts the literal of an integer TT_NUMBER token to its value
+// Literals without a base prefix are decimal
+func ParseInt(literal string) (int64, error) {
+	literal = strings.ReplaceAll(literal, "_", "")
+	digits, base := splitBase(literal)
+	val, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		return 0, errors.New("integer literal is out of range")
+	}
+	return val, nil
+}
+
+// ParseFloat converts the literal of a TT_NUMBER token to a float
+// Literals too large to be represented are out of range, too small ones round to zero
+func ParseFloat(literal string) (float64, error) {
+	literal = strings.ReplaceAll(literal, "_", "")
+	if digits, base := splitBase(literal); base != 10 {
+		val, err := strconv.ParseUint(digits, base, 64)
+		return float64(val), err
+	}
+	val, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return 0, errors.New("float literal is out of range")
+	}
+	return val, nil
+}
+
+// ParseBigInt converts the literal of a bigint TT_NUMBER token to its value
+func ParseBigInt(literal string) (*big.Int, error) {
+	literal = strings.ReplaceAll(strings.TrimSuffix(literal, "n"), "_", "")
+	digits, base := splitBase(literal)
+	val, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid bigint literal: %s", literal)
+	}
+	return val, nil
+}
+
+// ParseDecimal converts the literal of a decimal TT_NUMBER token to its exact value
+// The scale is the number of digits after the decimal point the literal was written with
+func ParseDecimal(literal string) (*big.Rat, int, error) {
+	literal = strings.ReplaceAll(strings.TrimSuffix(literal, "d"), "_", "")
+	val, ok := new(big.Rat).SetString(literal)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid decimal literal: %s", literal)
+	}
+
+	mantissa, exponent := literal, 0
+	if i := strings.IndexAny(literal, "eE"); i >= 0 {
+		mantissa = literal[:i]
+		exponent, _ = strconv.Atoi(literal[i+1:])
+	}
+
+	scale := 0
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		scale = len(mantissa) - i - 1
+	}
+	scale -= exponent
+	if scale < 0 {
+		scale = 0
+	}
+	return val, scale, nil
+}
+
+// checkNumber validates a number literal and returns a description of the problem if it is malformed
+// Decimal integers can't have leading zeros, which other languages read as octal, and integers
+// without the "n" suffix must fit in 64 bits, floats must not overflow
+func checkNumber(literal string) string {
+	base := numberBase(literal)
+	kind := NumberKindOf(literal)
+	digits := literal
+	switch kind {
+	case NUM_BIGINT:
+		digits = literal[:len(literal)-1]
+		if NumberKindOf(digits) != NUM_INT {
+			return "bigint literal must be an integer"
+		}
+	case NUM_DECIMAL:
+		digits = literal[:len(literal)-1]
+	}
+
+	if err := checkDigits(digits, base); err != "" {
+		return err
+	}
+	if (kind == NUM_INT || kind == NUM_BIGINT) && base == 10 && len(digits) > 1 && digits[0] == '0' {
+		return "integer literal has a leading zero, use the 0o prefix for octal"
+	}
+	switch kind {
+	case NUM_INT:
+		if _, err := ParseInt(literal); err != nil {
+			return err.Error()
+		}
+	case NUM_FLOAT:
+		if _, err := ParseFloat(literal); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// checkDigits checks the digits, decimal point and exponent of a number literal without its suffix
+func checkDigits(literal string, base int) string {
+	if base != 10 {
+		digits := literal[2:]
+		if digits == "" {
+			return fmt.Sprintf("%s literal has no digits", baseName(base))
+		}
+		for _, r := range digits {
+			if r != '_' && !isDigitOfBase(r, base) {
+				return fmt.Sprintf("invalid digit '%c' in %s literal", r, baseName(base))
+			}
+		}
+		return checkSeparators(digits, base)
+	}
+
+	i := skipDigits(literal, 0)
+	if i < len(literal) && literal[i] == '.' {
+		i = skipDigits(literal, i+1)
+	}
+	if i < len(literal) && (literal[i] == 'e' || literal[i] == 'E') {
+		i++
+		if i < len(literal) && (literal[i] == '+' || literal[i] == '-') {
+			i++
+		}
+		exponent := skipDigits(literal, i)
+		if exponent == i {
+			return "exponent has no digits"
+		}
+		i = exponent
+	}
+
+	if i < len(literal) {
+		if literal[i] == '.' {
+			return "number has more than one decimal point"
+		}
+		r, _ := utf8.DecodeRuneInString(literal[i:])
+		return fmt.Sprintf("invalid character '%c' in number", r)
+	}
+	return checkSeparators(literal, base)
+}
+
+// checkSeparators checks that every "_" is between two digits
+func checkSeparators(literal string, base int) string {
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(literal)-1 || !isDigitOfBase(rune(literal[i-1]), base) || !isDigitOfBase(rune(literal[i+1]), base) {
+			return "'_' must separate successive digits"
+		}
+	}
+	return ""
+}
+
+// isExponentSign checks if a sign following the given partial number literal belongs to its exponent
+func isExponentSign(partial string) bool {
+	if numberBase(partial) != 10 || len(partial) == 0 {
+		return false
+	}
+	last := partial[len(partial)-1]
+	return last == 'e' || last == 'E'
+}
+
+// splitBase removes the base prefix of a number literal
+func splitBase(literal string) (string, int) {
+	if base := numberBase(literal); base != 10 {
+		return literal[2:], base
+	}
+	return literal, 10
+}
+
+func numberBase(literal string) int {
+	if len(literal) < 2 || literal[0] != '0' {
+		return 10
+	}
+	switch literal[1] {
+	case 'x', 'X':
+		return 16
+	case 'b', 'B':
+		return 2
+	case 'o', 'O':
+		return 8
+	}
+	return 10
+}
+
+func baseName(base int) string {
+	switch base {
+	case 16:
+		return "hexadecimal"
+	case 2:
+		return "binary"
+	case 8:
+		return "octal"
+	}
+	return "decimal"
+}
+
+func isDigitOfBase(r rune, base int) bool {
+	switch base {
+	case 16:
+		return isHexDigit(r)
+	case 2:
+		return r == '0' || r == '1'
+	case 8:
+		return r >= '0' && r <= '7'
+	}
+	return isDigit(r)
+}
+
+func skipDigits(literal string, i int) int {
+	for i < len(literal) && (isDigit(rune(literal[i])) || literal[i] == '_') {
+		i++
+	}
+	return i
+}
@@ -11,6 +11,8 @@ type Token struct {
 	Literal       string
 	BeginPosition Position
 	EndPosition   Position
+	// Describes why the token is illegal, only set for TT_ILLEGAL tokens
+	Err string
 }
 
 func (t Token) String() string {
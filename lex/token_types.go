@@ -7,6 +7,24 @@ func LookupIdentifierType(v string) TokenType {
 	return TT_IDENTIFIER
 }
 
+// CompoundAssignmentOp returns the binary operator applied by a compound assignment such as "+="
+// It returns false for plain assignments
+func CompoundAssignmentOp(t TokenType) (TokenType, bool) {
+	switch t {
+	case TT_PLUS_ASSIGN:
+		return TT_PLUS, true
+	case TT_MINUS_ASSIGN:
+		return TT_MINUS, true
+	case TT_MULTIPLY_ASSIGN:
+		return TT_MULTIPLY, true
+	case TT_DIVIDE_ASSIGN:
+		return TT_DIVIDE, true
+	case TT_MODULO_ASSIGN:
+		return TT_MODULO, true
+	}
+	return t, false
+}
+
 const (
 	TT_ILLEGAL TokenType = iota
 	TT_EOF
@@ -15,9 +33,16 @@ const (
 	TT_IDENTIFIER
 	TT_NUMBER
 	TT_STRING
+	TT_STRING_PART
+	TT_STRING_END
 
 	// Operators
 	TT_ASSIGN
+	TT_PLUS_ASSIGN
+	TT_MINUS_ASSIGN
+	TT_MULTIPLY_ASSIGN
+	TT_DIVIDE_ASSIGN
+	TT_MODULO_ASSIGN
 	TT_PLUS
 	TT_MINUS
 	TT_DIVIDE
@@ -32,10 +57,15 @@ const (
 	TT_GTE
 	TT_LOGICAL_AND
 	TT_LOGICAL_OR
+	TT_NOT_IN
 
 	// Delimiters
 	TT_COMMA
+	TT_DOT
+	TT_ELLIPSIS
+	TT_ARROW
 	TT_COLON
+	TT_SEMICOLON
 	TT_QUESTION
 
 	// Parens + Braces
@@ -43,6 +73,7 @@ const (
 	TT_RPAREN
 	TT_LBRACE
 	TT_RBRACE
+	TT_HASH_LBRACE
 	TT_LBRACKET
 	TT_RBRACKET
 
@@ -55,12 +86,25 @@ const (
 	TT_FALSE
 	TT_RETURN
 	TT_WHILE
+	TT_DO
+	TT_FOR
+	TT_IN
+	TT_NOT_KEYWORD
 	TT_BREAK
 	TT_CONTINUE
 	TT_NIL
 	TT_DEFER
 	TT_ASSERT
 	TT_IMPORT
+	TT_STRUCT
+	TT_CLASS
+	TT_THIS
+	TT_SUPER
+	TT_THROW
+	TT_TRY
+	TT_CATCH
+	TT_FINALLY
+	TT_MATCH
 
 	// Misc
 	TT_COMMENT
@@ -78,8 +122,22 @@ func (t TokenType) String() string {
 		return "NUM"
 	case TT_STRING:
 		return "STR"
+	case TT_STRING_PART:
+		return "STR_PART"
+	case TT_STRING_END:
+		return "STR_END"
 	case TT_ASSIGN:
 		return "="
+	case TT_PLUS_ASSIGN:
+		return "+="
+	case TT_MINUS_ASSIGN:
+		return "-="
+	case TT_MULTIPLY_ASSIGN:
+		return "*="
+	case TT_DIVIDE_ASSIGN:
+		return "/="
+	case TT_MODULO_ASSIGN:
+		return "%="
 	case TT_PLUS:
 		return "+"
 	case TT_MINUS:
@@ -106,14 +164,24 @@ func (t TokenType) String() string {
 		return "&&"
 	case TT_LOGICAL_OR:
 		return "||"
+	case TT_NOT_IN:
+		return "not in"
 	case TT_MODULO:
 		return "%"
 	case TT_COMMA:
 		return ","
+	case TT_DOT:
+		return "."
+	case TT_ELLIPSIS:
+		return "..."
+	case TT_ARROW:
+		return "=>"
 	case TT_QUESTION:
 		return "?"
 	case TT_COLON:
 		return ":"
+	case TT_SEMICOLON:
+		return ";"
 	case TT_COMMENT:
 		return "//"
 	case TT_LPAREN:
@@ -124,6 +192,8 @@ func (t TokenType) String() string {
 		return "{"
 	case TT_RBRACE:
 		return "}"
+	case TT_HASH_LBRACE:
+		return "#{"
 	case TT_LBRACKET:
 		return "["
 	case TT_RBRACKET:
@@ -144,6 +214,14 @@ func (t TokenType) String() string {
 		return "return"
 	case TT_WHILE:
 		return "while"
+	case TT_DO:
+		return "do"
+	case TT_FOR:
+		return "for"
+	case TT_IN:
+		return "in"
+	case TT_NOT_KEYWORD:
+		return "not"
 	case TT_BREAK:
 		return "break"
 	case TT_CONTINUE:
@@ -156,6 +234,24 @@ func (t TokenType) String() string {
 		return "assert"
 	case TT_IMPORT:
 		return "import"
+	case TT_STRUCT:
+		return "struct"
+	case TT_CLASS:
+		return "class"
+	case TT_THIS:
+		return "this"
+	case TT_SUPER:
+		return "super"
+	case TT_THROW:
+		return "throw"
+	case TT_TRY:
+		return "try"
+	case TT_CATCH:
+		return "catch"
+	case TT_FINALLY:
+		return "finally"
+	case TT_MATCH:
+		return "match"
 	default:
 		return "<UNKNOWN>"
 	}
@@ -6,27 +6,36 @@ import (
 	"os"
 
 	"github.com/shreerangdixit/yeti/build"
+	"github.com/shreerangdixit/yeti/eval"
 	"github.com/shreerangdixit/yeti/run"
 )
 
 var flagVer bool
+var flagEngine string
 
 func init() {
 	flag.BoolVar(&flagVer, "v", false, "Display version/build info")
+	flag.StringVar(&flagEngine, "engine", string(eval.EngineTree), "Execution engine (tree|vm)")
 }
 
 func main() {
 	flag.Parse()
 
+	engine, err := eval.ParseEngine(flagEngine)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(2)
+	}
+
 	if flagVer {
 		fmt.Println(build.Info)
 		os.Exit(0)
-	} else if len(os.Args) > 1 {
-		err := run.File(os.Args[1])
+	} else if flag.NArg() > 0 {
+		err := run.File(flag.Arg(0), engine)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err)
 		}
 	} else {
-		run.REPL()
+		run.REPL(engine)
 	}
 }
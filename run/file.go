@@ -8,7 +8,7 @@ import (
 	"github.com/shreerangdixit/yeti/eval"
 )
 
-func File(file string) error {
+func File(file string, engine eval.Engine) error {
 	absPath, err := filepath.Abs(file)
 	if err != nil {
 		panic(err)
@@ -19,6 +19,6 @@ func File(file string) error {
 		panic(err)
 	}
 
-	e := eval.NewEvaluator()
+	e := eval.NewEvaluator().WithEngine(engine)
 	return e.Importer.Import(eval.NewFileModule(absPath))
 }
@@ -21,8 +21,8 @@ const Logo = `
     |_|  |______|  |_|  |_____|
 `
 
-func REPL() {
-	r := newRepl()
+func REPL(engine eval.Engine) {
+	r := newRepl(engine)
 	r.Start()
 }
 
@@ -30,13 +30,15 @@ type repl struct {
 	in     io.Reader
 	out    io.Writer
 	errout io.Writer
+	engine eval.Engine
 }
 
-func newRepl() *repl {
+func newRepl(engine eval.Engine) *repl {
 	return &repl{
 		in:     os.Stdin,
 		out:    os.Stdout,
 		errout: os.Stderr,
+		engine: engine,
 	}
 }
 
@@ -45,7 +47,7 @@ func (r *repl) Start() {
 	fmt.Fprintf(r.out, "%s", build.Info)
 
 	scanner := bufio.NewScanner(r.in)
-	e := eval.NewEvaluator()
+	e := eval.NewEvaluator().WithEngine(r.engine)
 	for {
 		fmt.Fprintf(r.out, "yeti >>> ")
 
@@ -66,6 +68,11 @@ func (r *repl) Start() {
 			continue
 		}
 
+		if err := e.Resolve(root); err != nil {
+			r.printErr(cmd, err)
+			continue
+		}
+
 		// If the input is a single expression, eval and print the result
 		// Otherwise run statements
 		exp, ok := isSingleExpression(root)